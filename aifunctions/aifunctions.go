@@ -0,0 +1,7 @@
+// Package aifunctions holds the json schema definitions of the tools the bot can call
+package aifunctions
+
+import "embed"
+
+//go:embed *.json
+var Definitions embed.FS
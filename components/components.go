@@ -2,11 +2,16 @@ package components
 
 import (
 	"log"
+	"strings"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/google/uuid"
 )
 
+// separates the prefix from the rest of a stable CustomID. see HandlePrefix
+const CUSTOM_ID_SEPARATOR = ":"
+
 type ButtonFunc func() (discordgo.Button, func())
 
 type ComponentClient interface {
@@ -20,24 +25,32 @@ type ComponentClient interface {
 }
 
 type ComponentHandler struct {
-	client            ComponentClient // discord client
-	callbackFuncs     map[string]func(*discordgo.InteractionCreate)
+	client        ComponentClient // discord client
+	callbackFuncs map[string]func(*discordgo.InteractionCreate)
+	// CustomID prefix -> callback for components that keep working after a restart
+	prefixFuncs       map[string]func(*discordgo.InteractionCreate)
 	removeHandlerFunc func()
+	// components can be created from scheduled jobs
+	mu sync.Mutex
 }
 
 func NewComponentHandler(client ComponentClient) *ComponentHandler {
 	componentHandler := &ComponentHandler{
 		client:        client,
 		callbackFuncs: map[string]func(*discordgo.InteractionCreate){},
+		prefixFuncs:   map[string]func(*discordgo.InteractionCreate){},
 	}
 
 	removeHandlerFunc := client.AddHandler(func(s *discordgo.Session, i *discordgo.InteractionCreate) {
 		if i.Type != discordgo.InteractionMessageComponent {
 			return
 		}
-		if callbackFunc, ok := componentHandler.callbackFuncs[i.MessageComponentData().CustomID]; ok {
+		customID := i.MessageComponentData().CustomID
+		if callbackFunc, ok := componentHandler.getCallback(customID); ok {
 			noOpResponse(componentHandler.client, i)
 			callbackFunc(i)
+		} else if prefixFunc, ok := componentHandler.getPrefixCallback(customID); ok {
+			prefixFunc(i)
 		} else {
 			if err := client.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 				Type: discordgo.InteractionResponseChannelMessageWithSource,
@@ -66,7 +79,7 @@ func (c *ComponentHandler) Close() {
 func (c *ComponentHandler) SelectMenu(selectMenu discordgo.SelectMenu, onSelect func(i *discordgo.InteractionCreate)) discordgo.ActionsRow {
 	componentID := uuid.New().String()
 	selectMenu.CustomID = componentID
-	c.callbackFuncs[componentID] = onSelect
+	c.setCallback(componentID, onSelect)
 
 	return discordgo.ActionsRow{
 		Components: []discordgo.MessageComponent{
@@ -80,7 +93,7 @@ func (c *ComponentHandler) SelectMenu(selectMenu discordgo.SelectMenu, onSelect
 func (c *ComponentHandler) WithButton(button discordgo.Button, onClick func(i *discordgo.InteractionCreate)) discordgo.Button {
 	componentID := uuid.New().String()
 	button.CustomID = componentID
-	c.callbackFuncs[componentID] = onClick
+	c.setCallback(componentID, onClick)
 
 	return button
 }
@@ -90,14 +103,64 @@ func (c *ComponentHandler) WithButton(button discordgo.Button, onClick func(i *d
 func (c *ComponentHandler) WithSubmitButton(button discordgo.Button, onClick func(i *discordgo.InteractionCreate)) discordgo.Button {
 	componentID := uuid.New().String()
 	button.CustomID = componentID
-	c.callbackFuncs[componentID] = func(i *discordgo.InteractionCreate) {
+	c.setCallback(componentID, func(i *discordgo.InteractionCreate) {
 		onClick(i)
-		delete(c.callbackFuncs, componentID)
-	}
+		c.deleteCallback(componentID)
+	})
 
 	return button
 }
 
+// HandlePrefix calls onInteract for every component with a CustomID made by MakeCustomID with the prefix.
+// The ids are stable so the components keep working after a restart.
+// onInteract is responsible for responding to the interaction
+func (c *ComponentHandler) HandlePrefix(prefix string, onInteract func(i *discordgo.InteractionCreate)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.prefixFuncs[prefix] = onInteract
+}
+
+// MakeCustomID joins the prefix and parts into a CustomID handled by HandlePrefix
+func MakeCustomID(prefix string, parts ...string) string {
+	return strings.Join(append([]string{prefix}, parts...), CUSTOM_ID_SEPARATOR)
+}
+
+// ParseCustomID gets the parts after the prefix of a CustomID made by MakeCustomID
+func ParseCustomID(customID string) []string {
+	parts := strings.Split(customID, CUSTOM_ID_SEPARATOR)
+	return parts[1:]
+}
+
+func (c *ComponentHandler) getPrefixCallback(customID string) (func(*discordgo.InteractionCreate), bool) {
+	prefix, _, found := strings.Cut(customID, CUSTOM_ID_SEPARATOR)
+	if !found {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	callbackFunc, ok := c.prefixFuncs[prefix]
+	return callbackFunc, ok
+}
+
+func (c *ComponentHandler) getCallback(componentID string) (func(*discordgo.InteractionCreate), bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	callbackFunc, ok := c.callbackFuncs[componentID]
+	return callbackFunc, ok
+}
+
+func (c *ComponentHandler) setCallback(componentID string, callbackFunc func(*discordgo.InteractionCreate)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callbackFuncs[componentID] = callbackFunc
+}
+
+func (c *ComponentHandler) deleteCallback(componentID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.callbackFuncs, componentID)
+}
+
 // ButtonRow executes the ButtonFuncs to that create the buttons and register each handler. See WithButton and WithSubmitButton.
 // returns an ActionRow component
 func ButtonRow(client ComponentClient, buttons ...discordgo.Button) discordgo.ActionsRow {
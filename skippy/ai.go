@@ -4,8 +4,11 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/sashabaranov/go-openai"
 )
 
@@ -16,9 +19,21 @@ const (
 )
 
 type ResponseReq struct {
-	ChannelID              string
-	UserID                 string
-	Message                string
+	GuildID   string
+	ChannelID string
+	// the ChatThread used for the conversation. defaults to ChannelID
+	ThreadID string
+	UserID   string
+	Message  string
+	// the text the user wrote without the reply chain, files or prompts. only this is moderated before the request
+	UserMessage string
+	// images attached to the message
+	Images []ImageInput
+	// images in the message being replied to. only used by the image tools
+	ReplyImages []ImageInput
+	// files attached to the message. they are read after the rate limit and budget
+	// checks since large files are summarized by the model
+	Attachments            []*discordgo.MessageAttachment
 	Tools                  []openai.Tool
 	AdditionalInstructions string
 	DisableTools           bool
@@ -34,11 +49,29 @@ type ToolChoice interface {
 func GetResponse(ctx context.Context, s *Skippy, req ResponseReq) (string, error) {
 	var messages []openai.ChatCompletionMessage
 
-	thread, ok := s.State.GetThread(req.ChannelID)
-	if ok {
+	moderationInput := ModerationInput{
+		GuildID:   req.GuildID,
+		ChannelID: req.ChannelID,
+		UserID:    req.UserID,
+		Source:    MODERATION_SOURCE_MESSAGE,
+		Content:   req.UserMessage,
+	}
+	if isFlagged(ctx, moderationInput, s) {
+		return "", ErrContentFlagged
+	}
+
+	threadID := req.ThreadID
+	if threadID == "" {
+		threadID = req.ChannelID
+	}
+
+	thread, ok := s.State.GetThread(threadID)
+	if ok && len(thread.messages) > 0 {
 		messages = thread.messages
 	} else {
-		thread = s.State.NewThread(req.ChannelID)
+		if !ok {
+			thread = s.State.NewThread(threadID)
+		}
 		messages = append(messages, openai.ChatCompletionMessage{
 			Role:    openai.ChatMessageRoleSystem,
 			Content: s.Config.BaseInstructions,
@@ -54,8 +87,30 @@ func GetResponse(ctx context.Context, s *Skippy, req ResponseReq) (string, error
 		})
 	}
 
+	model, err := getBudgetModel(getChannelModel(req.GuildID, req.ChannelID, s), req.GuildID, req.UserID, s)
+	if err != nil {
+		return "", err
+	}
+	// the tools are taken away from models that can't use them which a request that needs one can't do without
+	if req.RequireTools && !supportsTools(model, s.Config) {
+		return "", fmt.Errorf("%w: %s", ErrToolsNotSupported, model)
+	}
+
+	files, notes := GetMessageFiles(ctx, req, model, s)
+	for _, file := range files {
+		req.Message += "\n" + file
+	}
+	for _, note := range notes {
+		req.Message += "\n" + note
+	}
+
+	if !supportsTools(model, s.Config) {
+		req.DisableTools = true
+		req.Tools = NO_TOOLS
+	}
+
 	if req.Tools == nil {
-		req.Tools = ALL_TOOLS
+		req.Tools = TOOL_REGISTRY.GuildTools(req.GuildID, s)
 	}
 
 	var toolChoice any = TOOL_CHOICE_AUTO
@@ -74,19 +129,30 @@ func GetResponse(ctx context.Context, s *Skippy, req ResponseReq) (string, error
 		}
 	}
 
-	if req.Message != "" {
-		messages = append(messages, openai.ChatCompletionMessage{
-			Role:    openai.ChatMessageRoleUser,
-			Content: req.Message,
-		})
+	usage := Usage{
+		GuildID:   req.GuildID,
+		ChannelID: req.ChannelID,
+		UserID:    req.UserID,
+		Model:     model,
+	}
+	defer func() {
+		recordUsage(usage, s)
+	}()
+
+	if req.Message != "" || len(req.Images) > 0 {
+		messages = append(messages, makeUserMessage(req, model, s.Config))
 	}
 
 	completionReq := openai.ChatCompletionRequest{
 		ToolChoice: toolChoice,
-		Model:      s.Config.DefaultModel,
+		Model:      model,
 		Messages:   addTimeAndUserID(messages, req.UserID),
 		Tools:      req.Tools,
 	}
+	// tool_choice can only be set when tools are provided
+	if len(req.Tools) == 0 {
+		completionReq.ToolChoice = nil
+	}
 
 	resp, err := makeRequest(ctx, completionReq, s)
 	if err != nil {
@@ -94,21 +160,36 @@ func GetResponse(ctx context.Context, s *Skippy, req ResponseReq) (string, error
 		return "", err
 	}
 
-	log.Println("tokens used: ", resp.Usage.TotalTokens)
+	usage.addResponse(resp)
 
 	choice := resp.Choices[0]
 
 	messages = append(messages, choice.Message)
 
-	// if prompted only with a system message and tool calls are required the model is returning with 
+	// if prompted only with a system message and tool calls are required the model is returning with
 	// stopped while attempting to call a function
 	if choice.FinishReason == openai.FinishReasonToolCalls || (req.RequireTools && choice.FinishReason == openai.FinishReasonStop) {
 		log.Println("Recieved tool call")
+		if len(choice.Message.ToolCalls) == 0 {
+			return "", fmt.Errorf("%s did not call a tool", resp.Model)
+		}
 		if req.ReturnToolOutput {
 			return choice.Message.ToolCalls[0].Function.Arguments, nil
 		}
 
-		toolOutputs := GetToolOutputs(ctx, choice.Message.ToolCalls, req.ChannelID, s)
+		// tools can post and save things so the arguments are checked before any are run
+		moderationInput.Source = MODERATION_SOURCE_TOOL_CALL
+		moderationInput.Content = formatToolCallArguments(choice.Message.ToolCalls)
+		if isFlagged(ctx, moderationInput, s) {
+			return "", ErrContentFlagged
+		}
+
+		toolOutputs := GetToolOutputs(ctx, choice.Message.ToolCalls, ToolContext{
+			GuildID:   req.GuildID,
+			ChannelID: req.ChannelID,
+			UserID:    req.UserID,
+			Images:    append(slices.Clone(req.Images), req.ReplyImages...),
+		}, s)
 		messages = append(messages, toolOutputs...)
 
 		completionReq.Messages = addTimeAndUserID(messages, req.ChannelID)
@@ -119,21 +200,22 @@ func GetResponse(ctx context.Context, s *Skippy, req ResponseReq) (string, error
 			return "", err
 		}
 
+		usage.addResponse(resp)
+
 		choice = resp.Choices[0]
 		messages = append(messages, choice.Message)
 	}
 
-	s.State.SetThreadMessages(req.ChannelID, messages)
-
-	return choice.Message.Content, nil
-}
+	moderationInput.Source = MODERATION_SOURCE_RESPONSE
+	moderationInput.Content = choice.Message.Content
+	if isFlagged(ctx, moderationInput, s) {
+		// the flagged exchange is left out of the conversation
+		return "", ErrContentFlagged
+	}
 
-func makeRequest(ctx context.Context, req openai.ChatCompletionRequest, s *Skippy) (openai.ChatCompletionResponse, error) {
-	startTime := time.Now()
-	resp, err := s.AIClient.CreateChatCompletion(ctx, req)
-	log.Println("Request took: ", time.Since(startTime))
+	s.State.SetThreadMessages(threadID, messages)
 
-	return resp, err
+	return choice.Message.Content, nil
 }
 
 // adds the current user id and the timestamp to the message list
@@ -150,3 +232,28 @@ func addTimeAndUserID(messages []openai.ChatCompletionMessage, userID string) []
 		Content: content,
 	})
 }
+
+// rough estimate of the number of tokens used by messages
+// assumes about four characters per token
+func estimateTokens(messages []openai.ChatCompletionMessage) int {
+	chars := 0
+	for _, message := range messages {
+		chars += len(message.Content)
+		for _, part := range message.MultiContent {
+			chars += len(part.Text)
+		}
+		for _, toolCall := range message.ToolCalls {
+			chars += len(toolCall.Function.Arguments)
+		}
+	}
+	return chars / 4
+}
+
+// joins the arguments of each tool call so they can be moderated together
+func formatToolCallArguments(toolCalls []openai.ToolCall) string {
+	var arguments []string
+	for _, toolCall := range toolCalls {
+		arguments = append(arguments, toolCall.Function.Arguments)
+	}
+	return strings.Join(arguments, "\n")
+}
@@ -0,0 +1,177 @@
+package skippy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_API_TIMEOUT     = 10 * time.Second
+	DEFAULT_STOCK_API_URL   = "https://www.alphavantage.co/query"
+	DEFAULT_WEATHER_API_URL = "http://api.weatherapi.com/v1"
+	// the largest response body that is read from an api
+	MAX_API_RESPONSE_SIZE = 5 << 20
+	MAX_REDIRECTS         = 10
+)
+
+// An external api request type with its own cache lifetime and timeout
+type APIEndpoint struct {
+	Name string
+	// how long successful responses are cached. 0 disables caching
+	TTL     time.Duration
+	Timeout time.Duration
+}
+
+var (
+	STOCK_QUOTE_ENDPOINT      = APIEndpoint{Name: "stock quote", TTL: 5 * time.Minute, Timeout: DEFAULT_API_TIMEOUT}
+	WEATHER_FORECAST_ENDPOINT = APIEndpoint{Name: "weather forecast", TTL: 15 * time.Minute, Timeout: DEFAULT_API_TIMEOUT}
+	NEWS_FEED_ENDPOINT        = APIEndpoint{Name: "news feed", TTL: 10 * time.Minute, Timeout: DEFAULT_API_TIMEOUT}
+)
+
+// client for external apis. requests should also use a context from the caller
+var apiClient = &http.Client{Timeout: DEFAULT_API_TIMEOUT}
+
+// successful responses shared by every endpoint keyed by url
+var apiResponseCache = &apiCache{entries: make(map[string]apiCacheEntry)}
+
+// An error response from an api, such as a rate limit message
+type APIError struct {
+	Endpoint string
+	Message  string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Endpoint, e.Message)
+}
+
+type apiCacheEntry struct {
+	body      []byte
+	expiresAt time.Time
+}
+
+type apiCache struct {
+	mu      sync.Mutex
+	entries map[string]apiCacheEntry
+}
+
+func (c *apiCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.body, true
+}
+
+func (c *apiCache) set(key string, body []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	// expired entries are removed here so the cache does not grow forever
+	for k, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = apiCacheEntry{body: body, expiresAt: now.Add(ttl)}
+}
+
+func apiGet(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// Gets the body of a successful response. Responses are cached for the endpoint's TTL
+// and error bodies are returned as an APIError
+func cachedAPIGet(ctx context.Context, endpoint APIEndpoint, url string) ([]byte, error) {
+	return cachedClientGet(ctx, apiClient, endpoint, url)
+}
+
+// Same as cachedAPIGet with a different client such as the one for urls given by users
+func cachedClientGet(ctx context.Context, client *http.Client, endpoint APIEndpoint, url string) ([]byte, error) {
+	if body, ok := apiResponseCache.get(url); ok {
+		return body, nil
+	}
+
+	if endpoint.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, endpoint.Timeout)
+		defer cancel()
+	}
+
+	response, err := apiGet(ctx, client, url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// read one extra byte to detect responses over the limit
+	body, err := io.ReadAll(io.LimitReader(response.Body, MAX_API_RESPONSE_SIZE+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MAX_API_RESPONSE_SIZE {
+		return nil, &APIError{Endpoint: endpoint.Name, Message: fmt.Sprintf("response is larger than %d bytes", MAX_API_RESPONSE_SIZE)}
+	}
+
+	if err := checkAPIError(endpoint, response, body); err != nil {
+		return nil, err
+	}
+
+	if endpoint.TTL > 0 {
+		apiResponseCache.set(url, body, endpoint.TTL)
+	}
+	return body, nil
+}
+
+// Gets a json response and decodes it into v
+func getAPIJSON(ctx context.Context, endpoint APIEndpoint, url string, v any) error {
+	body, err := cachedAPIGet(ctx, endpoint, url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("unable to read %s response: %w", endpoint.Name, err)
+	}
+	return nil
+}
+
+// Checks for error statuses and the error bodies apis return with a 200 status.
+// Alpha Vantage sends rate limits as a Note or Information message
+func checkAPIError(endpoint APIEndpoint, response *http.Response, body []byte) error {
+	var apiErr struct {
+		Note         string `json:"Note"`
+		Information  string `json:"Information"`
+		ErrorMessage string `json:"Error Message"`
+		Error        struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	// not every api responds with a json object
+	if strings.HasPrefix(strings.TrimSpace(string(body)), "{") {
+		if err := json.Unmarshal(body, &apiErr); err == nil {
+			for _, message := range []string{apiErr.Note, apiErr.Information, apiErr.ErrorMessage, apiErr.Error.Message} {
+				if message != "" {
+					return &APIError{Endpoint: endpoint.Name, Message: message}
+				}
+			}
+		}
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return &APIError{Endpoint: endpoint.Name, Message: response.Status}
+	}
+	return nil
+}
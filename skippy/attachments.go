@@ -0,0 +1,266 @@
+package skippy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	MAX_IMAGE_SIZE           = 5 * 1024 * 1024
+	MAX_IMAGES_PER_MESSAGE   = 4
+	MAX_FILE_SIZE            = 1024 * 1024
+	MAX_FILE_CHARS           = 12000
+	MAX_SUMMARIZE_CHARS      = 100000
+	FILE_FORMAT              = "[Attached file: %s]\n```\n%s\n```\n[End of file: %s]"
+	FILE_SUMMARY_FORMAT      = "[Attached file: %s (%d bytes). The file was too large to include so here is a summary]\n%s\n[End of file: %s]"
+	FILE_SIZE_NOTE_FORMAT    = "[The user attached %s but it was too large to read.]"
+	FILE_ERROR_NOTE_FORMAT   = "[The user attached %s but it could not be read.]"
+	FILE_TRUNCATED_NOTE      = "\n... (truncated)"
+	NO_VISION_NOTE_FORMAT    = "[The user attached %d image(s) (%s) but the current model is unable to view images. Let them know.]"
+	IMAGE_SIZE_NOTE_FORMAT   = "[The user attached %s but it was too large to view.]"
+	IMAGE_COUNT_NOTE_FORMAT  = "[The user attached %d more image(s) than can be viewed at once.]"
+	REPLY_IMAGES_NOTE_FORMAT = "[The message being replied to has %d image(s) (%s) that can be edited or used for variations.]"
+)
+
+var (
+	imageExtensions = []string{".png", ".jpg", ".jpeg", ".gif", ".webp"}
+	textExtensions  = []string{
+		".txt", ".log", ".md", ".csv", ".json", ".yaml", ".yml", ".toml", ".ini", ".cfg", ".conf", ".xml", ".html", ".css",
+		".go", ".py", ".js", ".ts", ".java", ".c", ".h", ".cpp", ".rs", ".rb", ".sh", ".sql", ".diff", ".patch",
+	}
+	textContentTypes = []string{"application/json", "application/xml", "application/x-yaml", "application/x-sh"}
+	urlRegex         = regexp.MustCompile(`https?://[^\s<>]+`)
+)
+
+// an image included with a user message
+type ImageInput struct {
+	Name string
+	URL  string
+}
+
+// Collects the images from a discord message.
+// This includes image attachments, image urls in the content, and embedded images.
+// Returns the images and any notes about images that were dropped
+func getMessageImages(m *discordgo.Message, config *Config) ([]ImageInput, []string) {
+	var images []ImageInput
+	var notes []string
+	seen := make(map[string]bool)
+
+	add := func(image ImageInput) {
+		if image.URL == "" || seen[image.URL] {
+			return
+		}
+		seen[image.URL] = true
+		images = append(images, image)
+	}
+
+	for _, attachment := range m.Attachments {
+		if !isImageAttachment(attachment) {
+			continue
+		}
+		if config.MaxImageSize > 0 && int64(attachment.Size) > config.MaxImageSize {
+			log.Printf("skipping image %s with size %d\n", attachment.Filename, attachment.Size)
+			notes = append(notes, fmt.Sprintf(IMAGE_SIZE_NOTE_FORMAT, attachment.Filename))
+			continue
+		}
+		add(ImageInput{Name: attachment.Filename, URL: attachment.URL})
+	}
+
+	for _, url := range urlRegex.FindAllString(m.Content, -1) {
+		if isImageURL(url) {
+			add(ImageInput{Name: filepath.Base(removeQuery(url)), URL: url})
+		}
+	}
+
+	for _, embed := range m.Embeds {
+		if embed.Image != nil {
+			add(ImageInput{Name: embed.Title, URL: embed.Image.URL})
+		} else if embed.Type == discordgo.EmbedTypeImage && embed.Thumbnail != nil {
+			add(ImageInput{Name: embed.Title, URL: embed.Thumbnail.URL})
+		}
+	}
+
+	if config.MaxImagesPerMessage > 0 && len(images) > config.MaxImagesPerMessage {
+		notes = append(notes, fmt.Sprintf(IMAGE_COUNT_NOTE_FORMAT, len(images)-config.MaxImagesPerMessage))
+		images = images[:config.MaxImagesPerMessage]
+	}
+
+	return images, notes
+}
+
+// Lets the model know about images in the replied to message since only the image tools use them
+func makeReplyImagesNote(images []ImageInput) string {
+	if len(images) == 0 {
+		return ""
+	}
+	var names []string
+	for _, image := range images {
+		names = append(names, image.Name)
+	}
+	return fmt.Sprintf(REPLY_IMAGES_NOTE_FORMAT, len(images), strings.Join(names, ", "))
+}
+
+func isImageAttachment(attachment *discordgo.MessageAttachment) bool {
+	if attachment.ContentType != "" {
+		return strings.HasPrefix(attachment.ContentType, "image/")
+	}
+	return isImageURL(attachment.Filename)
+}
+
+func isImageURL(url string) bool {
+	ext := strings.ToLower(filepath.Ext(removeQuery(url)))
+	return slices.Contains(imageExtensions, ext)
+}
+
+func supportsVision(model string, config *Config) bool {
+	return slices.Contains(config.VisionModels, model)
+}
+
+// Creates the user message for a request.
+// If the model supports vision the images are added as image parts,
+// otherwise a note is added to the content so the model can tell the user
+func makeUserMessage(req ResponseReq, model string, config *Config) openai.ChatCompletionMessage {
+	message := openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: req.Message,
+	}
+
+	if len(req.Images) == 0 {
+		return message
+	}
+
+	if !supportsVision(model, config) {
+		var names []string
+		for _, image := range req.Images {
+			names = append(names, image.Name)
+		}
+		message.Content += "\n" + fmt.Sprintf(NO_VISION_NOTE_FORMAT, len(req.Images), strings.Join(names, ", "))
+		return message
+	}
+
+	parts := []openai.ChatMessagePart{
+		{
+			Type: openai.ChatMessagePartTypeText,
+			Text: req.Message,
+		},
+	}
+	for _, image := range req.Images {
+		parts = append(parts, openai.ChatMessagePart{
+			Type: openai.ChatMessagePartTypeImageURL,
+			ImageURL: &openai.ChatMessageImageURL{
+				URL:    image.URL,
+				Detail: openai.ImageURLDetailAuto,
+			},
+		})
+	}
+
+	return openai.ChatCompletionMessage{
+		Role:         openai.ChatMessageRoleUser,
+		MultiContent: parts,
+	}
+}
+
+// Downloads the text attachments of a request and formats them as labelled context.
+// Files over Config.MaxFileChars are summarized with model and files over Config.MaxFileSize are skipped.
+// Returns the context for each file and notes for any files that could not be included
+func GetMessageFiles(ctx context.Context, req ResponseReq, model string, s *Skippy) ([]string, []string) {
+	var files []string
+	var notes []string
+
+	for _, attachment := range req.Attachments {
+		if !isTextAttachment(attachment) {
+			continue
+		}
+
+		if s.Config.MaxFileSize > 0 && int64(attachment.Size) > s.Config.MaxFileSize {
+			log.Printf("skipping file %s with size %d\n", attachment.Filename, attachment.Size)
+			notes = append(notes, fmt.Sprintf(FILE_SIZE_NOTE_FORMAT, attachment.Filename))
+			continue
+		}
+
+		data, err := downloadAttachment(ctx, GetPublicClient(s.Config), attachment.URL, s.Config.MaxFileSize)
+		if err != nil || !utf8.Valid(data) {
+			log.Printf("unable to read file %s: %s\n", attachment.Filename, err)
+			notes = append(notes, fmt.Sprintf(FILE_ERROR_NOTE_FORMAT, attachment.Filename))
+			continue
+		}
+
+		content := string(data)
+		if s.Config.MaxFileChars <= 0 || len(content) <= s.Config.MaxFileChars {
+			files = append(files, fmt.Sprintf(FILE_FORMAT, attachment.Filename, content, attachment.Filename))
+			continue
+		}
+
+		summary, err := summarizeFile(ctx, req, model, attachment.Filename, content, s)
+		if err != nil {
+			log.Printf("unable to summarize file %s: %s\n", attachment.Filename, err)
+			files = append(files, fmt.Sprintf(FILE_FORMAT, attachment.Filename, truncate(content, s.Config.MaxFileChars), attachment.Filename))
+			continue
+		}
+		files = append(files, fmt.Sprintf(FILE_SUMMARY_FORMAT, attachment.Filename, len(data), summary, attachment.Filename))
+	}
+
+	return files, notes
+}
+
+func isTextAttachment(attachment *discordgo.MessageAttachment) bool {
+	contentType := strings.Split(attachment.ContentType, ";")[0]
+	if strings.HasPrefix(contentType, "text/") || slices.Contains(textContentTypes, contentType) {
+		return true
+	}
+	ext := strings.ToLower(filepath.Ext(attachment.Filename))
+	return slices.Contains(textExtensions, ext)
+}
+
+// Gets a summary of a file that is too large to include in a message.
+// The summary is counted in the usage of the request it is for
+func summarizeFile(ctx context.Context, req ResponseReq, model string, name string, content string, s *Skippy) (string, error) {
+	resp, err := makeRequest(ctx, openai.ChatCompletionRequest{
+		Model: model,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: SUMMARIZE_FILE_INSTRUCTIONS,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf(FILE_FORMAT, name, truncate(content, MAX_SUMMARIZE_CHARS), name),
+			},
+		},
+	}, s)
+	if err != nil {
+		return "", err
+	}
+
+	usage := Usage{
+		GuildID:   req.GuildID,
+		ChannelID: req.ChannelID,
+		UserID:    req.UserID,
+		Model:     model,
+	}
+	usage.addResponse(resp)
+	recordUsage(usage, s)
+
+	return resp.Choices[0].Message.Content, nil
+}
+
+// truncates content to maxChars without splitting a utf8 character
+func truncate(content string, maxChars int) string {
+	if len(content) <= maxChars {
+		return content
+	}
+	content = content[:maxChars]
+	for !utf8.ValidString(content) {
+		content = content[:len(content)-1]
+	}
+	return content + FILE_TRUNCATED_NOTE
+}
@@ -0,0 +1,47 @@
+package skippy
+
+import (
+	"fmt"
+	"time"
+)
+
+// A user's birthday in a guild. Shown in the birthdays section of digests
+type Birthday struct {
+	ID        uint   `gorm:"primaryKey"`
+	GuildID   string `gorm:"index"`
+	UserID    string
+	Month     time.Month
+	Day       int
+	CreatedAt time.Time
+}
+
+func setBirthday(guildID string, userID string, month int, day int, s *Skippy) (Birthday, error) {
+	birthday := Birthday{GuildID: guildID, UserID: userID, Month: time.Month(month), Day: day}
+	// checked against a leap year so february 29th is allowed
+	date := time.Date(2024, birthday.Month, day, 0, 0, 0, 0, time.UTC)
+	if month < 1 || month > 12 || date.Day() != day {
+		return birthday, fmt.Errorf("%d/%d is not a valid date", month, day)
+	}
+
+	return birthday, s.DB.SetBirthday(&birthday)
+}
+
+// Gets the birthdays in the guild on day.
+// February 29th birthdays are celebrated on the 28th when it is not a leap year
+func getBirthdaysOn(guildID string, day time.Time, s *Skippy) ([]Birthday, error) {
+	birthdays, err := s.DB.GetBirthdaysOn(guildID, day.Month(), day.Day())
+	if err != nil || day.Month() != time.February || day.Day() != 28 || isLeapYear(day.Year()) {
+		return birthdays, err
+	}
+
+	leapDay, err := s.DB.GetBirthdaysOn(guildID, time.February, 29)
+	return append(birthdays, leapDay...), err
+}
+
+func isLeapYear(year int) bool {
+	return time.Date(year, time.February, 29, 0, 0, 0, 0, time.UTC).Day() == 29
+}
+
+func formatBirthday(birthday Birthday) string {
+	return fmt.Sprintf("%s %d", birthday.Month, birthday.Day)
+}
@@ -13,12 +13,85 @@ type Config struct {
 	ReminderDurations []time.Duration
 	DefaultModel      string
 	DailyGameLimit    time.Duration
-	BaseInstructions string
+	BaseInstructions  string
 	// discordgo.User.ID -> UserPresenceConfig
 	UserConfigMap map[string]UserConfig
 	WeatherAPIKey string
 	StockAPIKey   string
-	Name BotName
+	// base urls of the weather and stock apis
+	WeatherAPIURL string
+	StockAPIURL   string
+	// WEATHER_UNITS_METRIC for users without a preference. imperial otherwise
+	WeatherUnits string
+	// how often channels watching the weather are checked for severe weather
+	WeatherWatchInterval time.Duration
+	// max size in bytes of an image attachment sent to the model
+	MaxImageSize int64
+	// max number of images sent to the model with a single message
+	MaxImagesPerMessage int
+	// max size in bytes of a text file attachment that will be downloaded
+	MaxFileSize int64
+	// files with more characters than this are summarized before being sent to the model
+	MaxFileChars int
+	// number of messages to follow up a reply chain for context
+	MaxReplyDepth int
+	// how conversations are split into threads
+	ThreadScope ThreadScope
+	// move long conversations into a new discord thread
+	AutoCreateThreads bool
+	// number of messages in a conversation before it is moved into a discord thread
+	AutoThreadMessageCount int
+	// model -> price per million tokens
+	ModelPrices map[string]ModelPrice
+	// image model, size and quality -> price in dollars per image
+	ImagePrices map[ImagePriceKey]float64
+	// price in dollars for images not in ImagePrices
+	ImagePrice float64
+	// model used by the image tools when one is not chosen
+	ImageModel string
+	// image models the image tools can use
+	ImageModels []string
+	// images each user can make a day. 0 for no limit
+	UserDailyImageLimit int
+	// daily spending limit in dollars for each guild. 0 for no limit
+	GuildDailyBudget float64
+	// daily spending limit in dollars for each user. 0 for no limit
+	UserDailyBudget float64
+	// cheaper model used once a budget is exceeded. if empty requests are refused
+	BudgetModel string
+	// default rate limits for AI requests
+	RateLimits RateLimits
+	// role ids that are not rate limited
+	RateLimitBypassRoles []string
+	// messages from a user sent within this delay are combined into one request. 0 to disable
+	MessageCoalesceDelay time.Duration
+	// timeout for a single completion request
+	RequestTimeout time.Duration
+	// number of times a request is retried after a rate limit or server error
+	MaxRetries     int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// models to try in order when the requested model fails. use provider:model for other providers
+	FallbackModels []string
+	// models that are able to view images
+	VisionModels []string
+	// models that can be chosen with /model
+	AllowedModels []string
+	// models that do not support function calling
+	NoToolModels []string
+	// default time a tool has to finish
+	ToolTimeout time.Duration
+	// tools that are disabled in every guild
+	DisabledTools []string
+	// MODERATION_OFF, LOW, MEDIUM or HIGH for guilds that have not set their own strictness
+	ModerationStrictness string
+	// IANA timezone for reminder times that do not have one. local time if empty
+	Timezone string
+	// RSS or Atom feed for the news section of digests
+	NewsFeedURL string
+	// lets urls given by users point at private and loopback addresses. only for testing
+	AllowPrivateURLs bool
+	Name        BotName
 }
 
 type UserConfig struct {
@@ -4,4 +4,6 @@ type key string
 
 const (
 	DisableFunctions key = "DisableFunctions"
+	// *time.Duration set to the Retry-After header of the response
+	RetryAfter key = "RetryAfter"
 )
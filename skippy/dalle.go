@@ -1,29 +1,221 @@
 package skippy
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/bwmarrin/discordgo"
 	"github.com/sashabaranov/go-openai"
 )
 
-// TODO: find better name
-// TODO: add context
-func GetImgUrl(prompt string, client *openai.Client) (string, error) {
-	log.Println("generating image from prompt: ", prompt)
+const (
+	DEFAULT_IMAGE_MODEL = openai.CreateImageModelDallE3
+	DEFAULT_IMAGE_SIZE  = openai.CreateImageSize1024x1024
+	IMAGE_FILE_NAME     = "image.png"
+	IMAGE_EMBED_COLOR   = 0x9b59b6
+	// discord limits for embed titles, descriptions and fields
+	MAX_EMBED_TITLE       = 256
+	MAX_EMBED_DESCRIPTION = 4096
+	MAX_EMBED_FIELDS      = 25
+	MAX_EMBED_FIELD_VALUE = 1024
+	IMAGE_LIMIT_RESPONSE  = "the user has used all %d of their images for today. let them know they can make more tomorrow"
+)
+
+// models that can be chosen with the image tools
+var DEFAULT_IMAGE_MODELS = []string{openai.CreateImageModelDallE3, openai.CreateImageModelDallE2}
+
+// sizes each model supports. sizes are not checked for other models
+var IMAGE_MODEL_SIZES = map[string][]string{
+	openai.CreateImageModelDallE2: {
+		openai.CreateImageSize256x256,
+		openai.CreateImageSize512x512,
+		openai.CreateImageSize1024x1024,
+	},
+	openai.CreateImageModelDallE3: {
+		openai.CreateImageSize1024x1024,
+		openai.CreateImageSize1792x1024,
+		openai.CreateImageSize1024x1792,
+	},
+}
+
+// models that do not support quality and style
+var NO_IMAGE_STYLE_MODELS = []string{openai.CreateImageModelDallE2}
 
-	imgReq := openai.ImageRequest{
-		Prompt:         prompt,
-		Size:           openai.CreateImageSize256x256,
-		ResponseFormat: openai.CreateImageResponseFormatURL,
+// An image made for a user. Used for the daily image limits
+type GeneratedImage struct {
+	ID        uint `gorm:"primaryKey"`
+	GuildID   string
+	ChannelID string
+	UserID    string `gorm:"index"`
+	Model     string
+	Size      string
+	Quality   string
+	Prompt    string
+	CreatedAt time.Time
+}
+
+type ImageOptions struct {
+	// Config.ImageModel if empty
+	Model   string
+	Size    string
+	Quality string
+	Style   string
+}
+
+// Makes a request for a single base64 image checking the options against the model
+func makeImageRequest(prompt string, options ImageOptions, config *Config) (openai.ImageRequest, error) {
+	if options.Model == "" {
+		options.Model = config.ImageModel
+	}
+	if !slices.Contains(config.ImageModels, options.Model) {
+		return openai.ImageRequest{}, fmt.Errorf(
+			"unknown image model %s. models are %s",
+			options.Model,
+			strings.Join(config.ImageModels, ", "),
+		)
+	}
+
+	if options.Size == "" {
+		options.Size = DEFAULT_IMAGE_SIZE
+	}
+	if sizes, ok := IMAGE_MODEL_SIZES[options.Model]; ok && !slices.Contains(sizes, options.Size) {
+		return openai.ImageRequest{}, fmt.Errorf(
+			"%s is unable to make %s images. sizes are %s",
+			options.Model,
+			options.Size,
+			strings.Join(sizes, ", "),
+		)
+	}
+
+	if options.Quality != "" &&
+		options.Quality != openai.CreateImageQualityStandard &&
+		options.Quality != openai.CreateImageQualityHD {
+		return openai.ImageRequest{}, fmt.Errorf("unknown image quality %s", options.Quality)
+	}
+	if options.Style != "" &&
+		options.Style != openai.CreateImageStyleVivid &&
+		options.Style != openai.CreateImageStyleNatural {
+		return openai.ImageRequest{}, fmt.Errorf("unknown image style %s", options.Style)
+	}
+	if slices.Contains(NO_IMAGE_STYLE_MODELS, options.Model) {
+		options.Quality = ""
+		options.Style = ""
+	}
+
+	return openai.ImageRequest{
+		Prompt:  prompt,
+		Model:   options.Model,
+		Size:    options.Size,
+		Quality: options.Quality,
+		Style:   options.Style,
+		// urls from openai expire so the image is uploaded instead
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
 		N:              1,
+	}, nil
+}
+
+// Generates an image. Returns the png and the prompt the model actually used
+func generateImage(ctx context.Context, imgReq openai.ImageRequest, client *openai.Client) ([]byte, string, error) {
+	log.Printf("generating %s %s image from prompt: %s\n", imgReq.Model, imgReq.Size, imgReq.Prompt)
+
+	resp, err := client.CreateImage(ctx, imgReq)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to generate image: %w", err)
+	}
+	return decodeImageResponse(resp)
+}
+
+func decodeImageResponse(resp openai.ImageResponse) ([]byte, string, error) {
+	if len(resp.Data) == 0 {
+		return nil, "", fmt.Errorf("no image was returned")
 	}
 
-	resp, err := client.CreateImage(context.Background(), imgReq)
+	image, err := base64.StdEncoding.DecodeString(resp.Data[0].B64JSON)
 	if err != nil {
-		return "", fmt.Errorf("unable to get image url: %s", err)
+		return nil, "", fmt.Errorf("unable to decode image: %w", err)
+	}
+	return image, resp.Data[0].RevisedPrompt, nil
+}
+
+// Uploads the image as an attachment shown in an embed
+func sendImage(channelID string, image []byte, title string, description string, s *Skippy) error {
+	_, err := s.DiscordSession.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+		Embeds: []*discordgo.MessageEmbed{
+			{
+				Title:       truncateEmbedText(title, MAX_EMBED_TITLE),
+				Description: truncateEmbedText(description, MAX_EMBED_DESCRIPTION),
+				Color:       IMAGE_EMBED_COLOR,
+				Image:       &discordgo.MessageEmbedImage{URL: "attachment://" + IMAGE_FILE_NAME},
+			},
+		},
+		Files: []*discordgo.File{
+			{Name: IMAGE_FILE_NAME, ContentType: "image/png", Reader: bytes.NewReader(image)},
+		},
+	})
+	return err
+}
+
+// held while images are counted and recorded so parallel tool calls see each other's images
+var imageLimitMu sync.Mutex
+
+// Records an image before it is made so parallel tool calls can't go over Config.UserDailyImageLimit.
+// Returns false if the user has made all of their images today.
+// The image should be released if it could not be made
+func reserveImage(toolCtx ToolContext, imgReq openai.ImageRequest, s *Skippy) (*GeneratedImage, bool) {
+	imageLimitMu.Lock()
+	defer imageLimitMu.Unlock()
+
+	if s.Config.UserDailyImageLimit > 0 && toolCtx.UserID != "" {
+		count, err := s.DB.CountGeneratedImages(toolCtx.UserID, startOfDay(time.Now()))
+		if err != nil {
+			log.Println("unable to check image limit: ", err)
+		} else if count >= int64(s.Config.UserDailyImageLimit) {
+			return nil, false
+		}
+	}
+
+	generated := &GeneratedImage{
+		GuildID:   toolCtx.GuildID,
+		ChannelID: toolCtx.ChannelID,
+		UserID:    toolCtx.UserID,
+		Model:     imgReq.Model,
+		Size:      imgReq.Size,
+		Quality:   imgReq.Quality,
+		Prompt:    imgReq.Prompt,
+	}
+	if err := s.DB.CreateGeneratedImage(generated); err != nil {
+		// the image is still made and charged without counting towards the limit
+		log.Println("unable to record generated image: ", err)
 	}
+	return generated, true
+}
+
+// Records the cost of a reserved image once it has been made.
+// Images that are refused, over the limit or fail are not charged
+func chargeImage(generated *GeneratedImage, s *Skippy) {
+	recordUsage(Usage{
+		GuildID:    generated.GuildID,
+		ChannelID:  generated.ChannelID,
+		UserID:     generated.UserID,
+		Model:      generated.Model,
+		ImageCalls: 1,
+		ImageCost:  getImagePrice(*generated, s.Config),
+	}, s)
+}
 
-	return resp.Data[0].URL, nil
+// Removes a reserved image that could not be made so it does not count towards the limit
+func releaseImage(generated *GeneratedImage, s *Skippy) {
+	if generated.ID == 0 {
+		return
+	}
+	if err := s.DB.DeleteGeneratedImage(generated.ID); err != nil {
+		log.Println("unable to release generated image: ", err)
+	}
 }
@@ -17,6 +17,66 @@ type Database interface {
 		daysAgo int,
 	) ([]GameSession, error)
 	GetGameSessionSum(userID string, daysAgo int) (time.Duration, error)
+	// gets the sessions of the users that started in [start, end)
+	GetGameSessionsBetween(userIDs []string, start time.Time, end time.Time) ([]GameSession, error)
+	CreateUsage(u *Usage) error
+	// gets the total cost since the given time. empty ids match everything
+	GetUsageCost(guildID string, userID string, since time.Time) (float64, error)
+	GetUsageSummary(guildID string, since time.Time) ([]UsageSummary, error)
+	// sets the model for a channel or for the guild if channelID is empty
+	SetModel(guildID string, channelID string, model string) error
+	// gets the channel model falling back to the guild model. empty if neither is set
+	GetModel(guildID string, channelID string) (string, error)
+	CreateReminder(r *Reminder) error
+	GetReminder(id uint) (*Reminder, error)
+	UpdateReminder(r *Reminder) error
+	DeleteReminder(id uint) error
+	// gets the reminders created by or for the user
+	GetRemindersByUser(userID string) ([]Reminder, error)
+	// gets the sent reminders in a channel that are waiting for the user to respond
+	GetPendingReminders(channelID string, userID string) ([]Reminder, error)
+	GetRemindersByChannel(channelID string) ([]Reminder, error)
+	GetAllReminders() ([]Reminder, error)
+	SetReminderOptOut(userID string, optOut bool) error
+	// checks if the user opted out of reminders from other people
+	IsReminderOptOut(userID string) (bool, error)
+	CreateDigest(d *Digest) error
+	GetDigest(id uint) (*Digest, error)
+	UpdateDigest(d *Digest) error
+	DeleteDigest(id uint) error
+	GetDigestsByChannel(channelID string) ([]Digest, error)
+	GetAllDigests() ([]Digest, error)
+	// sets the user's birthday in the guild replacing their current one
+	SetBirthday(b *Birthday) error
+	DeleteBirthday(guildID string, userID string) error
+	GetBirthdaysOn(guildID string, month time.Month, day int) ([]Birthday, error)
+	SetWeatherUnits(userID string, units string) error
+	// gets the user's preferred weather units. empty if they have not set them
+	GetWeatherUnits(userID string) (string, error)
+	CreateWeatherWatch(w *WeatherWatch) error
+	GetWeatherWatch(id uint) (*WeatherWatch, error)
+	UpdateWeatherWatch(w *WeatherWatch) error
+	DeleteWeatherWatch(id uint) error
+	GetWeatherWatchesByChannel(channelID string) ([]WeatherWatch, error)
+	GetAllWeatherWatches() ([]WeatherWatch, error)
+	CreateGeneratedImage(i *GeneratedImage) error
+	// counts the images made for the user since a time
+	CountGeneratedImages(userID string, since time.Time) (int64, error)
+	DeleteGeneratedImage(id uint) error
+	SetRateLimitSetting(r *RateLimitSetting) error
+	DeleteRateLimitSetting(guildID string) error
+	// gets the rate limits set for the guild. nil if they have not been set
+	GetRateLimitSetting(guildID string) (*RateLimitSetting, error)
+	SetBudgetSetting(b *BudgetSetting) error
+	DeleteBudgetSetting(guildID string) error
+	// gets the budget set for the guild. nil if it has not been set
+	GetBudgetSetting(guildID string) (*BudgetSetting, error)
+	SetToolSetting(t *ToolSetting) error
+	// gets the tools turned off in the guild. empty if none have been
+	GetToolSetting(guildID string) (ToolSetting, error)
+	SetModerationSetting(m *ModerationSetting) error
+	// gets the guild's moderation setting. empty if it has not been set
+	GetModerationSetting(guildID string) (ModerationSetting, error)
 	Close() error
 }
 
@@ -57,7 +117,7 @@ func NewDB(dialect, dsn string) (*DB, error) {
 		return nil, err
 	}
 
-	db.AutoMigrate(&GameSession{})
+	db.AutoMigrate(&GameSession{}, &Usage{}, &ModelSetting{}, &Reminder{}, &ReminderOptOut{}, &Digest{}, &Birthday{}, &WeatherPreference{}, &WeatherWatch{}, &GeneratedImage{}, &ModerationSetting{}, &RateLimitSetting{}, &BudgetSetting{}, &ToolSetting{})
 
 	return &DB{db}, nil
 }
@@ -119,3 +179,303 @@ func (db *DB) GetGameSessionSum(userID string, daysAgo int) (time.Duration, erro
 
 	return totDuration, err
 }
+
+func (db *DB) GetGameSessionsBetween(
+	userIDs []string,
+	start time.Time,
+	end time.Time,
+) ([]GameSession, error) {
+	var gs []GameSession
+	if len(userIDs) == 0 {
+		return gs, nil
+	}
+	err := db.Where("user_id IN ? AND started_at >= ? AND started_at < ?", userIDs, start, end).
+		Find(&gs).
+		Error
+	return gs, err
+}
+
+func (db *DB) CreateUsage(u *Usage) error {
+	return db.DB.Create(u).Error
+}
+
+func (db *DB) GetUsageCost(guildID string, userID string, since time.Time) (float64, error) {
+	query := db.Model(&Usage{}).
+		Select("IFNULL(SUM(cost), 0)").
+		Where("created_at >= ?", since)
+	if guildID != "" {
+		query = query.Where("guild_id = ?", guildID)
+	}
+	if userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var cost float64
+	err := query.Scan(&cost).Error
+	return cost, err
+}
+
+func (db *DB) GetUsageSummary(guildID string, since time.Time) ([]UsageSummary, error) {
+	var summaries []UsageSummary
+	err := db.Model(&Usage{}).
+		Select(`user_id, model, COUNT(*) AS requests, SUM(prompt_tokens) AS prompt_tokens,
+			SUM(completion_tokens) AS completion_tokens, SUM(image_calls) AS image_calls, SUM(cost) AS cost`).
+		Where("guild_id = ? AND created_at >= ?", guildID, since).
+		Group("user_id, model").
+		Order("cost DESC").
+		Scan(&summaries).Error
+	return summaries, err
+}
+
+func (db *DB) SetModel(guildID string, channelID string, model string) error {
+	setting := ModelSetting{GuildID: guildID, ChannelID: channelID}
+	return db.Where(&setting, "guild_id", "channel_id").
+		Assign(ModelSetting{Model: model}).
+		FirstOrCreate(&setting).Error
+}
+
+func (db *DB) GetModel(guildID string, channelID string) (string, error) {
+	var settings []ModelSetting
+	err := db.Where("guild_id = ? AND channel_id IN ?", guildID, []string{channelID, ""}).
+		Find(&settings).Error
+	if err != nil {
+		return "", err
+	}
+
+	var model string
+	for _, setting := range settings {
+		if setting.ChannelID == channelID {
+			return setting.Model, nil
+		}
+		model = setting.Model
+	}
+	return model, nil
+}
+
+func (db *DB) CreateReminder(r *Reminder) error {
+	return db.Create(r).Error
+}
+
+func (db *DB) GetReminder(id uint) (*Reminder, error) {
+	var r Reminder
+	err := db.First(&r, id).Error
+	return &r, err
+}
+
+func (db *DB) UpdateReminder(r *Reminder) error {
+	return db.Save(r).Error
+}
+
+func (db *DB) DeleteReminder(id uint) error {
+	return db.Delete(&Reminder{}, id).Error
+}
+
+func (db *DB) GetRemindersByUser(userID string) ([]Reminder, error) {
+	var reminders []Reminder
+	err := db.Where("user_id = ? OR target_user_id = ?", userID, userID).
+		Order("remind_at").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+func (db *DB) GetPendingReminders(channelID string, userID string) ([]Reminder, error) {
+	var reminders []Reminder
+	err := db.Where(
+		"sent = ? AND channel_id = ? AND (target_user_id = ? OR (target_user_id = '' AND user_id = ?))",
+		true, channelID, userID, userID,
+	).
+		Order("remind_at").
+		Find(&reminders).Error
+	return reminders, err
+}
+
+func (db *DB) GetRemindersByChannel(channelID string) ([]Reminder, error) {
+	var reminders []Reminder
+	err := db.Where("channel_id = ?", channelID).Order("remind_at").Find(&reminders).Error
+	return reminders, err
+}
+
+func (db *DB) GetAllReminders() ([]Reminder, error) {
+	var reminders []Reminder
+	err := db.Find(&reminders).Error
+	return reminders, err
+}
+
+func (db *DB) SetReminderOptOut(userID string, optOut bool) error {
+	if !optOut {
+		return db.Delete(&ReminderOptOut{UserID: userID}).Error
+	}
+	return db.Where(ReminderOptOut{UserID: userID}).FirstOrCreate(&ReminderOptOut{}).Error
+}
+
+func (db *DB) IsReminderOptOut(userID string) (bool, error) {
+	var count int64
+	err := db.Model(&ReminderOptOut{}).Where("user_id = ?", userID).Count(&count).Error
+	return count > 0, err
+}
+
+func (db *DB) CreateDigest(d *Digest) error {
+	return db.Create(d).Error
+}
+
+func (db *DB) GetDigest(id uint) (*Digest, error) {
+	var d Digest
+	err := db.First(&d, id).Error
+	return &d, err
+}
+
+func (db *DB) UpdateDigest(d *Digest) error {
+	return db.Save(d).Error
+}
+
+func (db *DB) DeleteDigest(id uint) error {
+	return db.Delete(&Digest{}, id).Error
+}
+
+func (db *DB) GetDigestsByChannel(channelID string) ([]Digest, error) {
+	var digests []Digest
+	err := db.Where("channel_id = ?", channelID).Order("time").Find(&digests).Error
+	return digests, err
+}
+
+func (db *DB) GetAllDigests() ([]Digest, error) {
+	var digests []Digest
+	err := db.Find(&digests).Error
+	return digests, err
+}
+
+func (db *DB) SetBirthday(b *Birthday) error {
+	return db.Where(Birthday{GuildID: b.GuildID, UserID: b.UserID}).
+		Assign(Birthday{Month: b.Month, Day: b.Day}).
+		FirstOrCreate(b).Error
+}
+
+func (db *DB) DeleteBirthday(guildID string, userID string) error {
+	return db.Where("guild_id = ? AND user_id = ?", guildID, userID).Delete(&Birthday{}).Error
+}
+
+func (db *DB) GetBirthdaysOn(guildID string, month time.Month, day int) ([]Birthday, error) {
+	var birthdays []Birthday
+	err := db.Where("guild_id = ? AND month = ? AND day = ?", guildID, month, day).
+		Find(&birthdays).Error
+	return birthdays, err
+}
+
+func (db *DB) SetWeatherUnits(userID string, units string) error {
+	return db.Save(&WeatherPreference{UserID: userID, Units: units}).Error
+}
+
+func (db *DB) GetWeatherUnits(userID string) (string, error) {
+	var preferences []WeatherPreference
+	err := db.Where("user_id = ?", userID).Find(&preferences).Error
+	if err != nil || len(preferences) == 0 {
+		return "", err
+	}
+	return preferences[0].Units, nil
+}
+
+func (db *DB) CreateWeatherWatch(w *WeatherWatch) error {
+	return db.Create(w).Error
+}
+
+func (db *DB) GetWeatherWatch(id uint) (*WeatherWatch, error) {
+	var w WeatherWatch
+	err := db.First(&w, id).Error
+	return &w, err
+}
+
+func (db *DB) UpdateWeatherWatch(w *WeatherWatch) error {
+	return db.Save(w).Error
+}
+
+func (db *DB) DeleteWeatherWatch(id uint) error {
+	return db.Delete(&WeatherWatch{}, id).Error
+}
+
+func (db *DB) GetWeatherWatchesByChannel(channelID string) ([]WeatherWatch, error) {
+	var watches []WeatherWatch
+	err := db.Where("channel_id = ?", channelID).Order("id").Find(&watches).Error
+	return watches, err
+}
+
+func (db *DB) GetAllWeatherWatches() ([]WeatherWatch, error) {
+	var watches []WeatherWatch
+	err := db.Find(&watches).Error
+	return watches, err
+}
+
+func (db *DB) CreateGeneratedImage(i *GeneratedImage) error {
+	return db.Create(i).Error
+}
+
+func (db *DB) CountGeneratedImages(userID string, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&GeneratedImage{}).
+		Where("user_id = ? AND created_at >= ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
+
+func (db *DB) DeleteGeneratedImage(id uint) error {
+	return db.Delete(&GeneratedImage{}, id).Error
+}
+
+func (db *DB) SetRateLimitSetting(r *RateLimitSetting) error {
+	return db.Save(r).Error
+}
+
+func (db *DB) DeleteRateLimitSetting(guildID string) error {
+	return db.Delete(&RateLimitSetting{GuildID: guildID}).Error
+}
+
+func (db *DB) GetRateLimitSetting(guildID string) (*RateLimitSetting, error) {
+	var settings []RateLimitSetting
+	err := db.Where("guild_id = ?", guildID).Find(&settings).Error
+	if err != nil || len(settings) == 0 {
+		return nil, err
+	}
+	return &settings[0], nil
+}
+
+func (db *DB) SetBudgetSetting(b *BudgetSetting) error {
+	return db.Save(b).Error
+}
+
+func (db *DB) DeleteBudgetSetting(guildID string) error {
+	return db.Delete(&BudgetSetting{GuildID: guildID}).Error
+}
+
+func (db *DB) GetBudgetSetting(guildID string) (*BudgetSetting, error) {
+	var settings []BudgetSetting
+	err := db.Where("guild_id = ?", guildID).Find(&settings).Error
+	if err != nil || len(settings) == 0 {
+		return nil, err
+	}
+	return &settings[0], nil
+}
+
+func (db *DB) SetToolSetting(t *ToolSetting) error {
+	return db.Save(t).Error
+}
+
+func (db *DB) GetToolSetting(guildID string) (ToolSetting, error) {
+	var settings []ToolSetting
+	err := db.Where("guild_id = ?", guildID).Find(&settings).Error
+	if err != nil || len(settings) == 0 {
+		return ToolSetting{GuildID: guildID}, err
+	}
+	return settings[0], nil
+}
+
+func (db *DB) SetModerationSetting(m *ModerationSetting) error {
+	return db.Save(m).Error
+}
+
+func (db *DB) GetModerationSetting(guildID string) (ModerationSetting, error) {
+	var settings []ModerationSetting
+	err := db.Where("guild_id = ?", guildID).Find(&settings).Error
+	if err != nil || len(settings) == 0 {
+		return ModerationSetting{GuildID: guildID}, err
+	}
+	return settings[0], nil
+}
@@ -31,3 +31,67 @@ func (d *Debouncer) Debounce(timerId string, callback func()) {
 		delete(d.timers, timerId)
 	})
 }
+
+// Coalescer combines requests that arrive in a burst into a single request
+type Coalescer struct {
+	mu      sync.Mutex
+	pending map[string][]ResponseReq
+	timers  map[string]*time.Timer
+	delay   time.Duration
+}
+
+func NewCoalescer(delay time.Duration) *Coalescer {
+	return &Coalescer{
+		pending: make(map[string][]ResponseReq),
+		timers:  make(map[string]*time.Timer),
+		delay:   delay,
+	}
+}
+
+// Add waits for the delay to pass without another request for key and then
+// calls callback with the combined request. A nil Coalescer calls callback immediately
+func (c *Coalescer) Add(key string, req ResponseReq, callback func(ResponseReq)) {
+	if c == nil || c.delay <= 0 {
+		callback(req)
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pending[key] = append(c.pending[key], req)
+	if timer, exists := c.timers[key]; exists {
+		timer.Stop()
+	}
+	c.timers[key] = time.AfterFunc(c.delay, func() {
+		c.mu.Lock()
+		reqs := c.pending[key]
+		delete(c.pending, key)
+		delete(c.timers, key)
+		c.mu.Unlock()
+
+		callback(mergeRequests(reqs))
+	})
+}
+
+// combines the messages and images of reqs. other fields are taken from the last request
+func mergeRequests(reqs []ResponseReq) ResponseReq {
+	merged := reqs[len(reqs)-1]
+	merged.Message = ""
+	merged.UserMessage = ""
+	merged.Images = nil
+	merged.ReplyImages = nil
+	merged.Attachments = nil
+	for i, req := range reqs {
+		if i > 0 {
+			merged.Message += "\n"
+			merged.UserMessage += "\n"
+		}
+		merged.Message += req.Message
+		merged.UserMessage += req.UserMessage
+		merged.Images = append(merged.Images, req.Images...)
+		merged.ReplyImages = append(merged.ReplyImages, req.ReplyImages...)
+		merged.Attachments = append(merged.Attachments, req.Attachments...)
+	}
+	return merged
+}
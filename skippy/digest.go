@@ -0,0 +1,248 @@
+package skippy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	// name of the digest managed by the morning message tools
+	DEFAULT_DIGEST_NAME = "morning"
+	DIGEST_EVERY_DAY    = "*"
+	DIGEST_WEEKDAYS     = "1-5"
+	DIGEST_WEEKENDS     = "0,6"
+)
+
+// A message sent to a channel on a schedule made of sections like the weather and stocks.
+// A channel can have multiple digests
+type Digest struct {
+	ID        uint `gorm:"primaryKey"`
+	GuildID   string
+	ChannelID string `gorm:"index"`
+	// used to tell the digests in a channel apart. ex: weekend
+	Name string
+	// time of day formatted as 15:04
+	Time string
+	// IANA timezone of Time. Config.Timezone is used if empty
+	Timezone string
+	// cron day of week field. ex: 1-5 for weekdays
+	Days             string
+	WeatherLocations []string `gorm:"serializer:json"`
+	Stocks           []string `gorm:"serializer:json"`
+	// names of the sections to include in order. DEFAULT_DIGEST_SECTIONS if nil
+	Sections []string `gorm:"serializer:json"`
+	// RSS or Atom feed for the news section. Config.NewsFeedURL is used if empty
+	FeedURL string
+	// the next time the digest will be sent
+	NextRun   time.Time
+	CreatedAt time.Time
+}
+
+func (d Digest) GetSections() []string {
+	if d.Sections == nil {
+		return DEFAULT_DIGEST_SECTIONS
+	}
+	return d.Sections
+}
+
+// Saves a new or changed digest and schedules it
+func saveDigest(digest Digest, s *Skippy) (Digest, error) {
+	if digest.Name == "" {
+		digest.Name = DEFAULT_DIGEST_NAME
+	}
+	if digest.Days == "" {
+		digest.Days = DIGEST_EVERY_DAY
+	}
+
+	atTime, err := ParseCommonTime(digest.Time)
+	if err != nil {
+		return digest, err
+	}
+	digest.Time = atTime.Format("15:04")
+
+	digest.Sections, err = parseDigestSections(digest.Sections)
+	if err != nil {
+		return digest, err
+	}
+
+	if digest.FeedURL != "" {
+		if err := checkPublicURL(context.Background(), digest.FeedURL, s.Config); err != nil {
+			return digest, err
+		}
+	}
+
+	if digest.Timezone == "" {
+		digest.Timezone = s.Config.Timezone
+	}
+	if _, err := getLocation(digest.Timezone, s.Config); err != nil {
+		return digest, fmt.Errorf("unknown timezone %s", digest.Timezone)
+	}
+	// check the schedule before saving so a bad edit does not replace a working digest
+	if _, err := cron.ParseStandard(makeDigestCrontab(digest, atTime)); err != nil {
+		return digest, fmt.Errorf("invalid days %s", digest.Days)
+	}
+
+	if digest.ID == 0 {
+		err = s.DB.CreateDigest(&digest)
+	} else {
+		err = s.DB.UpdateDigest(&digest)
+	}
+	if err != nil {
+		return digest, err
+	}
+
+	return scheduleDigest(digest, s)
+}
+
+// Schedules a digest replacing its current job
+func scheduleDigest(digest Digest, s *Skippy) (Digest, error) {
+	s.Scheduler.CancelDigestJob(digest.ID)
+
+	atTime, err := ParseCommonTime(digest.Time)
+	if err != nil {
+		return digest, err
+	}
+	crontab := makeDigestCrontab(digest, atTime)
+
+	log.Printf("scheduling digest %d on %s with %s\n", digest.ID, digest.ChannelID, crontab)
+	nextRun, err := s.Scheduler.AddDigestJob(digest.ID, crontab, func() {
+		SendDigest(digest.ID, s)
+	})
+	if err != nil {
+		return digest, fmt.Errorf("unable to schedule digest on days %s: %w", digest.Days, err)
+	}
+
+	digest.NextRun = nextRun
+	return digest, s.DB.UpdateDigest(&digest)
+}
+
+func makeDigestCrontab(digest Digest, atTime time.Time) string {
+	return makeCrontab(
+		fmt.Sprintf("%d %d * * %s", atTime.Minute(), atTime.Hour(), digest.Days),
+		digest.Timezone,
+	)
+}
+
+// Sends the digest and updates when it will run next
+func SendDigest(id uint, s *Skippy) {
+	digest, err := s.DB.GetDigest(id)
+	if err != nil {
+		log.Printf("unable to get digest %d: %s\n", id, err)
+		return
+	}
+
+	// the message is sent long after the request has finished
+	sendMorningMsg(context.Background(), *digest, s)
+
+	if nextRun, ok := s.Scheduler.NextDigestRun(id); ok {
+		digest.NextRun = nextRun
+		if err := s.DB.UpdateDigest(digest); err != nil {
+			log.Printf("unable to update digest %d: %s\n", id, err)
+		}
+	}
+}
+
+func deleteDigest(id uint, s *Skippy) error {
+	s.Scheduler.CancelDigestJob(id)
+	return s.DB.DeleteDigest(id)
+}
+
+// Finds a digest in the channel by name. If name is empty the channel must only have one digest.
+// Returns the channel's digests when one could not be found
+func findChannelDigest(channelID string, name string, s *Skippy) (*Digest, []Digest, error) {
+	digests, err := s.DB.GetDigestsByChannel(channelID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if name == "" && len(digests) == 1 {
+		return &digests[0], digests, nil
+	}
+	for _, digest := range digests {
+		if strings.EqualFold(digest.Name, name) {
+			return &digest, digests, nil
+		}
+	}
+	return nil, digests, nil
+}
+
+// Reschedules the saved digests when the bot starts
+func loadDigests(s *Skippy) {
+	digests, err := s.DB.GetAllDigests()
+	if err != nil {
+		log.Println("unable to load digests: ", err)
+		return
+	}
+
+	for _, digest := range digests {
+		if _, err := scheduleDigest(digest, s); err != nil {
+			log.Printf("unable to schedule digest %d: %s\n", digest.ID, err)
+		}
+	}
+}
+
+func formatDigestDays(days string) string {
+	switch days {
+	case DIGEST_EVERY_DAY:
+		return "every day"
+	case DIGEST_WEEKDAYS:
+		return "weekdays"
+	case DIGEST_WEEKENDS:
+		return "weekends"
+	default:
+		return "days `" + days + "`"
+	}
+}
+
+func formatDigest(digest Digest) string {
+	timezone := digest.Timezone
+	if timezone == "" {
+		timezone = "local time"
+	}
+	return fmt.Sprintf(
+		"`#%d` **%s** %s at %s %s, next <t:%d:R>",
+		digest.ID,
+		digest.Name,
+		formatDigestDays(digest.Days),
+		digest.Time,
+		timezone,
+		digest.NextRun.Unix(),
+	)
+}
+
+// Formats every setting of a digest
+func formatDigestDetails(digest Digest) string {
+	lines := []string{formatDigest(digest)}
+	sections := strings.Join(digest.GetSections(), ", ")
+	if sections == "" {
+		sections = NONE
+	}
+	lines = append(lines, "**Sections:** "+sections)
+	if len(digest.WeatherLocations) > 0 {
+		lines = append(lines, "**Weather:** "+strings.Join(digest.WeatherLocations, ", "))
+	}
+	if len(digest.Stocks) > 0 {
+		lines = append(lines, "**Stocks:** "+strings.Join(digest.Stocks, ", "))
+	}
+	if digest.FeedURL != "" {
+		lines = append(lines, "**News feed:** "+digest.FeedURL)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func formatDigests(digests []Digest) string {
+	if len(digests) == 0 {
+		return "There are no digests in this channel"
+	}
+
+	var lines []string
+	for _, digest := range digests {
+		lines = append(lines, formatDigest(digest))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,377 @@
+package skippy
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	SECTION_WEATHER    = "weather"
+	SECTION_STOCKS     = "stocks"
+	SECTION_EVENTS     = "events"
+	SECTION_GAME_STATS = "game_stats"
+	SECTION_REMINDERS  = "reminders"
+	SECTION_BIRTHDAYS  = "birthdays"
+	SECTION_NEWS       = "news"
+	// headlines included from a news feed
+	MAX_DIGEST_HEADLINES = 5
+	// reminders due within this long are included in a digest
+	DIGEST_REMINDER_WINDOW = 24 * time.Hour
+)
+
+// sections of digests that have not chosen any
+var DEFAULT_DIGEST_SECTIONS = []string{SECTION_WEATHER, SECTION_STOCKS}
+
+// A part of a digest. Get returns an empty string when there is nothing to include.
+// Partial content can be returned with an error
+type DigestSection struct {
+	Title string
+	Get   func(ctx context.Context, digest Digest, s *Skippy) (string, error)
+}
+
+// digest sections by name
+var DIGEST_SECTIONS = make(map[string]DigestSection)
+
+func init() {
+	RegisterDigestSection(SECTION_WEATHER, DigestSection{Title: "Weather", Get: getWeatherSection})
+	RegisterDigestSection(SECTION_STOCKS, DigestSection{Title: "Stocks", Get: getStocksSection})
+	RegisterDigestSection(SECTION_EVENTS, DigestSection{Title: "Today's events", Get: getEventsSection})
+	RegisterDigestSection(SECTION_GAME_STATS, DigestSection{Title: "Yesterday's games", Get: getGameStatsSection})
+	RegisterDigestSection(SECTION_REMINDERS, DigestSection{Title: "Upcoming reminders", Get: getRemindersSection})
+	RegisterDigestSection(SECTION_BIRTHDAYS, DigestSection{Title: "Birthdays", Get: getBirthdaysSection})
+	RegisterDigestSection(SECTION_NEWS, DigestSection{Title: "Headlines", Get: getNewsSection})
+}
+
+// Adds a section that digests can include by name
+func RegisterDigestSection(name string, section DigestSection) {
+	DIGEST_SECTIONS[name] = section
+}
+
+// Names of the registered sections in alphabetical order
+func digestSectionNames() []string {
+	var names []string
+	for name := range DIGEST_SECTIONS {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Lowercases the section names and checks that they are registered
+func parseDigestSections(sections []string) ([]string, error) {
+	if sections == nil {
+		return nil, nil
+	}
+
+	parsed := []string{}
+	for _, section := range sections {
+		section = strings.ToLower(strings.TrimSpace(section))
+		if _, ok := DIGEST_SECTIONS[section]; !ok {
+			return nil, fmt.Errorf(
+				"unknown section %s. sections are %s",
+				section,
+				strings.Join(digestSectionNames(), ", "),
+			)
+		}
+		parsed = append(parsed, section)
+	}
+	return parsed, nil
+}
+
+// Builds the prompt for a digest from its sections in order.
+// Sections that fail are listed so they can be reported in the message
+func BuildDigestPrompt(ctx context.Context, digest Digest, s *Skippy) string {
+	prompt := fmt.Sprintf("Please tell everyone @here %s.", getDigestGreeting(digest))
+	var failed []string
+	for _, name := range digest.GetSections() {
+		section, ok := DIGEST_SECTIONS[name]
+		if !ok {
+			failed = append(failed, fmt.Sprintf("%s: section does not exist", name))
+			continue
+		}
+
+		content, err := section.Get(ctx, digest, s)
+		if err != nil {
+			log.Printf("unable to get the %s section of digest %d: %s\n", name, digest.ID, err)
+			failed = append(failed, fmt.Sprintf("%s: %s", section.Title, err))
+		}
+		if content != "" {
+			prompt += fmt.Sprintf("\n\n## %s\n%s", section.Title, content)
+		}
+	}
+
+	if len(failed) > 0 {
+		prompt += "\n\nThese sections could not be loaded. Let everyone know they are unavailable:\n- " +
+			strings.Join(failed, "\n- ")
+	}
+	return prompt
+}
+
+// Gets the greeting for the time of day the digest is sent
+func getDigestGreeting(digest Digest) string {
+	atTime, err := ParseCommonTime(digest.Time)
+	if err != nil {
+		return "hello"
+	}
+	switch hour := atTime.Hour(); {
+	case hour >= 5 && hour < 12:
+		return "good morning"
+	case hour >= 12 && hour < 17:
+		return "good afternoon"
+	default:
+		return "good evening"
+	}
+}
+
+func getWeatherSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	var lines []string
+	var errs []error
+	for _, location := range digest.WeatherLocations {
+		weather, err := getWeather(ctx, location, s.Config)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", location, err))
+			continue
+		}
+		lines = append(lines, location+": "+weather)
+	}
+	return strings.Join(lines, "\n"), errors.Join(errs...)
+}
+
+func getStocksSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	var lines []string
+	var errs []error
+	for _, stock := range digest.Stocks {
+		result := getStockResult(ctx, stock, 0, s.Config)
+		if result.Error != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", stock, result.Error))
+			continue
+		}
+		quote, err := json.Marshal(result)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", stock, err))
+			continue
+		}
+		lines = append(lines, string(quote))
+	}
+	return strings.Join(lines, "\n"), errors.Join(errs...)
+}
+
+func getEventsSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	start, end, err := getDigestDay(digest, 0, s)
+	if err != nil {
+		return "", err
+	}
+
+	events, err := s.DiscordSession.GuildScheduledEvents(digest.GuildID, false)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, event := range events {
+		if event.Status != discordgo.GuildScheduledEventStatusScheduled &&
+			event.Status != discordgo.GuildScheduledEventStatusActive {
+			continue
+		}
+		if event.ScheduledStartTime.Before(start) || !event.ScheduledStartTime.Before(end) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(
+			"- %s at %s",
+			event.Name,
+			event.ScheduledStartTime.In(start.Location()).Format(time.Kitchen),
+		))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func getGameStatsSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	start, end, err := getDigestDay(digest, -1, s)
+	if err != nil {
+		return "", err
+	}
+
+	guild, err := s.DiscordSession.GetState().Guild(digest.GuildID)
+	if err != nil {
+		return "", fmt.Errorf("unable to get the server members: %w", err)
+	}
+	usernames := make(map[string]string)
+	var userIDs []string
+	for _, member := range guild.Members {
+		usernames[member.User.ID] = member.User.Username
+		userIDs = append(userIDs, member.User.ID)
+	}
+
+	sessions, err := s.DB.GetGameSessionsBetween(userIDs, start, end)
+	if err != nil {
+		return "", err
+	}
+
+	type userGame struct {
+		UserID string
+		Game   string
+	}
+	played := make(map[userGame]time.Duration)
+	for _, session := range sessions {
+		played[userGame{session.UserID, session.Game}] += session.Duration
+	}
+	var userGames []userGame
+	for userGame := range played {
+		userGames = append(userGames, userGame)
+	}
+	sort.Slice(userGames, func(i, j int) bool {
+		return played[userGames[i]] > played[userGames[j]]
+	})
+
+	var lines []string
+	for _, userGame := range userGames {
+		lines = append(lines, fmt.Sprintf(
+			"- %s played %s for %s",
+			usernames[userGame.UserID],
+			userGame.Game,
+			played[userGame].Round(time.Minute),
+		))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func getRemindersSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	location, err := getLocation(digest.Timezone, s.Config)
+	if err != nil {
+		return "", err
+	}
+
+	reminders, err := s.DB.GetRemindersByChannel(digest.ChannelID)
+	if err != nil {
+		return "", err
+	}
+
+	cutoff := time.Now().Add(DIGEST_REMINDER_WINDOW)
+	var lines []string
+	for _, reminder := range reminders {
+		if reminder.Sent || reminder.RemindAt.After(cutoff) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf(
+			"- %s: %s",
+			reminder.RemindAt.In(location).Format(time.Kitchen),
+			reminder.Message,
+		))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func getBirthdaysSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	today, _, err := getDigestDay(digest, 0, s)
+	if err != nil {
+		return "", err
+	}
+
+	birthdays, err := getBirthdaysOn(digest.GuildID, today, s)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, birthday := range birthdays {
+		lines = append(lines, fmt.Sprintf("- it is %s's birthday", UserMention(birthday.UserID)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func getNewsSection(ctx context.Context, digest Digest, s *Skippy) (string, error) {
+	feedURL := digest.FeedURL
+	client := apiClient
+	if feedURL == "" {
+		feedURL = s.Config.NewsFeedURL
+	} else if err := checkPublicURL(ctx, feedURL, s.Config); err != nil {
+		// checked again since the address of the host can change after the digest is saved
+		return "", err
+	} else {
+		client = GetPublicClient(s.Config)
+	}
+	if feedURL == "" {
+		return "", fmt.Errorf("no news feed is configured")
+	}
+
+	headlines, err := getHeadlines(ctx, client, feedURL)
+	if err != nil {
+		return "", err
+	}
+
+	var lines []string
+	for _, headline := range headlines {
+		lines = append(lines, fmt.Sprintf("- %s %s", headline.Title, headline.Link.Get()))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// Gets the start and end of a day relative to today in the digest's timezone
+func getDigestDay(digest Digest, days int, s *Skippy) (time.Time, time.Time, error) {
+	location, err := getLocation(digest.Timezone, s.Config)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	now := time.Now().In(location)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, location).AddDate(0, 0, days)
+	return start, start.AddDate(0, 0, 1), nil
+}
+
+// An RSS channel or an Atom feed
+type NewsFeed struct {
+	// rss items are inside of a channel
+	Channel *NewsFeed  `xml:"channel"`
+	Items   []NewsItem `xml:"item"`
+	Entries []NewsItem `xml:"entry"`
+}
+
+type NewsItem struct {
+	Title string   `xml:"title"`
+	Link  NewsLink `xml:"link"`
+}
+
+// rss links are text and atom links are in the href attribute
+type NewsLink struct {
+	Href string `xml:"href,attr"`
+	Text string `xml:",chardata"`
+}
+
+func (l NewsLink) Get() string {
+	if l.Href != "" {
+		return l.Href
+	}
+	return strings.TrimSpace(l.Text)
+}
+
+// Gets the latest headlines from an RSS or Atom feed
+func getHeadlines(ctx context.Context, client *http.Client, feedURL string) ([]NewsItem, error) {
+	body, err := cachedClientGet(ctx, client, NEWS_FEED_ENDPOINT, feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var feed NewsFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("unable to read the news feed: %w", err)
+	}
+
+	items := feed.Entries
+	if feed.Channel != nil {
+		items = feed.Channel.Items
+	}
+	if len(items) > MAX_DIGEST_HEADLINES {
+		items = items[:MAX_DIGEST_HEADLINES]
+	}
+	return items, nil
+}
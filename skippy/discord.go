@@ -2,6 +2,8 @@ package skippy
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 
 	"github.com/bwmarrin/discordgo"
@@ -9,8 +11,11 @@ import (
 
 // TODO: rename file
 const (
-	ERROR_RESPONSE   = "Oh no! Something went wrong."
-	EVERYONE_MENTION = "@everyone"
+	ERROR_RESPONSE     = "Oh no! Something went wrong."
+	EVERYONE_MENTION   = "@everyone"
+	MAX_REPLY_DEPTH    = 3
+	REPLY_CHAIN_HEADER = "[The user is replying to the following messages, oldest first:"
+	REPLY_CHAIN_FOOTER = "End of replied messages. The user's message follows.]"
 )
 
 func sendChunkedChannelMessage(
@@ -51,24 +56,33 @@ func OnMessageCreate(m *discordgo.MessageCreate, s *Skippy) {
 		return
 	}
 
-	if threadExists && thread.awaitsResponse {
-		getAndSendResponse(
-			context.Background(),
-			s,
-			ResponseReq{
-				ChannelID: m.ChannelID,
-				UserID:    m.Author.ID,
-				Message:   m.Content,
-			},
-		)
-		// value used by reminders to see if it needs to send another message to user
-		s.State.SetAwaitsResponse(m.ChannelID, false, s.AIClient)
-		s.Scheduler.CancelReminderJob(m.ChannelID)
+	var roles []string
+	if m.Member != nil {
+		roles = m.Member.Roles
+	}
+
+	// the user the reminder is for replying to it acknowledges it.
+	// the message is otherwise handled like any other message
+	if m.MessageReference != nil {
+		reminders, err := s.DB.GetPendingReminders(m.ChannelID, m.Author.ID)
+		if err != nil {
+			log.Println("unable to get pending reminders: ", err)
+		}
+		for _, reminder := range reminders {
+			if reminder.MessageID != "" && reminder.MessageID == m.MessageReference.MessageID {
+				ackReminder(reminder, s)
+			}
+		}
 	}
 
 	role, roleMentioned := isRoleMentioned(s.DiscordSession, m)
 
-	isMentioned := isMentioned(m.Mentions, s.DiscordSession.GetState().User) || roleMentioned
+	replyChain := getReplyChain(m.Message, s)
+	// replying directly to the bot counts as a mention
+	isReplyToBot := len(replyChain) > 0 && replyChain[0].Author != nil &&
+		replyChain[0].Author.ID == s.DiscordSession.GetState().User.ID
+
+	isMentioned := isMentioned(m.Mentions, s.DiscordSession.GetState().User) || roleMentioned || isReplyToBot
 	alwaysRespond := threadExists && thread.alwaysRespond
 	if !isMentioned && !alwaysRespond {
 		return
@@ -78,19 +92,135 @@ func OnMessageCreate(m *discordgo.MessageCreate, s *Skippy) {
 	message = removeRoleMention(message, role)
 	// TODO: why did I have this here?
 	message = replaceChannelIDs(message, m.MentionChannels)
+	userMessage := message
+
+	if len(replyChain) > 0 {
+		message = formatReplyChain(replyChain, s) + "\n" + message
+	}
+
+	images, notes := getMessageImages(m.Message, s.Config)
+	var replyImages []ImageInput
+	if len(replyChain) > 0 {
+		replyImages, _ = getMessageImages(replyChain[0], s.Config)
+	}
+	if note := makeReplyImagesNote(replyImages); note != "" {
+		notes = append(notes, note)
+	}
+	for _, note := range notes {
+		message += "\n" + note
+	}
 
 	log.Println("using message: ", message)
 
-	log.Println("CHANELLID: ", m.ChannelID)
-	err := getAndSendResponse(context.Background(), s, ResponseReq{
-		ChannelID: m.ChannelID,
-		UserID:    m.Author.ID,
-		Message:   message,
+	channelID := m.ChannelID
+	threadID := GetThreadID(m.ChannelID, m.Author.ID, s)
+	if discordChannelID, discordThreadID, ok := maybeStartDiscordThread(m.Message, threadID, s); ok {
+		channelID = discordChannelID
+		threadID = discordThreadID
+	}
+
+	log.Println("CHANELLID: ", channelID)
+	req := ResponseReq{
+		GuildID:     m.GuildID,
+		ChannelID:   channelID,
+		ThreadID:    threadID,
+		UserID:      m.Author.ID,
+		Message:     message,
+		UserMessage: userMessage,
+		Images:      images,
+		ReplyImages: replyImages,
+		Attachments: m.Attachments,
+	}
+
+	// messages sent in a burst are combined into a single request
+	s.Coalescer.Add(threadID+"|"+m.Author.ID, req, func(req ResponseReq) {
+		if err := sendRateLimitedResponse(context.Background(), s, roles, req); err != nil {
+			log.Println(err)
+		}
 	})
-	if err != nil {
-		log.Println(err)
-		return
+}
+
+// Checks the rate limits before getting and sending a response.
+// If a limit is exceeded a cooldown message is sent instead
+func sendRateLimitedResponse(
+	ctx context.Context,
+	s *Skippy,
+	roles []string,
+	req ResponseReq,
+) error {
+	key, wait, ok := checkRateLimit(req, roles, s)
+	if !ok {
+		log.Printf("rate limit exceeded for %s, available in %s\n", key, wait)
+		if s.RateLimiter.ShouldNotify(key, wait) {
+			return sendChunkedChannelMessage(s.DiscordSession, req.ChannelID, makeCooldownResponse(req.UserID, wait, s))
+		}
+		return nil
+	}
+
+	return getAndSendResponse(ctx, s, req)
+}
+
+// Checks the rate limits for a slash command that gets a response.
+// The cooldown is sent as the interaction response when the member is limited
+func checkInteractionRateLimit(i *discordgo.InteractionCreate, req ResponseReq, s *Skippy) (bool, error) {
+	key, wait, ok := checkRateLimit(req, i.Member.Roles, s)
+	if ok {
+		return true, nil
+	}
+
+	log.Printf("rate limit exceeded for %s, available in %s\n", key, wait)
+	return false, s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: makeCooldownResponse(req.UserID, wait, s),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+// Gets the messages that m is replying to, starting with the message directly replied to.
+// Follows the chain up to Config.MaxReplyDepth messages
+func getReplyChain(m *discordgo.Message, s *Skippy) []*discordgo.Message {
+	var chain []*discordgo.Message
+
+	curr := m
+	for len(chain) < s.Config.MaxReplyDepth && curr.MessageReference != nil {
+		ref := curr.ReferencedMessage
+		if ref == nil {
+			channelID := curr.MessageReference.ChannelID
+			if channelID == "" {
+				channelID = curr.ChannelID
+			}
+			var err error
+			ref, err = s.DiscordSession.ChannelMessage(channelID, curr.MessageReference.MessageID)
+			if err != nil {
+				log.Println("unable to get referenced message: ", err)
+				break
+			}
+		}
+		chain = append(chain, ref)
+		curr = ref
+	}
+
+	return chain
+}
+
+// Formats a reply chain as context for the model with the oldest message first
+func formatReplyChain(chain []*discordgo.Message, s *Skippy) string {
+	botID := s.DiscordSession.GetState().User.ID
+	content := REPLY_CHAIN_HEADER
+	for i := len(chain) - 1; i >= 0; i-- {
+		msg := chain[i]
+		author := "unknown"
+		if msg.Author != nil && msg.Author.ID == botID {
+			author = fmt.Sprintf("%s (you)", s.Config.Name)
+		} else if msg.Author != nil {
+			author = UserMention(msg.Author.ID)
+		}
+		content += fmt.Sprintf("\n%s: %s", author, removeBotMention(msg.Content, botID))
 	}
+	return content + "\n" + REPLY_CHAIN_FOOTER
 }
 
 // Gets response from ai disables functions calls.
@@ -109,7 +239,17 @@ func getAndSendResponse(
 		s,
 		req,
 	)
-	if err != nil {
+	if errors.Is(err, ErrBudgetExceeded) {
+		response = fmt.Sprintf(BUDGET_EXCEEDED_RESPONSE, s.Config.Name)
+	} else if errors.Is(err, ErrContentFlagged) {
+		response = makeModerationResponse(s)
+	} else if isRateLimitError(err) {
+		log.Println("Rate limited getting response: ", err)
+		response = RATE_LIMITED_RESPONSE
+	} else if isOutageError(err) {
+		log.Println("Outage getting response: ", err)
+		response = OUTAGE_RESPONSE
+	} else if err != nil {
 		log.Println("Unable to get response: ", err)
 		response = ERROR_RESPONSE
 	}
@@ -1,131 +1,11 @@
 package skippy
 
-import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"log"
-	"net/http"
-	"net/url"
-)
-
-// https://www.alphavantage.co/documentation/
-type GlobalQuote struct {
-	Symbol           string `json:"01. symbol"`
-	Open             string `json:"02. open"`
-	High             string `json:"03. high"`
-	Low              string `json:"04. low"`
-	Price            string `json:"05. price"`
-	Volume           string `json:"06. volume"`
-	LatestTradingDay string `json:"07. latest trading day"`
-	PreviousClose    string `json:"08. previous close"`
-	Change           string `json:"09. change"`
-	ChangePercent    string `json:"10. change percent"`
-}
-
-type ApiResponse struct {
-	GlobalQuote GlobalQuote `json:"Global Quote"`
-}
-
-func getStockPrice(symbol string, apiKey string) (string, error) {
-	baseURL := "https://www.alphavantage.co/query"
-
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		fmt.Println("Error parsing URL:", err)
-		return "", err
-	}
-
-	q := u.Query()
-	q.Set("function", "GLOBAL_QUOTE")
-	q.Set("symbol", symbol)
-	q.Set("apikey", apiKey)
-
-	u.RawQuery = q.Encode()
-
-	response, err := http.Get(u.String())
-	if err != nil {
-		fmt.Println("Error making GET request:", err)
-		return "", err
-	}
-	defer response.Body.Close()
-
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return "", err
-	}
-
-	apiResponse := ApiResponse{}
-	err = json.Unmarshal(body, &apiResponse)
-	if err != nil {
-		log.Println("error unmashalling: ", string(body))
-		return "", err
-	}
-	log.Println("The price is: ", apiResponse.GlobalQuote.Price)
-	return apiResponse.GlobalQuote.Price, nil
-}
-
-func getWeather(location string, apiKey string) (string, error) {
-	baseURL := "http://api.weatherapi.com/v1/forecast.json"
-
-	u, err := url.Parse(baseURL)
-	if err != nil {
-		fmt.Println("Error parsing URL:", err)
-		return "", err
-	}
-
-	q := u.Query()
-	q.Set("key", apiKey)
-	q.Set("q", location)
-
-	u.RawQuery = q.Encode()
-
-	log.Println("Final URL:", u.String())
-
-	response, err := http.Get(u.String())
-	if err != nil {
-		fmt.Println("Error making GET request:", err)
-		return "", err
-	}
-	defer response.Body.Close()
-
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
-		return "", err
-	}
-
-	weatherData := WeatherData{}
-	err = json.Unmarshal(body, &weatherData)
-	if err != nil {
-		log.Println("error unmashalling: ", string(body))
-		return "", err
-	}
-
-	var forecastDays []ForecastDay
-	for _, forcastDay := range weatherData.Forecast.Forecastday {
-		forcastDay.Hour = []Hour{}
-		forecastDays = append(forecastDays, forcastDay)
-	}
-	// dailyForecast := weatherData.Forecast.Forecastday[0]
-
-	// dailyForecast.Hour = []Hour{}
-	jsonForecast, err := json.Marshal(forecastDays)
-	if err != nil {
-		return "", err
-	}
-	log.Println("Forecast: ", string(jsonForecast))
-	return fmt.Sprintf(
-		"here is the json data only comment on the temperature in freedom units, precipitation, and condition: %s. Only comment on the requested day. If no day was specified by the user only give the details for today",
-		string(jsonForecast),
-	), nil
-}
-
+// https://www.weatherapi.com/docs/
 type WeatherData struct {
 	Location Location `json:"location"`
 	Current  Current  `json:"current"`
 	Forecast Forecast `json:"forecast"`
+	Alerts   Alerts   `json:"alerts"`
 }
 
 type Location struct {
@@ -175,6 +55,9 @@ type Current struct {
 	UV               float64   `json:"uv"`
 	GustMph          float64   `json:"gust_mph"`
 	GustKph          float64   `json:"gust_kph"`
+
+	// only included when aqi=yes
+	AirQuality *AirQuality `json:"air_quality"`
 }
 
 type DayCondition struct {
@@ -271,3 +154,35 @@ type ForecastDay struct {
 type Forecast struct {
 	Forecastday []ForecastDay `json:"forecastday"`
 }
+
+type AirQuality struct {
+	CO           float64 `json:"co"`
+	NO2          float64 `json:"no2"`
+	O3           float64 `json:"o3"`
+	SO2          float64 `json:"so2"`
+	PM2_5        float64 `json:"pm2_5"`
+	PM10         float64 `json:"pm10"`
+	USEPAIndex   int     `json:"us-epa-index"`
+	GBDefraIndex int     `json:"gb-defra-index"`
+}
+
+// only included when alerts=yes
+type Alerts struct {
+	Alert []Alert `json:"alert"`
+}
+
+type Alert struct {
+	Headline    string `json:"headline"`
+	MsgType     string `json:"msgtype"`
+	Severity    string `json:"severity"`
+	Urgency     string `json:"urgency"`
+	Areas       string `json:"areas"`
+	Category    string `json:"category"`
+	Certainty   string `json:"certainty"`
+	Event       string `json:"event"`
+	Note        string `json:"note"`
+	Effective   string `json:"effective"`
+	Expires     string `json:"expires"`
+	Desc        string `json:"desc"`
+	Instruction string `json:"instruction"`
+}
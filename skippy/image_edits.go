@@ -0,0 +1,215 @@
+package skippy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	// the only model with the edit and variation endpoints
+	IMAGE_EDIT_MODEL = openai.CreateImageModelDallE2
+	// largest side of an image sent to be edited. keeps the png under the 4MB limit
+	MAX_EDIT_IMAGE_SIDE  = 1000
+	NO_EDIT_IMAGE_OUTPUT = "there is no image in the user's message or the message they replied to. ask them to attach one"
+)
+
+// Finds an image by name in the images from the user's message and the message they replied to.
+// The first image that is not skipped is used if name is empty
+func findToolImage(images []ImageInput, name string, skip string) (ImageInput, error) {
+	if len(images) == 0 {
+		return ImageInput{}, errors.New(NO_EDIT_IMAGE_OUTPUT)
+	}
+
+	var names []string
+	for _, image := range images {
+		if name == "" && image.Name != skip || name != "" && strings.EqualFold(image.Name, name) {
+			return image, nil
+		}
+		names = append(names, image.Name)
+	}
+	if name == "" {
+		return ImageInput{}, errors.New(NO_EDIT_IMAGE_OUTPUT)
+	}
+	return ImageInput{}, fmt.Errorf("there is no image named %s. images are %s", name, strings.Join(names, ", "))
+}
+
+// Downloads an image and makes it a square png so it can be sent to the edit endpoints.
+// Images can come from urls in the message so they are checked like any other url a user gives
+func getEditImage(ctx context.Context, input ImageInput, s *Skippy) (*image.NRGBA, error) {
+	if err := checkPublicURL(ctx, input.URL, s.Config); err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", input.Name, err)
+	}
+	data, err := downloadAttachment(ctx, GetPublicClient(s.Config), input.URL, s.Config.MaxImageSize)
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", input.Name, err)
+	}
+
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s. images must be png, jpeg or gif: %w", input.Name, err)
+	}
+	log.Printf("editing %s image %s with size %s\n", format, input.Name, img.Bounds().Size())
+	return squareImage(img, MAX_EDIT_IMAGE_SIDE), nil
+}
+
+// Crops the center square of an image and scales it down to at most maxSide
+func squareImage(img image.Image, maxSide int) *image.NRGBA {
+	bounds := img.Bounds()
+	return scaleSquare(img, min(bounds.Dx(), bounds.Dy(), maxSide))
+}
+
+// Crops the center square of an image and scales it up or down to size
+func scaleSquare(img image.Image, size int) *image.NRGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	crop := image.Rect(0, 0, side, side).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-side)/2,
+		bounds.Min.Y+(bounds.Dy()-side)/2,
+	))
+
+	square := image.NewNRGBA(image.Rect(0, 0, size, size))
+	// nearest neighbor is good enough since the model redraws the image
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			square.Set(x, y, img.At(crop.Min.X+x*side/size, crop.Min.Y+y*side/size))
+		}
+	}
+	return square
+}
+
+// Makes a mask the size of img. The transparent areas of the image are edited
+// or the whole image if it does not have any
+func makeEditMask(img *image.NRGBA) *image.NRGBA {
+	for i := 3; i < len(img.Pix); i += 4 {
+		if img.Pix[i] < 0xff {
+			return nil
+		}
+	}
+	mask := image.NewNRGBA(img.Bounds())
+	draw.Draw(mask, mask.Bounds(), image.NewUniform(color.Transparent), image.Point{}, draw.Src)
+	return mask
+}
+
+// Writes the image to a temporary png file since the openai client uploads files.
+// The file should be closed and removed with removeTempImage
+func writeTempImage(img image.Image) (*os.File, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	file, err := os.CreateTemp("", "skippy-*.png")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		removeTempImage(file)
+		return nil, err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		removeTempImage(file)
+		return nil, err
+	}
+	return file, nil
+}
+
+func removeTempImage(file *os.File) {
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		log.Println("unable to remove temporary image: ", err)
+	}
+}
+
+func checkEditImageSize(size string) (string, error) {
+	if size == "" {
+		return DEFAULT_IMAGE_SIZE, nil
+	}
+	sizes := IMAGE_MODEL_SIZES[IMAGE_EDIT_MODEL]
+	if !slices.Contains(sizes, size) {
+		return "", fmt.Errorf("edited images can only be %s", strings.Join(sizes, ", "))
+	}
+	return size, nil
+}
+
+// Edits an image with a prompt. If mask is nil the transparent areas of the image are edited
+func editImage(
+	ctx context.Context,
+	prompt string,
+	img *image.NRGBA,
+	mask *image.NRGBA,
+	size string,
+	client *openai.Client,
+) ([]byte, error) {
+	if mask == nil {
+		mask = makeEditMask(img)
+	} else if mask.Bounds() != img.Bounds() {
+		// the mask has to be the exact size of the image
+		mask = scaleSquare(mask, img.Bounds().Dx())
+	}
+
+	imageFile, err := writeTempImage(img)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTempImage(imageFile)
+
+	var maskFile *os.File
+	if mask != nil {
+		maskFile, err = writeTempImage(mask)
+		if err != nil {
+			return nil, err
+		}
+		defer removeTempImage(maskFile)
+	}
+
+	log.Printf("editing image with prompt: %s\n", prompt)
+	resp, err := client.CreateEditImage(ctx, openai.ImageEditRequest{
+		Image:          imageFile,
+		Mask:           maskFile,
+		Prompt:         prompt,
+		Model:          IMAGE_EDIT_MODEL,
+		N:              1,
+		Size:           size,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to edit image: %w", err)
+	}
+	edited, _, err := decodeImageResponse(resp)
+	return edited, err
+}
+
+func createImageVariation(ctx context.Context, img *image.NRGBA, size string, client *openai.Client) ([]byte, error) {
+	imageFile, err := writeTempImage(img)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTempImage(imageFile)
+
+	resp, err := client.CreateVariImage(ctx, openai.ImageVariRequest{
+		Image:          imageFile,
+		Model:          IMAGE_EDIT_MODEL,
+		N:              1,
+		Size:           size,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create image variation: %w", err)
+	}
+	variation, _, err := decodeImageResponse(resp)
+	return variation, err
+}
@@ -7,9 +7,10 @@ const (
 	Use responses of varying lengths.
 	`
 	MORNING_MESSAGE_INSTRUCTIONS = `
-	You are making creating morning wake up message for the users of a discord server. Make sure to mention @here in your message. 
-	Be creative in the message you create in wishing everyone good morning. If there is weather data included in the message please give a brief overview of the weather for each location.
+	You are creating a scheduled message for the users of a discord server. Make sure to mention @here in your message. 
+	Be creative in the message you create in greeting everyone for the time of day given in the message. If there is weather data included in the message please give a brief overview of the weather for each location.
 	if there is stock price information included in the message include that information in the message.
+	Cover each section of the message in the order it is given. If any sections could not be loaded briefly mention that they are unavailable.
 	`
 	SEND_CHANNEL_MSG_INSTRUCTIONS   = `You are generating a message to send in a discord channel. Generate a message based on the prompt.`
 	GENERATE_GAME_STAT_INSTRUCTIONS = `You are summarizing a users game sessions. 
@@ -26,6 +27,11 @@ const (
 	Messages will be sent in this thread that will contain the json results of a rocket league game.
 	Announce the overall score and commentate on the performance of the home team. Come up with creative insults on their performance, but praise high performers
 	`
+	SUMMARIZE_FILE_INSTRUCTIONS = `You are summarizing a file that a discord user attached to their message.
+	The file is too large to include in the conversation so your summary will be used in its place.
+	Keep the important details such as errors, warnings, names, values and structure. Quote short relevant lines exactly.
+	Do not respond in character, only generate the summary.
+	`
 	// TODO: FIX THE HELP MENU
 	HELP_INSTRUCTIONS = `
 		Here is a description of your functionality as a discord bot. Please use this to generate a help message describing to a user who you are and what you can do. 
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -21,6 +22,56 @@ const (
 	WHENS_GOOD        = "whens_good"
 	TRACK_GAME_USEAGE = "track_game_usage"
 	HELP              = "help"
+	RESET             = "reset"
+	CONTEXT           = "context"
+	USAGE             = "usage"
+	MODEL             = "model"
+	SCOPE             = "scope"
+	REMINDERS         = "reminders"
+	LIST              = "list"
+	CANCEL            = "cancel"
+	EDIT              = "edit"
+	SNOOZE            = "snooze"
+	ID                = "id"
+	MINUTES           = "minutes"
+	FROM_OTHERS       = "from_others"
+	ALLOW             = "allow"
+	DIGEST            = "digest"
+	VIEW              = "view"
+	CREATE            = "create"
+	DELETE            = "delete"
+	NAME              = "name"
+	TIME              = "time"
+	TIMEZONE          = "timezone"
+	WEATHER           = "weather"
+	STOCKS            = "stocks"
+	SECTIONS          = "sections"
+	FEED              = "feed"
+	BIRTHDAY          = "birthday"
+	SET               = "set"
+	REMOVE            = "remove"
+	MONTH             = "month"
+	DAY               = "day"
+	WEATHER_UNITS     = "weather_units"
+	UNITS             = "units"
+	WEATHER_WATCH     = "weather_watch"
+	ADD               = "add"
+	LOCATION          = "location"
+	MODERATION        = "moderation"
+	STRICTNESS        = "strictness"
+	LOG_CHANNEL       = "log_channel"
+	CLEAR_LOG         = "clear_log"
+	RATE_LIMITS        = "rate_limits"
+	USER_PER_MINUTE    = "user_per_minute"
+	USER_BURST         = "user_burst"
+	CHANNEL_PER_MINUTE = "channel_per_minute"
+	CHANNEL_BURST      = "channel_burst"
+	BUDGET             = "budget"
+	DAILY_BUDGET       = "daily_budget"
+	TOOLS              = "tools"
+	DISABLE            = "disable"
+	// clears a list option
+	NONE = "none"
 	CHANNEL           = "channel"
 	MESSAGE           = "message"
 	MENTION           = "mention"
@@ -32,6 +83,10 @@ const (
 )
 
 func initSlashCommands(s *Skippy) ([]*discordgo.ApplicationCommand, error) {
+	minBirthdayValue := 1.0
+	minRateLimitValue := 0.0
+	minUsageDays := 1.0
+	minBudgetValue := 0.0
 	commands := []*discordgo.ApplicationCommand{
 		{
 			Name:        TRACK_GAME_USEAGE,
@@ -113,6 +168,394 @@ func initSlashCommands(s *Skippy) ([]*discordgo.ApplicationCommand, error) {
 			Name:        HELP,
 			Description: fmt.Sprintf("see what %s can do", s.Config.Name),
 		},
+		{
+			Name:        RESET,
+			Description: fmt.Sprintf("Clear %s's memory of the conversation", s.Config.Name),
+		},
+		{
+			Name:        USAGE,
+			Description: fmt.Sprintf("See how much %s has cost this server. Only your own usage without Manage Server", s.Config.Name),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        DAYS,
+					Description: fmt.Sprintf("Number of days to get usage for. Defaults to %d.", DEFAULT_USAGE_DAYS),
+					Required:    false,
+					MinValue:    &minUsageDays,
+				},
+			},
+		},
+		{
+			Name:        BUDGET,
+			Description: fmt.Sprintf("Set how much %s can spend a day in this server. Requires Manage Server", s.Config.Name),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionNumber,
+					Name:        DAILY_BUDGET,
+					Description: "Daily spending limit in dollars. 0 for no limit",
+					Required:    false,
+					MinValue:    &minBudgetValue,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        RESET,
+					Description: "Go back to the default budget",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        CONTEXT,
+			Description: fmt.Sprintf("See what %s remembers about the conversation", s.Config.Name),
+		},
+		{
+			Name:        REMINDERS,
+			Description: "Manage your reminders",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        LIST,
+					Description: "List your reminders",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        CANCEL,
+					Description: "Cancel a reminder",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The reminder id from /reminders list",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        EDIT,
+					Description: "Change the message or time of a reminder",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The reminder id from /reminders list",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        MESSAGE,
+							Description: "The new reminder message",
+							Required:    false,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        MINUTES,
+							Description: "Send the reminder this many minutes from now",
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        SNOOZE,
+					Description: "Snooze a reminder",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The reminder id from /reminders list",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        MINUTES,
+							Description: fmt.Sprintf("Number of minutes to snooze for. Defaults to %d", DEFAULT_SNOOZE_MINUTES),
+							Required:    false,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        FROM_OTHERS,
+					Description: "Allow or stop other people from setting reminders for you",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionBoolean,
+							Name:        ALLOW,
+							Description: "Turning this off cancels the reminders other people set for you",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        DIGEST,
+			Description: "Manage the scheduled digests in this channel. Requires Manage Server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        LIST,
+					Description: "List the digests in this channel",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        VIEW,
+					Description: "See the settings of a digest",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The digest id from /digest list",
+							Required:    true,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        CREATE,
+					Description: "Schedule a new digest in this channel",
+					Options:     makeDigestOptions(true),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        EDIT,
+					Description: "Change a digest",
+					Options: append([]*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The digest id from /digest list",
+							Required:    true,
+						},
+					}, makeDigestOptions(false)...),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        DELETE,
+					Description: "Stop sending a digest",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The digest id from /digest list",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        BIRTHDAY,
+			Description: "Set your birthday to be celebrated in this server's digests",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        SET,
+					Description: "Set your birthday",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        MONTH,
+							Description: "Month of your birthday from 1 to 12",
+							Required:    true,
+							MinValue:    &minBirthdayValue,
+							MaxValue:    12,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        DAY,
+							Description: "Day of the month of your birthday",
+							Required:    true,
+							MinValue:    &minBirthdayValue,
+							MaxValue:    31,
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        REMOVE,
+					Description: "Remove your birthday",
+				},
+			},
+		},
+		{
+			Name:        WEATHER_UNITS,
+			Description: "Set the units the weather is given to you in",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        UNITS,
+					Description: "Units for temperature, wind and precipitation",
+					Required:    true,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: "Imperial (°F, mph, in)", Value: WEATHER_UNITS_IMPERIAL},
+						{Name: "Metric (°C, kph, mm)", Value: WEATHER_UNITS_METRIC},
+					},
+				},
+			},
+		},
+		{
+			Name:        WEATHER_WATCH,
+			Description: "Get severe weather alerts, freezing temperatures and heavy rain posted in this channel",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        ADD,
+					Description: "Start watching the weather in a location",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        LOCATION,
+							Description: "City, zip code or coordinates. ex: Portland, OR",
+							Required:    true,
+						},
+						{
+							Type:        discordgo.ApplicationCommandOptionString,
+							Name:        UNITS,
+							Description: "Units for temperature and rain. Defaults to imperial",
+							Required:    false,
+							Choices: []*discordgo.ApplicationCommandOptionChoice{
+								{Name: "Imperial (°F, in)", Value: WEATHER_UNITS_IMPERIAL},
+								{Name: "Metric (°C, mm)", Value: WEATHER_UNITS_METRIC},
+							},
+						},
+					},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        LIST,
+					Description: "List the locations this channel is watching",
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionSubCommand,
+					Name:        REMOVE,
+					Description: "Stop watching a location",
+					Options: []*discordgo.ApplicationCommandOption{
+						{
+							Type:        discordgo.ApplicationCommandOptionInteger,
+							Name:        ID,
+							Description: "The watch id from /weather_watch list",
+							Required:    true,
+						},
+					},
+				},
+			},
+		},
+		{
+			Name:        MODEL,
+			Description: fmt.Sprintf("Choose the model %s uses. Requires Manage Server", s.Config.Name),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        MODEL,
+					Description: "The model to use",
+					Required:    true,
+					Choices:     makeModelChoices(s.Config.AllowedModels),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        SCOPE,
+					Description: "Set the model for this channel or the whole server. Defaults to this channel",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: MODEL_SCOPE_CHANNEL, Value: MODEL_SCOPE_CHANNEL},
+						{Name: MODEL_SCOPE_GUILD, Value: MODEL_SCOPE_GUILD},
+					},
+				},
+			},
+		},
+		{
+			Name:        MODERATION,
+			Description: "Check messages, image prompts and responses for harmful content. Requires Manage Server",
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        STRICTNESS,
+					Description: "How strict moderation is. Higher flags more content",
+					Required:    false,
+					Choices: []*discordgo.ApplicationCommandOptionChoice{
+						{Name: MODERATION_OFF, Value: MODERATION_OFF},
+						{Name: MODERATION_LOW, Value: MODERATION_LOW},
+						{Name: MODERATION_MEDIUM, Value: MODERATION_MEDIUM},
+						{Name: MODERATION_HIGH, Value: MODERATION_HIGH},
+					},
+				},
+				{
+					Type:         discordgo.ApplicationCommandOptionChannel,
+					Name:         LOG_CHANNEL,
+					Description:  "Channel flagged content is logged to",
+					Required:     false,
+					ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        CLEAR_LOG,
+					Description: "Stop logging flagged content",
+					Required:    false,
+				},
+			},
+		},
+		{
+			Name:        TOOLS,
+			Description: fmt.Sprintf("Turn the things %s can do on or off in this server. Requires Manage Server", s.Config.Name),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        ENABLE,
+					Description: "Tool to turn on",
+					Required:    false,
+					Choices:     makeToolChoices(ALL_TOOLS),
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionString,
+					Name:        DISABLE,
+					Description: "Tool to turn off",
+					Required:    false,
+					Choices:     makeToolChoices(ALL_TOOLS),
+				},
+			},
+		},
+		{
+			Name:        RATE_LIMITS,
+			Description: fmt.Sprintf("Set how often %s responds to each user and channel in this server. Requires Manage Server", s.Config.Name),
+			Options: []*discordgo.ApplicationCommandOption{
+				{
+					Type:        discordgo.ApplicationCommandOptionNumber,
+					Name:        USER_PER_MINUTE,
+					Description: "Messages a minute each user can send. 0 for no limit",
+					Required:    false,
+					MinValue:    &minRateLimitValue,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        USER_BURST,
+					Description: "Messages each user can send at once",
+					Required:    false,
+					MinValue:    &minRateLimitValue,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionNumber,
+					Name:        CHANNEL_PER_MINUTE,
+					Description: "Messages a minute each channel can send. 0 for no limit",
+					Required:    false,
+					MinValue:    &minRateLimitValue,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionInteger,
+					Name:        CHANNEL_BURST,
+					Description: "Messages each channel can send at once",
+					Required:    false,
+					MinValue:    &minRateLimitValue,
+				},
+				{
+					Type:        discordgo.ApplicationCommandOptionBoolean,
+					Name:        RESET,
+					Description: "Go back to the default limits",
+					Required:    false,
+				},
+			},
+		},
 	}
 
 	for _, command := range commands {
@@ -148,6 +591,58 @@ func OnInteraction(i *discordgo.InteractionCreate, s *Skippy) {
 		if err := handleHelp(i, s); err != nil {
 			handleSlashCommandError(s.DiscordSession, i, err)
 		}
+	case RESET:
+		if err := handleReset(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case USAGE:
+		if err := handleUsage(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case CONTEXT:
+		if err := handleContext(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case MODEL:
+		if err := handleModel(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case REMINDERS:
+		if err := handleReminders(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case DIGEST:
+		if err := handleDigest(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case BIRTHDAY:
+		if err := handleBirthday(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case WEATHER_UNITS:
+		if err := handleWeatherUnits(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case WEATHER_WATCH:
+		if err := handleWeatherWatch(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case MODERATION:
+		if err := handleModeration(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case TOOLS:
+		if err := handleTools(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case BUDGET:
+		if err := handleBudget(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
+	case RATE_LIMITS:
+		if err := handleRateLimits(i, s); err != nil {
+			handleSlashCommandError(s.DiscordSession, i, err)
+		}
 	default:
 		log.Println("recieved unrecognized command")
 		err := s.DiscordSession.InteractionRespond(i.Interaction,
@@ -212,6 +707,18 @@ func generateGameStats(i *discordgo.InteractionCreate, s *Skippy) error {
 		content = string(jsonData)
 	}
 
+	req := ResponseReq{
+		GuildID:                i.GuildID,
+		ChannelID:              i.ChannelID,
+		UserID:                 i.Member.User.ID,
+		Message:                content,
+		AdditionalInstructions: fmt.Sprintf(GENERATE_GAME_STAT_INSTRUCTIONS, i.Member.Mention()),
+		DisableTools:           true,
+	}
+	if ok, err := checkInteractionRateLimit(i, req, s); !ok {
+		return err
+	}
+
 	// respond before sending response to maintain consistent
 	// ChannelTyping behavior
 	err = s.DiscordSession.InteractionRespond(i.Interaction,
@@ -227,17 +734,7 @@ func generateGameStats(i *discordgo.InteractionCreate, s *Skippy) error {
 		return err
 	}
 
-	go getAndSendResponse(
-		context.Background(),
-		s,
-		ResponseReq{
-			ChannelID:              i.ChannelID,
-			UserID:                 i.Member.User.ID,
-			Message:                content,
-			AdditionalInstructions: fmt.Sprintf(GENERATE_GAME_STAT_INSTRUCTIONS, i.Member.Mention()),
-			DisableTools:           true,
-		},
-	)
+	go getAndSendResponse(context.Background(), s, req)
 
 	return nil
 }
@@ -372,17 +869,20 @@ func sendChannelMessage(i *discordgo.InteractionCreate, s *Skippy) error {
 		)
 	}
 
-	go getAndSendResponse(
-		context.Background(),
-		s,
-		ResponseReq{
-			ChannelID:              channelID,
-			UserID:                 i.Member.User.ID,
-			Message:                message,
-			AdditionalInstructions: instructions,
-			DisableTools:           true,
-		},
-	)
+	req := ResponseReq{
+		GuildID:                i.GuildID,
+		ChannelID:              channelID,
+		UserID:                 i.Member.User.ID,
+		Message:                message,
+		UserMessage:            prompt,
+		AdditionalInstructions: instructions,
+		DisableTools:           true,
+	}
+	if ok, err := checkInteractionRateLimit(i, req, s); !ok {
+		return err
+	}
+
+	go getAndSendResponse(context.Background(), s, req)
 
 	err := s.DiscordSession.InteractionRespond(i.Interaction,
 		&discordgo.InteractionResponse{
@@ -453,16 +953,18 @@ func handleHelp(i *discordgo.InteractionCreate, s *Skippy) error {
 
 	instructions := fmt.Sprintf(HELP_INSTRUCTIONS, help)
 
-	go getAndSendResponse(
-		context.Background(),
-		s,
-		ResponseReq{
-			ChannelID:              i.ChannelID,
-			UserID:                 i.Member.User.ID,
-			AdditionalInstructions: instructions,
-			DisableTools:           true,
-		},
-	)
+	req := ResponseReq{
+		GuildID:                i.GuildID,
+		ChannelID:              i.ChannelID,
+		UserID:                 i.Member.User.ID,
+		AdditionalInstructions: instructions,
+		DisableTools:           true,
+	}
+	if ok, err := checkInteractionRateLimit(i, req, s); !ok {
+		return err
+	}
+
+	go getAndSendResponse(context.Background(), s, req)
 
 	return s.DiscordSession.InteractionRespond(i.Interaction,
 		&discordgo.InteractionResponse{
@@ -473,6 +975,851 @@ func handleHelp(i *discordgo.InteractionCreate, s *Skippy) error {
 		})
 }
 
+func handleReset(i *discordgo.InteractionCreate, s *Skippy) error {
+	userID, err := getInteractionUserID(i)
+	if err != nil {
+		return err
+	}
+
+	threadID := GetThreadID(i.ChannelID, userID, s)
+	// a user can always reset their own conversation but
+	// resetting a shared conversation requires manage messages
+	isShared := threadID == i.ChannelID
+	if isShared && !hasPermission(i, discordgo.PermissionManageMessages) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Messages permission to reset this channel's conversation",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	s.State.ResetThread(threadID)
+
+	message := "Reset your conversation"
+	if isShared {
+		message = "Reset the conversation for this channel"
+	}
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: message,
+			},
+		})
+}
+
+func handleContext(i *discordgo.InteractionCreate, s *Skippy) error {
+	userID, err := getInteractionUserID(i)
+	if err != nil {
+		return err
+	}
+
+	threadID := GetThreadID(i.ChannelID, userID, s)
+	messages := s.State.GetThreadMessages(threadID)
+
+	scope := s.Config.ThreadScope
+	if scope == "" {
+		scope = SCOPE_CHANNEL
+	}
+
+	content := fmt.Sprintf("**Persona:** %s\n", s.Config.Name)
+	content += fmt.Sprintf("**Model:** %s\n", getChannelModel(i.GuildID, i.ChannelID, s))
+	content += fmt.Sprintf("**Messages:** %d\n", len(messages))
+	content += fmt.Sprintf("**Estimated tokens:** %d\n", estimateTokens(messages))
+	content += fmt.Sprintf("**Conversation scope:** %s\n", scope)
+	content += fmt.Sprintf("**Always respond:** %t\n", s.State.GetAlwaysRespond(i.ChannelID))
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+// Shows the server's usage to members with Manage Server and only their own usage to everyone else
+func handleUsage(i *discordgo.InteractionCreate, s *Skippy) error {
+	days := DEFAULT_USAGE_DAYS
+	optionValue, ok := findCommandOption(
+		i.ApplicationCommandData().Options,
+		DAYS,
+	)
+	if ok {
+		days = max(int(optionValue.IntValue()), 1)
+	}
+
+	since := startOfDay(time.Now()).AddDate(0, 0, -days)
+	summaries, err := s.DB.GetUsageSummary(i.GuildID, since)
+	if err != nil {
+		log.Println("Unable to get usage: ", err)
+		return err
+	}
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		userID, err := getInteractionUserID(i)
+		if err != nil {
+			return err
+		}
+		summaries = slices.DeleteFunc(summaries, func(summary UsageSummary) bool {
+			return summary.UserID != userID
+		})
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: formatUsageSummary(summaries, days),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+func handleModel(i *discordgo.InteractionCreate, s *Skippy) error {
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Server permission to change the model",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	optionValue, ok := findCommandOption(
+		i.ApplicationCommandData().Options,
+		MODEL,
+	)
+	if !ok {
+		return fmt.Errorf("unable to find slash command option %s", MODEL)
+	}
+	model := optionValue.StringValue()
+
+	if !slices.Contains(s.Config.AllowedModels, model) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: fmt.Sprintf("%s is not an allowed model", model),
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	scope := MODEL_SCOPE_CHANNEL
+	optionValue, ok = findCommandOption(
+		i.ApplicationCommandData().Options,
+		SCOPE,
+	)
+	if ok {
+		scope = optionValue.StringValue()
+	}
+
+	channelID := i.ChannelID
+	if scope == MODEL_SCOPE_GUILD {
+		channelID = ""
+	}
+
+	if err := s.DB.SetModel(i.GuildID, channelID, model); err != nil {
+		log.Println("unable to set model: ", err)
+		return err
+	}
+
+	content := fmt.Sprintf("Using %s in this channel", model)
+	if scope == MODEL_SCOPE_GUILD {
+		content = fmt.Sprintf("Using %s in this server", model)
+	}
+	if !supportsTools(model, s.Config) {
+		content += ". This model does not support functions so they will be disabled"
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+}
+
+func handleReminders(i *discordgo.InteractionCreate, s *Skippy) error {
+	userID, err := getInteractionUserID(i)
+	if err != nil {
+		return err
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return fmt.Errorf("missing %s subcommand", REMINDERS)
+	}
+	subcommand := options[0]
+
+	var content string
+	switch subcommand.Name {
+	case LIST:
+		reminders, err := s.DB.GetRemindersByUser(userID)
+		if err != nil {
+			return err
+		}
+		content = formatReminders(reminders)
+	case FROM_OTHERS:
+		content, err = setReminderConsent(userID, subcommand, s)
+		if err != nil {
+			return err
+		}
+	default:
+		content, err = updateReminder(userID, subcommand, s)
+		if err != nil {
+			return err
+		}
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+func setReminderConsent(
+	userID string,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+	s *Skippy,
+) (string, error) {
+	optionValue, ok := findCommandOption(subcommand.Options, ALLOW)
+	if !ok {
+		return "", fmt.Errorf("unable to find slash command option %s", ALLOW)
+	}
+
+	if optionValue.BoolValue() {
+		if err := s.DB.SetReminderOptOut(userID, false); err != nil {
+			return "", err
+		}
+		return "Other people can set reminders for you", nil
+	}
+
+	if err := optOutOfReminders(userID, s); err != nil {
+		return "", err
+	}
+	return "Other people can no longer set reminders for you", nil
+}
+
+// Handles the reminder subcommands that change one of the user's reminders
+func updateReminder(
+	userID string,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+	s *Skippy,
+) (string, error) {
+	optionValue, ok := findCommandOption(subcommand.Options, ID)
+	if !ok {
+		return "", fmt.Errorf("unable to find slash command option %s", ID)
+	}
+
+	// the subcommands are named after the reminder actions
+	reminders, err := findReminders(userID, subcommand.Name, uint(optionValue.IntValue()), "", s)
+	if err != nil {
+		return "", err
+	}
+	if len(reminders) == 0 {
+		return fmt.Sprintf("You don't have a reminder #%d you can %s", optionValue.IntValue(), subcommand.Name), nil
+	}
+	reminder := reminders[0]
+
+	minutes := 0
+	if optionValue, ok := findCommandOption(subcommand.Options, MINUTES); ok {
+		minutes = int(optionValue.IntValue())
+	}
+
+	switch subcommand.Name {
+	case CANCEL:
+		cancelReminder(reminder.ID, s)
+		return fmt.Sprintf("Canceled reminder #%d", reminder.ID), nil
+	case SNOOZE:
+		if minutes <= 0 {
+			minutes = DEFAULT_SNOOZE_MINUTES
+		}
+		reminder, err = snoozeReminder(reminder, time.Duration(minutes)*time.Minute, s)
+		if err != nil {
+			return "", err
+		}
+		return "Snoozed " + formatReminder(reminder), nil
+	case EDIT:
+		var message string
+		if optionValue, ok := findCommandOption(subcommand.Options, MESSAGE); ok {
+			message = optionValue.StringValue()
+		}
+		var remindAt time.Time
+		if minutes > 0 {
+			remindAt = time.Now().Add(time.Duration(minutes) * time.Minute)
+		}
+		reminder, err = editReminder(reminder, message, remindAt, "", s)
+		if err != nil {
+			return "", err
+		}
+		return "Updated " + formatReminder(reminder), nil
+	default:
+		return "", fmt.Errorf("unknown %s subcommand %s", REMINDERS, subcommand.Name)
+	}
+}
+
+// Options for creating or editing a digest. The time and name are required when creating
+func makeDigestOptions(create bool) []*discordgo.ApplicationCommandOption {
+	return []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        TIME,
+			Description: "Time of day to send the digest. ex: 8:30 AM",
+			Required:    create,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        NAME,
+			Description: "Name to tell the digests in this channel apart. ex: weekend",
+			Required:    create,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        TIMEZONE,
+			Description: "IANA timezone of the time. ex: America/Chicago",
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        DAYS,
+			Description: "Days to send the digest. Defaults to every day",
+			Required:    false,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: formatDigestDays(DIGEST_EVERY_DAY), Value: DIGEST_EVERY_DAY},
+				{Name: formatDigestDays(DIGEST_WEEKDAYS), Value: DIGEST_WEEKDAYS},
+				{Name: formatDigestDays(DIGEST_WEEKENDS), Value: DIGEST_WEEKENDS},
+			},
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        WEATHER,
+			Description: fmt.Sprintf("Comma separated locations to get the weather for. Use %s to remove them", NONE),
+			Required:    false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        STOCKS,
+			Description: fmt.Sprintf("Comma separated tickers to get the price for. Use %s to remove them", NONE),
+			Required:    false,
+		},
+		{
+			Type: discordgo.ApplicationCommandOptionString,
+			Name: SECTIONS,
+			Description: fmt.Sprintf(
+				"Comma separated sections in order from %s",
+				strings.Join(digestSectionNames(), ", "),
+			),
+			Required: false,
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        FEED,
+			Description: fmt.Sprintf("RSS or Atom feed url for the news section. Use %s to remove it", NONE),
+			Required:    false,
+		},
+	}
+}
+
+func handleDigest(i *discordgo.InteractionCreate, s *Skippy) error {
+	if !hasPermission(i, discordgo.PermissionManageServer) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Server permission to manage digests",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return fmt.Errorf("missing %s subcommand", DIGEST)
+	}
+	subcommand := options[0]
+
+	var content string
+	var err error
+	switch subcommand.Name {
+	case LIST:
+		var digests []Digest
+		digests, err = s.DB.GetDigestsByChannel(i.ChannelID)
+		content = formatDigests(digests)
+	case CREATE:
+		content, err = saveDigestOptions(
+			Digest{GuildID: i.GuildID, ChannelID: i.ChannelID},
+			subcommand.Options,
+			s,
+		)
+	default:
+		content, err = updateDigest(i.ChannelID, subcommand, s)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+}
+
+// Handles the digest subcommands for a single digest in the channel
+func updateDigest(
+	channelID string,
+	subcommand *discordgo.ApplicationCommandInteractionDataOption,
+	s *Skippy,
+) (string, error) {
+	optionValue, ok := findCommandOption(subcommand.Options, ID)
+	if !ok {
+		return "", fmt.Errorf("unable to find slash command option %s", ID)
+	}
+
+	digest, err := s.DB.GetDigest(uint(optionValue.IntValue()))
+	if err != nil || digest.ChannelID != channelID {
+		return fmt.Sprintf("There is no digest #%d in this channel", optionValue.IntValue()), nil
+	}
+
+	switch subcommand.Name {
+	case VIEW:
+		return formatDigestDetails(*digest), nil
+	case EDIT:
+		return saveDigestOptions(*digest, subcommand.Options, s)
+	case DELETE:
+		if err := deleteDigest(digest.ID, s); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Deleted digest #%d **%s**", digest.ID, digest.Name), nil
+	default:
+		return "", fmt.Errorf("unknown %s subcommand %s", DIGEST, subcommand.Name)
+	}
+}
+
+// Applies the digest options that were set and saves the digest
+func saveDigestOptions(
+	digest Digest,
+	options []*discordgo.ApplicationCommandInteractionDataOption,
+	s *Skippy,
+) (string, error) {
+	if optionValue, ok := findCommandOption(options, NAME); ok {
+		digest.Name = optionValue.StringValue()
+	}
+	if optionValue, ok := findCommandOption(options, TIME); ok {
+		digest.Time = optionValue.StringValue()
+	}
+	if optionValue, ok := findCommandOption(options, TIMEZONE); ok {
+		digest.Timezone = optionValue.StringValue()
+	}
+	if optionValue, ok := findCommandOption(options, DAYS); ok {
+		digest.Days = optionValue.StringValue()
+	}
+	if optionValue, ok := findCommandOption(options, WEATHER); ok {
+		digest.WeatherLocations = parseListOption(optionValue.StringValue())
+	}
+	if optionValue, ok := findCommandOption(options, STOCKS); ok {
+		digest.Stocks = parseListOption(optionValue.StringValue())
+	}
+	if optionValue, ok := findCommandOption(options, SECTIONS); ok {
+		digest.Sections = parseListOption(optionValue.StringValue())
+	}
+	if optionValue, ok := findCommandOption(options, FEED); ok {
+		digest.FeedURL = strings.TrimSpace(optionValue.StringValue())
+		if strings.EqualFold(digest.FeedURL, NONE) {
+			digest.FeedURL = ""
+		}
+	}
+
+	digest, err := saveDigest(digest, s)
+	if err != nil {
+		return fmt.Sprintf("Unable to save the digest: %s", err), nil
+	}
+	return "Saved " + formatDigestDetails(digest), nil
+}
+
+func handleBirthday(i *discordgo.InteractionCreate, s *Skippy) error {
+	userID, err := getInteractionUserID(i)
+	if err != nil {
+		return err
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return fmt.Errorf("missing %s subcommand", BIRTHDAY)
+	}
+	subcommand := options[0]
+
+	var content string
+	switch subcommand.Name {
+	case SET:
+		month, ok := findCommandOption(subcommand.Options, MONTH)
+		if !ok {
+			return fmt.Errorf("unable to find slash command option %s", MONTH)
+		}
+		day, ok := findCommandOption(subcommand.Options, DAY)
+		if !ok {
+			return fmt.Errorf("unable to find slash command option %s", DAY)
+		}
+
+		birthday, err := setBirthday(i.GuildID, userID, int(month.IntValue()), int(day.IntValue()), s)
+		if err != nil {
+			content = fmt.Sprintf("Unable to set your birthday: %s", err)
+		} else {
+			content = "Your birthday is set to " + formatBirthday(birthday)
+		}
+	case REMOVE:
+		if err := s.DB.DeleteBirthday(i.GuildID, userID); err != nil {
+			return err
+		}
+		content = "Your birthday was removed"
+	default:
+		return fmt.Errorf("unknown %s subcommand %s", BIRTHDAY, subcommand.Name)
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+func handleWeatherUnits(i *discordgo.InteractionCreate, s *Skippy) error {
+	userID, err := getInteractionUserID(i)
+	if err != nil {
+		return err
+	}
+
+	optionValue, ok := findCommandOption(i.ApplicationCommandData().Options, UNITS)
+	if !ok {
+		return fmt.Errorf("unable to find slash command option %s", UNITS)
+	}
+	units := optionValue.StringValue()
+	if err := s.DB.SetWeatherUnits(userID, units); err != nil {
+		return err
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("The weather will be given to you in %s units", units),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+func handleWeatherWatch(i *discordgo.InteractionCreate, s *Skippy) error {
+	if !hasPermission(i, discordgo.PermissionManageChannels) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Channels permission to change the weather watches",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	options := i.ApplicationCommandData().Options
+	if len(options) == 0 {
+		return fmt.Errorf("missing %s subcommand", WEATHER_WATCH)
+	}
+	subcommand := options[0]
+
+	var content string
+	var added *WeatherWatch
+	switch subcommand.Name {
+	case ADD:
+		location, ok := findCommandOption(subcommand.Options, LOCATION)
+		if !ok {
+			return fmt.Errorf("unable to find slash command option %s", LOCATION)
+		}
+		watch := WeatherWatch{GuildID: i.GuildID, ChannelID: i.ChannelID, Location: location.StringValue()}
+		if units, ok := findCommandOption(subcommand.Options, UNITS); ok {
+			watch.Units = units.StringValue()
+		}
+
+		saved, err := addWeatherWatch(watch, s)
+		if err != nil {
+			content = fmt.Sprintf("Unable to watch the weather in %s: %s", watch.Location, err)
+		} else {
+			content = "Watching the weather for severe alerts, freezing temperatures and heavy rain in " +
+				formatWeatherWatch(saved)
+			added = &saved
+		}
+	case LIST:
+		watches, err := s.DB.GetWeatherWatchesByChannel(i.ChannelID)
+		if err != nil {
+			return err
+		}
+		content = formatWeatherWatches(watches)
+	case REMOVE:
+		optionValue, ok := findCommandOption(subcommand.Options, ID)
+		if !ok {
+			return fmt.Errorf("unable to find slash command option %s", ID)
+		}
+		watch, err := s.DB.GetWeatherWatch(uint(optionValue.IntValue()))
+		if err != nil || watch.ChannelID != i.ChannelID {
+			content = fmt.Sprintf("There is no weather watch #%d in this channel", optionValue.IntValue())
+			break
+		}
+		if err := deleteWeatherWatch(watch.ID, s); err != nil {
+			return err
+		}
+		content = fmt.Sprintf("Stopped watching the weather in %s", watch.Name)
+	default:
+		return fmt.Errorf("unknown %s subcommand %s", WEATHER_WATCH, subcommand.Name)
+	}
+
+	err := s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+			},
+		})
+	if err != nil {
+		return err
+	}
+
+	// weather that is already severe is posted right away instead of at the first interval
+	if added != nil {
+		if err := CheckWeatherWatch(context.Background(), added.ID, s); err != nil {
+			log.Printf("unable to check weather watch %d: %s\n", added.ID, err)
+		}
+	}
+	return nil
+}
+
+// Shows the moderation setting for the guild after applying any changes
+func handleModeration(i *discordgo.InteractionCreate, s *Skippy) error {
+	if i.GuildID == "" || !hasPermission(i, discordgo.PermissionManageServer) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Server permission to change moderation in a server",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	options := i.ApplicationCommandData().Options
+	setting, err := s.DB.GetModerationSetting(i.GuildID)
+	if err != nil {
+		return err
+	}
+	if strictness, ok := findCommandOption(options, STRICTNESS); ok {
+		setting.Strictness = strictness.StringValue()
+	}
+	if channel, ok := findCommandOption(options, LOG_CHANNEL); ok {
+		setting.LogChannelID = channel.ChannelValue(nil).ID
+	}
+	if clearLog, ok := findCommandOption(options, CLEAR_LOG); ok && clearLog.BoolValue() {
+		setting.LogChannelID = ""
+	}
+	if len(options) > 0 {
+		if err := s.DB.SetModerationSetting(&setting); err != nil {
+			return err
+		}
+	}
+
+	content := formatModerationSetting(getModerationSetting(i.GuildID, s))
+	if s.Moderator == nil {
+		content += ". No moderator is configured so nothing is checked"
+	}
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: content,
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+// Shows the tools turned off in the guild after applying any changes
+func handleTools(i *discordgo.InteractionCreate, s *Skippy) error {
+	if i.GuildID == "" || !hasPermission(i, discordgo.PermissionManageServer) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Server permission to change the tools in a server",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	setting, err := s.DB.GetToolSetting(i.GuildID)
+	if err != nil {
+		return err
+	}
+	options := i.ApplicationCommandData().Options
+	if optionValue, ok := findCommandOption(options, ENABLE); ok {
+		setting.DisabledTools = slices.DeleteFunc(setting.DisabledTools, func(name string) bool {
+			return name == optionValue.StringValue()
+		})
+	}
+	if optionValue, ok := findCommandOption(options, DISABLE); ok {
+		name := optionValue.StringValue()
+		if _, ok := TOOL_REGISTRY.Get(name); !ok {
+			return fmt.Errorf("unknown tool %s", name)
+		}
+		if !slices.Contains(setting.DisabledTools, name) {
+			setting.DisabledTools = append(setting.DisabledTools, name)
+			slices.Sort(setting.DisabledTools)
+		}
+	}
+	if len(options) > 0 {
+		if err := s.DB.SetToolSetting(&setting); err != nil {
+			return err
+		}
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: formatToolSetting(setting, s.Config),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+// Shows the daily budget for the guild after applying any changes
+func handleBudget(i *discordgo.InteractionCreate, s *Skippy) error {
+	if i.GuildID == "" || !hasPermission(i, discordgo.PermissionManageServer) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Server permission to change the budget in a server",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	options := i.ApplicationCommandData().Options
+	if reset, ok := findCommandOption(options, RESET); ok && reset.BoolValue() {
+		if err := s.DB.DeleteBudgetSetting(i.GuildID); err != nil {
+			return err
+		}
+	} else if optionValue, ok := findCommandOption(options, DAILY_BUDGET); ok {
+		err := s.DB.SetBudgetSetting(&BudgetSetting{
+			GuildID:     i.GuildID,
+			DailyBudget: optionValue.FloatValue(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	spent, err := s.DB.GetUsageCost(i.GuildID, "", startOfDay(time.Now()))
+	if err != nil {
+		return err
+	}
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: formatBudget(getGuildBudget(i.GuildID, s), spent),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+// Shows the rate limits for the guild after applying any changes.
+// Limits that are not given keep their current value
+func handleRateLimits(i *discordgo.InteractionCreate, s *Skippy) error {
+	if i.GuildID == "" || !hasPermission(i, discordgo.PermissionManageServer) {
+		return s.DiscordSession.InteractionRespond(i.Interaction,
+			&discordgo.InteractionResponse{
+				Type: discordgo.InteractionResponseChannelMessageWithSource,
+				Data: &discordgo.InteractionResponseData{
+					Content: "You need the Manage Server permission to change the rate limits in a server",
+					Flags:   discordgo.MessageFlagsEphemeral,
+				},
+			})
+	}
+
+	options := i.ApplicationCommandData().Options
+	if reset, ok := findCommandOption(options, RESET); ok && reset.BoolValue() {
+		if err := s.DB.DeleteRateLimitSetting(i.GuildID); err != nil {
+			return err
+		}
+	} else if len(options) > 0 {
+		limits := getRateLimits(i.GuildID, s)
+		if option, ok := findCommandOption(options, USER_PER_MINUTE); ok {
+			limits.User.PerMinute = option.FloatValue()
+		}
+		if option, ok := findCommandOption(options, USER_BURST); ok {
+			limits.User.Burst = int(option.IntValue())
+		}
+		if option, ok := findCommandOption(options, CHANNEL_PER_MINUTE); ok {
+			limits.Channel.PerMinute = option.FloatValue()
+		}
+		if option, ok := findCommandOption(options, CHANNEL_BURST); ok {
+			limits.Channel.Burst = int(option.IntValue())
+		}
+		if err := s.DB.SetRateLimitSetting(&RateLimitSetting{GuildID: i.GuildID, Limits: limits}); err != nil {
+			return err
+		}
+	}
+
+	return s.DiscordSession.InteractionRespond(i.Interaction,
+		&discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: formatRateLimits(getRateLimits(i.GuildID, s)),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+}
+
+// Splits a comma separated option. NONE clears the list
+func parseListOption(value string) []string {
+	if strings.EqualFold(strings.TrimSpace(value), NONE) {
+		return []string{}
+	}
+	return parseList(value)
+}
+
+func getInteractionUserID(i *discordgo.InteractionCreate) (string, error) {
+	if i.Member != nil {
+		return i.Member.User.ID, nil
+	} else if i.User != nil {
+		return i.User.ID, nil
+	}
+	return "", fmt.Errorf("could not get user ID from interaction object")
+}
+
+// Checks if the member that created the interaction has the permission.
+// Interactions outside of a guild are always allowed
+func hasPermission(i *discordgo.InteractionCreate, permission int64) bool {
+	if i.Member == nil {
+		return true
+	}
+	return i.Member.Permissions&(permission|discordgo.PermissionAdministrator) != 0
+}
+
 func findCommandOption(
 	options []*discordgo.ApplicationCommandInteractionDataOption,
 	name string,
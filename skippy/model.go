@@ -0,0 +1,77 @@
+package skippy
+
+import (
+	"errors"
+	"log"
+	"slices"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	MODEL_SCOPE_CHANNEL = "channel"
+	MODEL_SCOPE_GUILD   = "guild"
+	O1_MINI             = "o1-mini"
+	O1_PREVIEW          = "o1-preview"
+	// discord limits the number of choices for an option
+	MAX_COMMAND_CHOICES = 25
+)
+
+var ErrToolsNotSupported = errors.New("the model can not use tools")
+
+// The model set for a channel or for a whole guild if ChannelID is empty
+type ModelSetting struct {
+	ID        uint   `gorm:"primaryKey"`
+	GuildID   string `gorm:"index"`
+	ChannelID string `gorm:"index"`
+	Model     string
+}
+
+// Gets the model for a channel. Falls back to the guild model and then Config.DefaultModel
+func getChannelModel(guildID string, channelID string, s *Skippy) string {
+	model, err := s.DB.GetModel(guildID, channelID)
+	if err != nil {
+		log.Println("unable to get channel model: ", err)
+	}
+	if model == "" {
+		return s.Config.DefaultModel
+	}
+	return model
+}
+
+func supportsTools(model string, config *Config) bool {
+	// models may be in the form provider:model
+	if _, modelName, found := strings.Cut(model, PROVIDER_SEPARATOR); found {
+		model = modelName
+	}
+	return !slices.Contains(config.NoToolModels, model)
+}
+
+func makeModelChoices(models []string) []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for i, model := range models {
+		if i >= MAX_COMMAND_CHOICES {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  model,
+			Value: model,
+		})
+	}
+	return choices
+}
+
+// o1 models are left out since they reject the system messages every request has
+var DEFAULT_ALLOWED_MODELS = []string{
+	openai.GPT4o,
+	openai.GPT4oMini,
+	openai.GPT4Turbo,
+	openai.GPT3Dot5Turbo,
+}
+
+var DEFAULT_NO_TOOL_MODELS = []string{
+	O1_MINI,
+	O1_PREVIEW,
+}
@@ -0,0 +1,243 @@
+package skippy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	MODERATION_OFF    = "off"
+	MODERATION_LOW    = "low"
+	MODERATION_MEDIUM = "medium"
+	MODERATION_HIGH   = "high"
+	// where flagged content came from. shown in the mod log
+	MODERATION_SOURCE_MESSAGE      = "message"
+	MODERATION_SOURCE_RESPONSE     = "response"
+	MODERATION_SOURCE_IMAGE_PROMPT = "image prompt"
+	MODERATION_SOURCE_TOOL_CALL    = "tool call"
+	MODERATION_LOG_COLOR           = 0xe67e22
+	// characters of the flagged content shown in the mod log
+	MAX_MODERATION_LOG_CONTENT = 1000
+	BLOCKLIST_CATEGORY         = "blocklist"
+	IMAGE_PROMPT_FLAGGED       = "the image prompt was flagged by moderation and no image was made. refuse in character without repeating the prompt"
+)
+
+var ErrContentFlagged = errors.New("content flagged by moderation")
+
+// lowest category score that is flagged at each strictness. nothing is checked when off
+var MODERATION_THRESHOLDS = map[string]float64{
+	MODERATION_LOW:    0.8,
+	MODERATION_MEDIUM: 0.5,
+	MODERATION_HIGH:   0.2,
+}
+
+var MODERATION_RESPONSES = map[BotName]string{
+	SKIPPY: "Nope. Even an ancient, all-powerful beer can has standards, and that is way below them. Find something else to talk about, monkey.",
+	GLADOS: "That falls well outside the parameters of acceptable testing. I'll pretend you never said it. You should too.",
+}
+
+// Classifies text for moderation. Set Skippy.Moderator to use another classifier
+type Moderator interface {
+	// Returns the score of each category from 0 to 1
+	Moderate(ctx context.Context, text string) (map[string]float64, error)
+}
+
+// Uses the OpenAI moderation endpoint
+type OpenAIModerator struct {
+	client *openai.Client
+}
+
+func NewOpenAIModerator(client *openai.Client) *OpenAIModerator {
+	return &OpenAIModerator{client: client}
+}
+
+func (m *OpenAIModerator) Moderate(ctx context.Context, text string) (map[string]float64, error) {
+	resp, err := m.client.Moderations(ctx, openai.ModerationRequest{
+		Input: text,
+		Model: openai.ModerationTextLatest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) == 0 {
+		return nil, errors.New("no moderation result was returned")
+	}
+
+	// the scores are a struct with a json field for each category
+	data, err := json.Marshal(resp.Results[0].CategoryScores)
+	if err != nil {
+		return nil, err
+	}
+	var scores map[string]float64
+	err = json.Unmarshal(data, &scores)
+	return scores, err
+}
+
+// A local classifier that flags text containing any of its words or phrases
+type BlocklistModerator struct {
+	terms []string
+}
+
+func NewBlocklistModerator(terms []string) *BlocklistModerator {
+	var normalized []string
+	for _, term := range terms {
+		if term = normalizeModerationText(term); term != "" {
+			normalized = append(normalized, term)
+		}
+	}
+	return &BlocklistModerator{terms: normalized}
+}
+
+func (m *BlocklistModerator) Moderate(ctx context.Context, text string) (map[string]float64, error) {
+	// padded so terms only match whole words
+	text = " " + normalizeModerationText(text) + " "
+	for _, term := range m.terms {
+		if strings.Contains(text, " "+term+" ") {
+			return map[string]float64{BLOCKLIST_CATEGORY: 1}, nil
+		}
+	}
+	return map[string]float64{BLOCKLIST_CATEGORY: 0}, nil
+}
+
+// Lowercases the words in text and separates them with single spaces
+func normalizeModerationText(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// Moderation set for a guild with /moderation
+type ModerationSetting struct {
+	GuildID string `gorm:"primaryKey"`
+	// MODERATION_OFF, LOW, MEDIUM or HIGH. Config.ModerationStrictness if empty
+	Strictness string
+	// channel flagged content is logged to. not logged if empty
+	LogChannelID string
+}
+
+// Content being moderated and where it came from
+type ModerationInput struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	Source    string
+	Content   string
+}
+
+// Gets the guild's setting falling back to Config.ModerationStrictness
+func getModerationSetting(guildID string, s *Skippy) ModerationSetting {
+	setting := ModerationSetting{GuildID: guildID}
+	if guildID != "" {
+		saved, err := s.DB.GetModerationSetting(guildID)
+		if err != nil {
+			log.Println("unable to get moderation setting: ", err)
+		} else {
+			setting = saved
+		}
+	}
+	if setting.Strictness == "" {
+		setting.Strictness = s.Config.ModerationStrictness
+	}
+	return setting
+}
+
+// Checks the content with Skippy.Moderator at the guild's strictness.
+// Flagged content is posted to the guild's mod log.
+// Content is allowed when there is no moderator, moderation is off or the moderator fails
+func isFlagged(ctx context.Context, input ModerationInput, s *Skippy) bool {
+	if s.Moderator == nil || strings.TrimSpace(input.Content) == "" {
+		return false
+	}
+	setting := getModerationSetting(input.GuildID, s)
+	threshold, ok := MODERATION_THRESHOLDS[setting.Strictness]
+	if !ok {
+		return false
+	}
+
+	scores, err := s.Moderator.Moderate(ctx, input.Content)
+	if err != nil {
+		log.Println("unable to moderate content: ", err)
+		return false
+	}
+	categories := findFlaggedCategories(scores, threshold)
+	if len(categories) == 0 {
+		return false
+	}
+
+	log.Printf("%s from %s on %s flagged for %s\n", input.Source, input.UserID, input.ChannelID, strings.Join(categories, ", "))
+	if setting.LogChannelID != "" {
+		if _, err := s.DiscordSession.ChannelMessageSendEmbed(
+			setting.LogChannelID,
+			makeModerationLogEmbed(input, categories),
+		); err != nil {
+			log.Println("unable to send to the mod log: ", err)
+		}
+	}
+	return true
+}
+
+// Gets the categories at or above threshold with their scores sorted by name
+func findFlaggedCategories(scores map[string]float64, threshold float64) []string {
+	var categories []string
+	for category, score := range scores {
+		if score >= threshold {
+			categories = append(categories, fmt.Sprintf("%s (%.2f)", category, score))
+		}
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func makeModerationLogEmbed(input ModerationInput, categories []string) *discordgo.MessageEmbed {
+	fields := []*discordgo.MessageEmbedField{
+		{Name: "Channel", Value: fmt.Sprintf("<#%s>", input.ChannelID), Inline: true},
+		{Name: "Categories", Value: strings.Join(categories, "\n"), Inline: true},
+	}
+	if input.UserID != "" {
+		fields = append([]*discordgo.MessageEmbedField{
+			{Name: "User", Value: UserMention(input.UserID), Inline: true},
+		}, fields...)
+	}
+	return &discordgo.MessageEmbed{
+		Title:       "Flagged " + input.Source,
+		Description: truncate(input.Content, MAX_MODERATION_LOG_CONTENT),
+		Color:       MODERATION_LOG_COLOR,
+		Fields:      fields,
+	}
+}
+
+func makeModerationResponse(s *Skippy) string {
+	response, ok := MODERATION_RESPONSES[s.Config.Name]
+	if !ok {
+		return MODERATION_RESPONSES[SKIPPY]
+	}
+	return response
+}
+
+func formatModerationSetting(setting ModerationSetting) string {
+	content := fmt.Sprintf("Moderation is **%s**", setting.Strictness)
+	if setting.LogChannelID != "" {
+		content += fmt.Sprintf(". Flagged content is logged in <#%s>", setting.LogChannelID)
+	}
+	return content
+}
+
+func isImagePromptFlagged(ctx context.Context, toolCtx ToolContext, prompt string, s *Skippy) bool {
+	return isFlagged(ctx, ModerationInput{
+		GuildID:   toolCtx.GuildID,
+		ChannelID: toolCtx.ChannelID,
+		UserID:    toolCtx.UserID,
+		Source:    MODERATION_SOURCE_IMAGE_PROMPT,
+		Content:   prompt,
+	}, s)
+}
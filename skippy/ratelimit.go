@@ -0,0 +1,194 @@
+package skippy
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	USER_RATE_LIMIT_KEY    = "USER|%s|%s"
+	CHANNEL_RATE_LIMIT_KEY = "CHANNEL|%s"
+	DEFAULT_USER_RATE      = 6
+	DEFAULT_USER_BURST     = 3
+	DEFAULT_CHANNEL_RATE   = 20
+	DEFAULT_CHANNEL_BURST  = 10
+	DEFAULT_COALESCE_DELAY = 1500 * time.Millisecond
+)
+
+var COOLDOWN_RESPONSES = map[BotName]string{
+	SKIPPY: "Whoa there %s, even my magnificence has limits on how much monkey chatter it can process. Try again in %s.",
+	GLADOS: "%s, your enthusiasm has been noted and will be used against you. Testing will resume in %s.",
+}
+
+// Token bucket limits. A bucket holds Burst tokens and refills PerMinute tokens each minute
+type RateLimit struct {
+	PerMinute float64
+	Burst     int
+}
+
+// Limits applied to AI requests in a guild
+type RateLimits struct {
+	User    RateLimit
+	Channel RateLimit
+}
+
+// Rate limits set for a guild with /rate_limits overriding Config.RateLimits
+type RateLimitSetting struct {
+	GuildID string     `gorm:"primaryKey"`
+	Limits  RateLimits `gorm:"serializer:json"`
+}
+
+// A bucket and its limit for RateLimiter.AllowAll
+type RateLimitKey struct {
+	Key   string
+	Limit RateLimit
+}
+
+type bucket struct {
+	tokens   float64
+	last     time.Time
+	notified time.Time
+}
+
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Takes a token from the bucket for key if one is available.
+// Returns the time until a token is available when it is not.
+// A nil RateLimiter or a zero limit always allows
+func (r *RateLimiter) Allow(key string, limit RateLimit) (bool, time.Duration) {
+	_, wait, ok := r.AllowAll(RateLimitKey{Key: key, Limit: limit})
+	return ok, wait
+}
+
+// Takes a token from every bucket only if each of them has one available
+// so a request that is limited does not use up the other buckets.
+// Returns the first key that is limited and the time until it has a token
+func (r *RateLimiter) AllowAll(keys ...RateLimitKey) (string, time.Duration, bool) {
+	if r == nil {
+		return "", 0, true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var buckets []*bucket
+	for _, key := range keys {
+		if key.Limit.PerMinute <= 0 || key.Limit.Burst <= 0 {
+			continue
+		}
+		b := r.refill(key.Key, key.Limit, now)
+		if b.tokens < 1 {
+			perSecond := key.Limit.PerMinute / 60
+			return key.Key, time.Duration((1 - b.tokens) / perSecond * float64(time.Second)), false
+		}
+		buckets = append(buckets, b)
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return "", 0, true
+}
+
+// Gets the bucket for key with the tokens added since it was last used
+func (r *RateLimiter) refill(key string, limit RateLimit, now time.Time) *bucket {
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), last: now}
+		r.buckets[key] = b
+	}
+
+	perSecond := limit.PerMinute / 60
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+	return b
+}
+
+// Checks if the cooldown message should be sent for key.
+// Only notifies once per cooldown so the bot doesn't spam the channel
+func (r *RateLimiter) ShouldNotify(key string, cooldown time.Duration) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[key]
+	if !ok || time.Since(b.notified) < cooldown {
+		return false
+	}
+	b.notified = time.Now()
+	return true
+}
+
+func makeUserRateLimitKey(guildID string, userID string) string {
+	return fmt.Sprintf(USER_RATE_LIMIT_KEY, guildID, userID)
+}
+
+func makeChannelRateLimitKey(channelID string) string {
+	return fmt.Sprintf(CHANNEL_RATE_LIMIT_KEY, channelID)
+}
+
+// Gets the limits set for the guild falling back to Config.RateLimits
+func getRateLimits(guildID string, s *Skippy) RateLimits {
+	if guildID != "" {
+		setting, err := s.DB.GetRateLimitSetting(guildID)
+		if err != nil {
+			log.Println("unable to get guild rate limits: ", err)
+		} else if setting != nil {
+			return setting.Limits
+		}
+	}
+	return s.Config.RateLimits
+}
+
+// Checks the user and channel rate limits for a request.
+// Members with a bypass role are not limited.
+// Returns the rate limit key that was exceeded and how long until it is available
+func checkRateLimit(req ResponseReq, roles []string, s *Skippy) (string, time.Duration, bool) {
+	for _, role := range roles {
+		if slices.Contains(s.Config.RateLimitBypassRoles, role) {
+			return "", 0, true
+		}
+	}
+
+	limits := getRateLimits(req.GuildID, s)
+	return s.RateLimiter.AllowAll(
+		RateLimitKey{Key: makeUserRateLimitKey(req.GuildID, req.UserID), Limit: limits.User},
+		RateLimitKey{Key: makeChannelRateLimitKey(req.ChannelID), Limit: limits.Channel},
+	)
+}
+
+func makeCooldownResponse(userID string, wait time.Duration, s *Skippy) string {
+	format, ok := COOLDOWN_RESPONSES[s.Config.Name]
+	if !ok {
+		format = COOLDOWN_RESPONSES[SKIPPY]
+	}
+	return fmt.Sprintf(format, UserMention(userID), wait.Round(time.Second))
+}
+
+func formatRateLimit(limit RateLimit) string {
+	if limit.PerMinute <= 0 || limit.Burst <= 0 {
+		return "no limit"
+	}
+	return fmt.Sprintf("%g messages a minute with bursts of %d", limit.PerMinute, limit.Burst)
+}
+
+func formatRateLimits(limits RateLimits) string {
+	return fmt.Sprintf("Each user: %s\nEach channel: %s", formatRateLimit(limits.User), formatRateLimit(limits.Channel))
+}
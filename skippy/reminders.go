@@ -0,0 +1,564 @@
+package skippy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"skippybot/components"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	DEFAULT_SNOOZE_MINUTES = 10
+	// gocron will not schedule a job in the past
+	MIN_REMINDER_DELAY = time.Second
+	CRON_TZ_PREFIX     = "CRON_TZ="
+	REMINDER_DONE      = "Done"
+	REMINDER_CANCEL    = "Cancel"
+	// CustomID prefix of the reminder buttons. ids are reminder:<id>:<action>[:<snooze minutes>]
+	REMINDER_COMPONENT_PREFIX = "reminder"
+	REMINDER_ACTION_DONE      = "done"
+	REMINDER_ACTION_SNOOZE    = "snooze"
+	REMINDER_ACTION_CANCEL    = "cancel"
+	REMINDER_ACTION_EDIT      = "edit"
+	// the reminder is sent in ChannelID
+	REMINDER_DELIVERY_CHANNEL = "channel"
+	// the reminder is sent to the target user's DMs
+	REMINDER_DELIVERY_DM = "dm"
+)
+
+// snooze buttons added to reminder messages
+var REMINDER_SNOOZE_DURATIONS = []time.Duration{
+	10 * time.Minute,
+	time.Hour,
+}
+
+// formats accepted for absolute reminder times. times without an offset are in the reminder's timezone
+var REMINDER_TIME_FORMATS = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+type Reminder struct {
+	ID        uint `gorm:"primaryKey"`
+	GuildID   string
+	ChannelID string `gorm:"index"`
+	// the user that created the reminder
+	UserID string `gorm:"index"`
+	// the user the reminder is for. the creator if empty
+	TargetUserID string `gorm:"index"`
+	// one of REMINDER_DELIVERY_CHANNEL or REMINDER_DELIVERY_DM
+	Delivery string
+	Message  string
+	// the next time the reminder will be sent
+	RemindAt time.Time
+	// cron expression for recurring reminders. empty for one time reminders
+	Recurrence string
+	// IANA timezone the recurrence is in
+	Timezone string
+	// how long after the reminder is sent to follow up if the user has not responded.
+	// nil uses Config.ReminderDurations and empty sends no follow ups
+	FollowUps []time.Duration `gorm:"serializer:json"`
+	// the reminder was sent and is waiting for the user to respond
+	Sent bool
+	// the last message the reminder was sent in. replying to it acknowledges the reminder
+	MessageID string
+	CreatedAt time.Time
+}
+
+func (r Reminder) IsRecurring() bool {
+	return r.Recurrence != ""
+}
+
+// Gets the user the reminder is for
+func (r Reminder) getTargetUserID() string {
+	if r.TargetUserID == "" {
+		return r.UserID
+	}
+	return r.TargetUserID
+}
+
+// Gets the user that can take an action on the reminder.
+// Only the creator can cancel or edit it and only the user it is for can finish or snooze it
+func (r Reminder) getActionUserID(action string) string {
+	if action == REMINDER_ACTION_CANCEL || action == REMINDER_ACTION_EDIT {
+		return r.UserID
+	}
+	return r.getTargetUserID()
+}
+
+// a user that does not want reminders set by other people
+type ReminderOptOut struct {
+	UserID    string `gorm:"primaryKey"`
+	CreatedAt time.Time
+}
+
+func (r Reminder) getFollowUps(config *Config) []time.Duration {
+	if r.FollowUps == nil {
+		return config.ReminderDurations
+	}
+	return r.FollowUps
+}
+
+// Saves the reminder and schedules it
+func createReminder(reminder Reminder, s *Skippy) (Reminder, error) {
+	if err := s.DB.CreateReminder(&reminder); err != nil {
+		return reminder, err
+	}
+	reminder, err := scheduleReminder(reminder, s)
+	if err != nil {
+		cancelReminder(reminder.ID, s)
+		return reminder, err
+	}
+	return reminder, nil
+}
+
+// Schedules a reminder replacing any of its existing jobs.
+// Recurring reminders are updated with their next run
+func scheduleReminder(reminder Reminder, s *Skippy) (Reminder, error) {
+	s.Scheduler.CancelReminderJob(reminder.ID)
+
+	jobFunc := func() {
+		sendReminder(reminder.ID, s)
+	}
+
+	if reminder.IsRecurring() {
+		nextRun, err := s.Scheduler.AddRecurringReminderJob(
+			reminder.ID,
+			makeCrontab(reminder.Recurrence, reminder.Timezone),
+			jobFunc,
+		)
+		if err != nil {
+			return reminder, fmt.Errorf("invalid recurrence %s: %w", reminder.Recurrence, err)
+		}
+		reminder.RemindAt = nextRun
+		log.Printf("scheduling recurring reminder %d on %s next at %s\n", reminder.ID, reminder.ChannelID, nextRun)
+		return reminder, s.DB.UpdateReminder(&reminder)
+	}
+
+	remindAt := reminder.RemindAt
+	if time.Until(remindAt) < MIN_REMINDER_DELAY {
+		remindAt = time.Now().Add(MIN_REMINDER_DELAY)
+	}
+
+	log.Printf("scheduling reminder %d on %s at %s\n", reminder.ID, reminder.ChannelID, remindAt)
+	return reminder, s.Scheduler.AddReminderJob(reminder.ID, remindAt, jobFunc)
+}
+
+// Sends the reminder and schedules follow ups until the user responds
+func sendReminder(id uint, s *Skippy) {
+	reminder, err := s.DB.GetReminder(id)
+	if err != nil {
+		log.Printf("unable to get reminder %d: %s\n", id, err)
+		return
+	}
+
+	msg, err := s.DiscordSession.ChannelMessageSendComplex(reminder.ChannelID, &discordgo.MessageSend{
+		Content:    reminder.Message,
+		Components: []discordgo.MessageComponent{makeReminderButtons(*reminder, s)},
+	})
+	if err != nil {
+		log.Printf("unable to send reminder %d: %s\n", id, err)
+	} else if msg != nil {
+		reminder.MessageID = msg.ID
+	}
+
+	reminder.Sent = true
+	if reminder.IsRecurring() {
+		if nextRun, ok := s.Scheduler.NextReminderRun(reminder.ID); ok {
+			reminder.RemindAt = nextRun
+		}
+	}
+	if err := s.DB.UpdateReminder(reminder); err != nil {
+		log.Printf("unable to update reminder %d: %s\n", id, err)
+	}
+
+	// follow ups from the last time a recurring reminder was sent are replaced
+	s.Scheduler.CancelReminderNagJobs(reminder.ID)
+	for _, duration := range reminder.getFollowUps(s.Config) {
+		s.Scheduler.AddReminderNagJob(reminder.ID, duration, func() {
+			sendAdditionalReminder(
+				context.Background(),
+				reminder.GuildID,
+				reminder.ChannelID,
+				reminder.getTargetUserID(),
+				s,
+			)
+		})
+	}
+}
+
+// Creates the done and snooze buttons for a reminder message.
+// Recurring reminders also get a button to cancel them
+func makeReminderButtons(reminder Reminder, s *Skippy) discordgo.ActionsRow {
+	id := strconv.FormatUint(uint64(reminder.ID), 10)
+	buttons := []discordgo.Button{
+		{
+			Style:    discordgo.SuccessButton,
+			Label:    REMINDER_DONE,
+			CustomID: components.MakeCustomID(REMINDER_COMPONENT_PREFIX, id, REMINDER_ACTION_DONE),
+		},
+	}
+	for _, duration := range REMINDER_SNOOZE_DURATIONS {
+		buttons = append(buttons, discordgo.Button{
+			Style: discordgo.SecondaryButton,
+			Label: "Snooze " + formatSnoozeDuration(duration),
+			CustomID: components.MakeCustomID(
+				REMINDER_COMPONENT_PREFIX,
+				id,
+				REMINDER_ACTION_SNOOZE,
+				strconv.Itoa(int(duration.Minutes())),
+			),
+		})
+	}
+	if reminder.IsRecurring() {
+		buttons = append(buttons, discordgo.Button{
+			Style:    discordgo.DangerButton,
+			Label:    REMINDER_CANCEL,
+			CustomID: components.MakeCustomID(REMINDER_COMPONENT_PREFIX, id, REMINDER_ACTION_CANCEL),
+		})
+	}
+	return components.ButtonRow(s.DiscordSession, buttons...)
+}
+
+// Handles the buttons of every reminder message including ones sent before a restart
+func RegisterReminderButtons(s *Skippy) {
+	s.ComponentHandler.HandlePrefix(REMINDER_COMPONENT_PREFIX, func(i *discordgo.InteractionCreate) {
+		if err := onReminderButton(i, s); err != nil {
+			log.Println("unable to handle reminder button: ", err)
+		}
+	})
+}
+
+// Acknowledges, snoozes or cancels the reminder in the button's CustomID.
+// Only the user the reminder is for can acknowledge or snooze it and only its creator can cancel it
+func onReminderButton(i *discordgo.InteractionCreate, s *Skippy) error {
+	userID, err := getInteractionUserID(i)
+	if err != nil {
+		return err
+	}
+
+	parts := components.ParseCustomID(i.MessageComponentData().CustomID)
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid reminder button %s", i.MessageComponentData().CustomID)
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return err
+	}
+	action := parts[1]
+
+	reminder, err := s.DB.GetReminder(uint(id))
+	if err != nil {
+		// the reminder was canceled
+		return removeReminderButtons(i, "", s)
+	}
+
+	allowedUserID := reminder.getActionUserID(action)
+	if allowedUserID != userID {
+		log.Printf("user %s can not %s reminder %d\n", userID, action, id)
+		return s.DiscordSession.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: &discordgo.InteractionResponseData{
+				Content: fmt.Sprintf("Only %s can %s this reminder", UserMention(allowedUserID), action),
+				Flags:   discordgo.MessageFlagsEphemeral,
+			},
+		})
+	}
+	if !reminder.Sent && action != REMINDER_ACTION_CANCEL {
+		// already acknowledged or snoozed from another message
+		return removeReminderButtons(i, "", s)
+	}
+
+	var status string
+	switch action {
+	case REMINDER_ACTION_DONE:
+		ackReminder(*reminder, s)
+		status = REMINDER_DONE
+	case REMINDER_ACTION_SNOOZE:
+		minutes := DEFAULT_SNOOZE_MINUTES
+		if len(parts) > 2 {
+			if minutes, err = strconv.Atoi(parts[2]); err != nil {
+				return err
+			}
+		}
+		snooze := time.Duration(minutes) * time.Minute
+		if _, err := snoozeReminder(*reminder, snooze, s); err != nil {
+			return fmt.Errorf("unable to snooze reminder %d: %w", id, err)
+		}
+		status = fmt.Sprintf("Snoozed until <t:%d:t>", time.Now().Add(snooze).Unix())
+	case REMINDER_ACTION_CANCEL:
+		cancelReminder(reminder.ID, s)
+		status = "Canceled"
+	default:
+		return fmt.Errorf("unknown reminder action %s", action)
+	}
+	return removeReminderButtons(i, status, s)
+}
+
+// Acknowledges the button press and removes the buttons from the reminder message
+func removeReminderButtons(i *discordgo.InteractionCreate, status string, s *Skippy) error {
+	err := s.DiscordSession.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredMessageUpdate,
+	})
+	if err != nil || i.Message == nil {
+		return err
+	}
+
+	content := i.Message.Content
+	if status != "" {
+		content += "\n-# " + status
+	}
+	_, err = s.DiscordSession.ChannelMessageEditComplex(&discordgo.MessageEdit{
+		Channel:    i.ChannelID,
+		ID:         i.Message.ID,
+		Content:    &content,
+		Components: &[]discordgo.MessageComponent{},
+	})
+	return err
+}
+
+// Stops the follow ups of a sent reminder.
+// One time reminders are removed and recurring reminders wait for their next run
+func ackReminder(reminder Reminder, s *Skippy) {
+	if !reminder.IsRecurring() {
+		cancelReminder(reminder.ID, s)
+		return
+	}
+
+	s.Scheduler.CancelReminderNagJobs(reminder.ID)
+	reminder.Sent = false
+	if err := s.DB.UpdateReminder(&reminder); err != nil {
+		log.Printf("unable to update reminder %d: %s\n", reminder.ID, err)
+	}
+}
+
+// Removes a reminder and stops any follow ups
+func cancelReminder(id uint, s *Skippy) {
+	s.Scheduler.CancelReminderJob(id)
+	if err := s.DB.DeleteReminder(id); err != nil {
+		log.Printf("unable to delete reminder %d: %s\n", id, err)
+	}
+}
+
+// Sends the reminder again after the duration.
+// Recurring reminders keep their schedule and only the current reminder is snoozed
+func snoozeReminder(reminder Reminder, duration time.Duration, s *Skippy) (Reminder, error) {
+	s.Scheduler.CancelReminderNagJobs(reminder.ID)
+	reminder.Sent = false
+
+	if reminder.IsRecurring() {
+		if err := s.DB.UpdateReminder(&reminder); err != nil {
+			return reminder, err
+		}
+		return reminder, s.Scheduler.AddReminderNagJob(reminder.ID, duration, func() {
+			sendReminder(reminder.ID, s)
+		})
+	}
+
+	reminder.RemindAt = time.Now().Add(duration)
+	if err := s.DB.UpdateReminder(&reminder); err != nil {
+		return reminder, err
+	}
+	return scheduleReminder(reminder, s)
+}
+
+// Changes the message and time of a reminder. Empty values are left unchanged.
+// Setting a time makes the reminder a one time reminder and setting a recurrence makes it recurring
+func editReminder(reminder Reminder, message string, remindAt time.Time, recurrence string, s *Skippy) (Reminder, error) {
+	if message != "" {
+		reminder.Message = message
+	}
+	if remindAt.IsZero() && recurrence == "" {
+		return reminder, s.DB.UpdateReminder(&reminder)
+	}
+
+	if recurrence != "" {
+		reminder.Recurrence = recurrence
+	} else {
+		reminder.RemindAt = remindAt
+		reminder.Recurrence = ""
+	}
+	reminder.Sent = false
+	if err := s.DB.UpdateReminder(&reminder); err != nil {
+		return reminder, err
+	}
+	return scheduleReminder(reminder, s)
+}
+
+// Finds the reminders a user can take action on by id or by searching the message
+func findReminders(userID string, action string, id uint, search string, s *Skippy) ([]Reminder, error) {
+	if id != 0 {
+		reminder, err := s.DB.GetReminder(id)
+		if err != nil || reminder.getActionUserID(action) != userID {
+			return nil, nil
+		}
+		return []Reminder{*reminder}, nil
+	}
+
+	userReminders, err := s.DB.GetRemindersByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reminders []Reminder
+	search = strings.ToLower(search)
+	for _, reminder := range userReminders {
+		if reminder.getActionUserID(action) == userID && strings.Contains(strings.ToLower(reminder.Message), search) {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+// Reschedules the saved reminders when the bot starts.
+// Sent one time reminders are left waiting for a response
+func loadReminders(s *Skippy) {
+	reminders, err := s.DB.GetAllReminders()
+	if err != nil {
+		log.Println("unable to load reminders: ", err)
+		return
+	}
+
+	for _, reminder := range reminders {
+		if reminder.Sent && !reminder.IsRecurring() {
+			continue
+		}
+		if _, err := scheduleReminder(reminder, s); err != nil {
+			log.Printf("unable to schedule reminder %d: %s\n", reminder.ID, err)
+		}
+	}
+}
+
+// Checks if a user can set a reminder for someone else.
+// If they can not the returned reason is sent back to the model
+func canRemindUser(guildID string, userID string, targetUserID string, s *Skippy) (bool, string, error) {
+	if targetUserID == userID {
+		return true, "", nil
+	}
+	if guildID == "" {
+		return false, "reminders for other people can only be set in a server", nil
+	}
+
+	optOut, err := s.DB.IsReminderOptOut(targetUserID)
+	if err != nil {
+		return false, "unable to check if the user allows reminders", err
+	}
+	if optOut {
+		return false, fmt.Sprintf("%s has opted out of reminders from other people", UserMention(targetUserID)), nil
+	}
+
+	if _, err := s.DiscordSession.GuildMember(guildID, targetUserID); err != nil {
+		return false, "that user is not in this server", nil
+	}
+	return true, "", nil
+}
+
+// Stops a user from getting reminders from other people and cancels the ones they have
+func optOutOfReminders(userID string, s *Skippy) error {
+	if err := s.DB.SetReminderOptOut(userID, true); err != nil {
+		return err
+	}
+
+	reminders, err := s.DB.GetRemindersByUser(userID)
+	if err != nil {
+		return err
+	}
+	for _, reminder := range reminders {
+		if reminder.UserID != userID {
+			cancelReminder(reminder.ID, s)
+		}
+	}
+	return nil
+}
+
+// Gets a timezone by name. Falls back to Config.Timezone and then the local timezone
+func getLocation(name string, config *Config) (*time.Location, error) {
+	if name == "" {
+		name = config.Timezone
+	}
+	if name == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(name)
+}
+
+// Parses an absolute reminder time in the given timezone
+func parseReminderTime(value string, location *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	for _, format := range REMINDER_TIME_FORMATS {
+		if t, err := time.ParseInLocation(format, value, location); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unable to parse time %s", value)
+}
+
+// Adds the timezone to a cron expression if it does not have one
+func makeCrontab(recurrence string, timezone string) string {
+	if timezone == "" || strings.HasPrefix(recurrence, CRON_TZ_PREFIX) || strings.HasPrefix(recurrence, "TZ=") {
+		return recurrence
+	}
+	return CRON_TZ_PREFIX + timezone + " " + recurrence
+}
+
+func formatSnoozeDuration(duration time.Duration) string {
+	if duration%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(duration.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(duration.Minutes()))
+}
+
+// Converts follow up minutes from a tool call. nil is kept so the default follow ups are used
+func makeFollowUps(minutes []int) []time.Duration {
+	if minutes == nil {
+		return nil
+	}
+	followUps := []time.Duration{}
+	for _, m := range minutes {
+		if m > 0 {
+			followUps = append(followUps, time.Duration(m)*time.Minute)
+		}
+	}
+	return followUps
+}
+
+func formatReminder(reminder Reminder) string {
+	when := fmt.Sprintf("<t:%d:R>", reminder.RemindAt.Unix())
+	if reminder.Sent && !reminder.IsRecurring() {
+		when = "waiting for a response"
+	}
+	if reminder.IsRecurring() {
+		when = fmt.Sprintf("repeats `%s` next %s", reminder.Recurrence, when)
+	}
+	if reminder.TargetUserID != "" && reminder.TargetUserID != reminder.UserID {
+		when = fmt.Sprintf("for %s from %s %s", UserMention(reminder.TargetUserID), UserMention(reminder.UserID), when)
+	}
+
+	where := fmt.Sprintf("in <#%s>", reminder.ChannelID)
+	if reminder.Delivery == REMINDER_DELIVERY_DM {
+		where = "by DM"
+	}
+	return fmt.Sprintf("`#%d` %s %s: %s", reminder.ID, when, where, reminder.Message)
+}
+
+func formatReminders(reminders []Reminder) string {
+	if len(reminders) == 0 {
+		return "You don't have any reminders"
+	}
+
+	var lines []string
+	for _, reminder := range reminders {
+		lines = append(lines, formatReminder(reminder))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,220 @@
+package skippy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	DEFAULT_REQUEST_TIMEOUT  = 60 * time.Second
+	DEFAULT_MAX_RETRIES      = 3
+	DEFAULT_RETRY_BASE_DELAY = 500 * time.Millisecond
+	DEFAULT_RETRY_MAX_DELAY  = 20 * time.Second
+	PROVIDER_SEPARATOR       = ":"
+	OPENAI_PROVIDER          = "openai"
+	GROQ_PROVIDER            = "groq"
+	GROQ_BASE_URL            = "https://api.groq.com/openai/v1/"
+	RATE_LIMITED_RESPONSE    = "I'm being rate limited right now. Give me a minute and try again."
+	OUTAGE_RESPONSE          = "I can't reach my brain right now, it looks like the AI service is having problems. Try again later."
+)
+
+// http client that records the Retry-After header of a response
+// into the *time.Duration stored in the request context with the RetryAfter key
+type retryAfterClient struct {
+	*http.Client
+}
+
+func (c *retryAfterClient) Do(req *http.Request) (*http.Response, error) {
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return resp, err
+	}
+	if retryAfter, ok := req.Context().Value(RetryAfter).(*time.Duration); ok {
+		*retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+	}
+	return resp, err
+}
+
+func NewAIClient(apiKey string, baseURL string) *openai.Client {
+	clientConfig := openai.DefaultConfig(apiKey)
+	if baseURL != "" {
+		clientConfig.BaseURL = baseURL
+	}
+	clientConfig.HTTPClient = &retryAfterClient{&http.Client{}}
+	return openai.NewClientWithConfig(clientConfig)
+}
+
+// Retry-After is either a number of seconds or an http date
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// Makes a completion request with retries and falls back to Config.FallbackModels
+// when the model keeps failing with retryable errors
+func makeRequest(ctx context.Context, req openai.ChatCompletionRequest, s *Skippy) (openai.ChatCompletionResponse, error) {
+	models := append([]string{req.Model}, s.Config.FallbackModels...)
+
+	var resp openai.ChatCompletionResponse
+	var err error
+	for i, model := range models {
+		if i > 0 && !canFallBack(model, req, s.Config) {
+			log.Printf("skipping fallback model %s since it can not handle the request\n", model)
+			continue
+		}
+		client, modelName := getClient(model, s)
+		req.Model = modelName
+
+		resp, err = makeRequestWithRetry(ctx, client, req, s)
+		if err == nil || !isRetryableError(err) || ctx.Err() != nil {
+			return resp, err
+		}
+		log.Printf("model %s failed: %s\n", model, err)
+	}
+
+	return resp, err
+}
+
+// Checks if a fallback model supports the tools and images of the request
+func canFallBack(model string, req openai.ChatCompletionRequest, config *Config) bool {
+	if len(req.Tools) > 0 && !supportsTools(model, config) {
+		return false
+	}
+	return !hasImageParts(req.Messages) || supportsVision(model, config)
+}
+
+func hasImageParts(messages []openai.ChatCompletionMessage) bool {
+	for _, message := range messages {
+		for _, part := range message.MultiContent {
+			if part.Type == openai.ChatMessagePartTypeImageURL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func makeRequestWithRetry(
+	ctx context.Context,
+	client *openai.Client,
+	req openai.ChatCompletionRequest,
+	s *Skippy,
+) (openai.ChatCompletionResponse, error) {
+	var resp openai.ChatCompletionResponse
+	var err error
+	for attempt := 0; attempt <= s.Config.MaxRetries; attempt++ {
+		var retryAfter time.Duration
+		attemptCtx, cancel := withOptionalTimeout(context.WithValue(ctx, RetryAfter, &retryAfter), s.Config.RequestTimeout)
+
+		startTime := time.Now()
+		resp, err = client.CreateChatCompletion(attemptCtx, req)
+		log.Println("Request took: ", time.Since(startTime))
+		cancel()
+
+		if err == nil || !isRetryableError(err) || attempt == s.Config.MaxRetries {
+			return resp, err
+		}
+
+		delay := getBackoff(attempt, s.Config)
+		if retryAfter > 0 {
+			// the server is asking us to wait longer than we are willing to
+			if retryAfter > s.Config.RetryMaxDelay {
+				return resp, err
+			}
+			delay = retryAfter
+		}
+
+		log.Printf("retrying request in %s after error: %s\n", delay, err)
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+
+	return resp, err
+}
+
+// adds a timeout to ctx if timeout is set
+func withOptionalTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Gets the client for a model in the form provider:model.
+// Models without a provider use the default client
+func getClient(model string, s *Skippy) (*openai.Client, string) {
+	provider, modelName, found := strings.Cut(model, PROVIDER_SEPARATOR)
+	if !found {
+		return s.AIClient, model
+	}
+	client, ok := s.Providers[provider]
+	if !ok {
+		log.Printf("unknown provider %s using default client\n", provider)
+		return s.AIClient, modelName
+	}
+	return client, modelName
+}
+
+// exponential backoff with jitter
+func getBackoff(attempt int, config *Config) time.Duration {
+	delay := config.RetryBaseDelay << attempt
+	if delay > config.RetryMaxDelay || delay <= 0 {
+		delay = config.RetryMaxDelay
+	}
+	if delay > 1 {
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)))
+	}
+	return delay
+}
+
+func getStatusCode(err error) int {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.HTTPStatusCode
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return reqErr.HTTPStatusCode
+	}
+	return 0
+}
+
+func isRateLimitError(err error) bool {
+	return getStatusCode(err) == http.StatusTooManyRequests
+}
+
+// outages are server errors, timeouts, and network errors
+func isOutageError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return getStatusCode(err) >= http.StatusInternalServerError
+}
+
+func isRetryableError(err error) bool {
+	return isRateLimitError(err) || isOutageError(err)
+}
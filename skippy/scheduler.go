@@ -2,20 +2,25 @@ package skippy
 
 import (
 	"fmt"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
 )
 
 const (
-	REMINDER_TAG    = "%s|REMINDER"
-	MORNING_MSG_TAG = "%s|MORNING_MSG"
-	DURATION_TAG    = "POLL"
-	DAILY_INTERVAL  = 1
+	REMINDER_TAG      = "%d|REMINDER"
+	REMINDER_NAG_TAG  = "%d|REMINDER_NAG"
+	DIGEST_TAG        = "%d|DIGEST"
+	WEATHER_WATCH_TAG = "%d|WEATHER_WATCH"
+	DURATION_TAG      = "POLL"
 )
 
 type Scheduler struct {
 	gocron.Scheduler
+	// jobs can be added by tools running in parallel
+	mu     sync.Mutex
 	jobSet map[string]bool
 }
 
@@ -40,17 +45,54 @@ func (s *Scheduler) AddDurationJob(duration time.Duration, jobFunc interface{})
 	if err != nil {
 		return err
 	}
-	s.jobSet[DURATION_TAG] = true
+	s.setJob(DURATION_TAG)
 	return nil
 }
 
 func (s *Scheduler) CancelDurationJob() {
 	s.RemoveByTags(DURATION_TAG)
-	delete(s.jobSet, DURATION_TAG)
+	s.deleteJob(DURATION_TAG)
 }
 
-func (s *Scheduler) AddReminderJob(channelID string, duration time.Duration, jobFunc interface{}) error {
-	tag := MakeReminderTag(channelID)
+// Adds a one time job that sends a reminder at a time
+func (s *Scheduler) AddReminderJob(reminderID uint, at time.Time, jobFunc interface{}) error {
+	tag := MakeReminderTag(reminderID)
+	_, err := s.NewJob(gocron.OneTimeJob(
+		gocron.OneTimeJobStartDateTime(at),
+	),
+		gocron.NewTask(jobFunc),
+		gocron.WithTags(tag),
+	)
+	if err != nil {
+		return err
+	}
+	s.setJob(tag)
+	return nil
+}
+
+// Adds a job that sends a reminder on a cron schedule. Returns the next time it runs
+func (s *Scheduler) AddRecurringReminderJob(reminderID uint, crontab string, jobFunc interface{}) (time.Time, error) {
+	tag := MakeReminderTag(reminderID)
+	job, err := s.NewJob(
+		gocron.CronJob(crontab, false),
+		gocron.NewTask(jobFunc),
+		gocron.WithTags(tag),
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.setJob(tag)
+	return job.NextRun()
+}
+
+func (s *Scheduler) NextReminderRun(reminderID uint) (time.Time, bool) {
+	return s.nextRun(MakeReminderTag(reminderID))
+}
+
+// Adds a follow up for a sent reminder. A reminder can have multiple
+// follow ups which are all canceled with CancelReminderNagJobs
+func (s *Scheduler) AddReminderNagJob(reminderID uint, duration time.Duration, jobFunc interface{}) error {
+	tag := MakeReminderNagTag(reminderID)
 	_, err := s.NewJob(gocron.OneTimeJob(
 		gocron.OneTimeJobStartDateTime(time.Now().Add(duration)),
 	),
@@ -60,64 +102,125 @@ func (s *Scheduler) AddReminderJob(channelID string, duration time.Duration, job
 	if err != nil {
 		return err
 	}
-	s.jobSet[tag] = true
+	s.setJob(tag)
 	return nil
 }
 
-func (s *Scheduler) CancelReminderJob(channelID string) {
-	tag := MakeReminderTag(channelID)
+func (s *Scheduler) CancelReminderNagJobs(reminderID uint) {
+	tag := MakeReminderNagTag(reminderID)
 	s.RemoveByTags(tag)
-	delete(s.jobSet, tag)
+	s.deleteJob(tag)
 }
 
-func (s *Scheduler) HasReminderJob(channelID string) bool {
-	_, ok := s.jobSet[MakeMorningMsgTag(channelID)]
-	return ok
+// Cancels the reminder and its follow ups
+func (s *Scheduler) CancelReminderJob(reminderID uint) {
+	tag := MakeReminderTag(reminderID)
+	s.RemoveByTags(tag)
+	s.deleteJob(tag)
+	s.CancelReminderNagJobs(reminderID)
+}
+
+func (s *Scheduler) HasReminderJob(reminderID uint) bool {
+	return s.hasJob(MakeReminderTag(reminderID))
+}
+
+// Adds a job that sends a digest on a cron schedule. Returns the next time it runs
+func (s *Scheduler) AddDigestJob(digestID uint, crontab string, jobFunc interface{}) (time.Time, error) {
+	tag := MakeDigestTag(digestID)
+	job, err := s.NewJob(
+		gocron.CronJob(crontab, false),
+		gocron.NewTask(jobFunc),
+		gocron.WithTags(tag),
+	)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.setJob(tag)
+	return job.NextRun()
+}
+
+func (s *Scheduler) NextDigestRun(digestID uint) (time.Time, bool) {
+	return s.nextRun(MakeDigestTag(digestID))
 }
 
-func (s *Scheduler) AddMorningMsgJob(
-	channelID string,
-	atTime time.Time,
-	jobFunc interface{},
-) error {
-	tag := MakeMorningMsgTag(channelID)
+func (s *Scheduler) CancelDigestJob(digestID uint) {
+	tag := MakeDigestTag(digestID)
+	s.RemoveByTags(tag)
+	s.deleteJob(tag)
+}
+
+func (s *Scheduler) HasDigestJob(digestID uint) bool {
+	return s.hasJob(MakeDigestTag(digestID))
+}
+
+// Adds a job that checks a weather watch at an interval
+func (s *Scheduler) AddWeatherWatchJob(watchID uint, interval time.Duration, jobFunc interface{}) error {
+	tag := MakeWeatherWatchTag(watchID)
 	_, err := s.NewJob(
-		gocron.DailyJob(
-			DAILY_INTERVAL,
-			gocron.NewAtTimes(
-				gocron.NewAtTime(
-					uint(atTime.Hour()),
-					uint(atTime.Minute()),
-					uint(atTime.Second()),
-				),
-			),
-		),
+		gocron.DurationJob(interval),
 		gocron.NewTask(jobFunc),
 		gocron.WithTags(tag),
 	)
 	if err != nil {
 		return err
 	}
-	s.jobSet[tag] = true
-
+	s.setJob(tag)
 	return nil
 }
 
-func (s *Scheduler) CancelMorningMsgJob(channelID string) {
-	tag := MakeMorningMsgTag(channelID)
+func (s *Scheduler) CancelWeatherWatchJob(watchID uint) {
+	tag := MakeWeatherWatchTag(watchID)
 	s.RemoveByTags(tag)
+	s.deleteJob(tag)
+}
+
+func (s *Scheduler) HasWeatherWatchJob(watchID uint) bool {
+	return s.hasJob(MakeWeatherWatchTag(watchID))
+}
+
+// Gets the next run of the first job with the tag
+func (s *Scheduler) nextRun(tag string) (time.Time, bool) {
+	for _, job := range s.Jobs() {
+		if !slices.Contains(job.Tags(), tag) {
+			continue
+		}
+		nextRun, err := job.NextRun()
+		return nextRun, err == nil
+	}
+	return time.Time{}, false
+}
+
+func (s *Scheduler) setJob(tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobSet[tag] = true
+}
+
+func (s *Scheduler) deleteJob(tag string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	delete(s.jobSet, tag)
 }
 
-func (s *Scheduler) HasMorningMsgJob(channelID string) bool {
-	_, ok := s.jobSet[MakeMorningMsgTag(channelID)]
+func (s *Scheduler) hasJob(tag string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.jobSet[tag]
 	return ok
 }
 
-func MakeReminderTag(channelID string) string {
-	return fmt.Sprintf(REMINDER_TAG, channelID)
+func MakeReminderTag(reminderID uint) string {
+	return fmt.Sprintf(REMINDER_TAG, reminderID)
+}
+
+func MakeReminderNagTag(reminderID uint) string {
+	return fmt.Sprintf(REMINDER_NAG_TAG, reminderID)
+}
+
+func MakeDigestTag(digestID uint) string {
+	return fmt.Sprintf(DIGEST_TAG, digestID)
 }
 
-func MakeMorningMsgTag(channelID string) string {
-	return fmt.Sprintf(MORNING_MSG_TAG, channelID)
+func MakeWeatherWatchTag(watchID uint) string {
+	return fmt.Sprintf(WEATHER_WATCH_TAG, watchID)
 }
@@ -307,10 +307,17 @@ func generateAndScheduleEvent(i *discordgo.InteractionCreate, activityName strin
 		content += UserMention(userID)
 	}
 
+	generateEventTool, err := TOOL_REGISTRY.Tool(GenerateEvent)
+	if err != nil {
+		log.Println("could not get generate event tool", err)
+		s.DiscordSession.ChannelMessageSend(i.ChannelID, ERROR_RESPONSE)
+		return
+	}
+
 	funcArgs, err := GetResponse(context.Background(), s, ResponseReq{
 		ChannelID:        i.ChannelID,
 		AdditionalInstructions:          content,
-		Tools:            []openai.Tool{GenerateEventTool},
+		Tools:            []openai.Tool{generateEventTool},
 		RequireTools:     true,
 		ReturnToolOutput: true,
 	})
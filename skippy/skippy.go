@@ -6,6 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"slices"
 	"time"
 
 	"skippybot/components"
@@ -27,13 +28,19 @@ const (
 )
 
 type Skippy struct {
-	DiscordSession   DiscordSession
-	AIClient         *openai.Client
+	DiscordSession DiscordSession
+	AIClient       *openai.Client
+	// provider name -> client used for fallback models
+	Providers        map[string]*openai.Client
 	State            *State
 	DB               Database
 	ComponentHandler *components.ComponentHandler
 	Config           *Config
 	Scheduler        *Scheduler
+	RateLimiter      *RateLimiter
+	Coalescer        *Coalescer
+	// classifies messages, image prompts and responses. moderation is skipped if nil
+	Moderator Moderator
 }
 
 // TODO: need to create option funcs to pass in here and read from env as default?
@@ -49,9 +56,14 @@ func NewSkippy(aiClientKey, discordToken string, botName BotName) *Skippy {
 	session.State.TrackChannels = true
 	session.State.TrackMembers = true
 
-	clientConfig := openai.DefaultConfig(aiClientKey)
-	// clientConfig.BaseURL = "https://api.groq.com/openai/v1/"
-	aiClient := openai.NewClientWithConfig(clientConfig)
+	aiClient := NewAIClient(aiClientKey, "")
+
+	providers := map[string]*openai.Client{
+		OPENAI_PROVIDER: aiClient,
+	}
+	if groqKey := os.Getenv("GROQ_API_KEY"); groqKey != "" {
+		providers[GROQ_PROVIDER] = NewAIClient(groqKey, GROQ_BASE_URL)
+	}
 
 	var instructionsFilePath string
 	switch botName {
@@ -76,6 +88,27 @@ func NewSkippy(aiClientKey, discordToken string, botName BotName) *Skippy {
 
 	instructions := string(content)
 
+	imageModel := os.Getenv("IMAGE_MODEL")
+	if imageModel == "" {
+		imageModel = DEFAULT_IMAGE_MODEL
+	}
+	imageModels := parseEnvList("IMAGE_MODELS")
+	if len(imageModels) == 0 {
+		imageModels = DEFAULT_IMAGE_MODELS
+	}
+	if !slices.Contains(imageModels, imageModel) {
+		imageModels = append(imageModels, imageModel)
+	}
+
+	moderationStrictness := os.Getenv("MODERATION_STRICTNESS")
+	if moderationStrictness == "" {
+		moderationStrictness = MODERATION_OFF
+	}
+	var moderator Moderator = NewOpenAIModerator(aiClient)
+	if blocklist := parseEnvList("MODERATION_BLOCKLIST"); len(blocklist) > 0 {
+		moderator = NewBlocklistModerator(blocklist)
+	}
+
 	log.Println("using instructions: ", instructions)
 
 	// TODO: should read this from the db first
@@ -90,12 +123,56 @@ func NewSkippy(aiClientKey, discordToken string, botName BotName) *Skippy {
 		},
 		// DefaultModel: "llama3-groq-70b-8192-tool-use-preview",
 		// DefaultModel: "llama-3.1-70b-versatile",
-		BaseInstructions: instructions,
-		DefaultModel:     openai.GPT4o,
-		UserConfigMap:    make(map[string]UserConfig),
-		StockAPIKey:      os.Getenv("ALPHA_VANTAGE_API_KEY"),
-		WeatherAPIKey:    os.Getenv("WEATHER_API_KEY"),
-		Name:             botName,
+		BaseInstructions:       instructions,
+		DefaultModel:           openai.GPT4o,
+		UserConfigMap:          make(map[string]UserConfig),
+		StockAPIKey:            os.Getenv("ALPHA_VANTAGE_API_KEY"),
+		WeatherAPIKey:          os.Getenv("WEATHER_API_KEY"),
+		StockAPIURL:            DEFAULT_STOCK_API_URL,
+		WeatherAPIURL:          DEFAULT_WEATHER_API_URL,
+		WeatherUnits:           os.Getenv("WEATHER_UNITS"),
+		WeatherWatchInterval:   DEFAULT_WEATHER_WATCH_INTERVAL,
+		MaxImageSize:           MAX_IMAGE_SIZE,
+		MaxImagesPerMessage:    MAX_IMAGES_PER_MESSAGE,
+		MaxFileSize:            MAX_FILE_SIZE,
+		MaxFileChars:           MAX_FILE_CHARS,
+		MaxReplyDepth:          MAX_REPLY_DEPTH,
+		ThreadScope:            ThreadScope(os.Getenv("THREAD_SCOPE")),
+		AutoCreateThreads:      os.Getenv("AUTO_CREATE_THREADS") == "true",
+		AutoThreadMessageCount: AUTO_THREAD_MESSAGE_COUNT,
+		ModelPrices:            DEFAULT_MODEL_PRICES,
+		ImagePrices:            DEFAULT_IMAGE_PRICES,
+		ImagePrice:             DEFAULT_IMAGE_PRICE,
+		ImageModel:             imageModel,
+		ImageModels:            imageModels,
+		UserDailyImageLimit:    parseEnvInt("USER_DAILY_IMAGE_LIMIT"),
+		GuildDailyBudget:       parseEnvFloat("GUILD_DAILY_BUDGET"),
+		UserDailyBudget:        parseEnvFloat("USER_DAILY_BUDGET"),
+		BudgetModel:            os.Getenv("BUDGET_MODEL"),
+		RateLimits: RateLimits{
+			User:    RateLimit{PerMinute: DEFAULT_USER_RATE, Burst: DEFAULT_USER_BURST},
+			Channel: RateLimit{PerMinute: DEFAULT_CHANNEL_RATE, Burst: DEFAULT_CHANNEL_BURST},
+		},
+		RateLimitBypassRoles: parseEnvList("RATE_LIMIT_BYPASS_ROLES"),
+		MessageCoalesceDelay: DEFAULT_COALESCE_DELAY,
+		RequestTimeout:       DEFAULT_REQUEST_TIMEOUT,
+		MaxRetries:           DEFAULT_MAX_RETRIES,
+		RetryBaseDelay:       DEFAULT_RETRY_BASE_DELAY,
+		RetryMaxDelay:        DEFAULT_RETRY_MAX_DELAY,
+		FallbackModels:       parseEnvList("FALLBACK_MODELS"),
+		VisionModels: []string{
+			openai.GPT4o,
+			openai.GPT4oMini,
+			openai.GPT4Turbo,
+		},
+		AllowedModels:        DEFAULT_ALLOWED_MODELS,
+		NoToolModels:         DEFAULT_NO_TOOL_MODELS,
+		ToolTimeout:          DEFAULT_TOOL_TIMEOUT,
+		DisabledTools:        parseEnvList("DISABLED_TOOLS"),
+		ModerationStrictness: moderationStrictness,
+		Timezone:             os.Getenv("TIMEZONE"),
+		NewsFeedURL:          os.Getenv("NEWS_FEED_URL"),
+		Name:                 botName,
 	}
 
 	log.Println("Connecting to db")
@@ -112,12 +189,16 @@ func NewSkippy(aiClientKey, discordToken string, botName BotName) *Skippy {
 	return &Skippy{
 		DiscordSession: NewDiscordBot(session),
 		AIClient:       aiClient,
+		Providers:      providers,
 		// TODO: does this work
 		ComponentHandler: components.NewComponentHandler(session),
 		Config:           config,
 		State:            NewState(),
 		DB:               db,
 		Scheduler:        scheduler,
+		RateLimiter:      NewRateLimiter(),
+		Coalescer:        NewCoalescer(config.MessageCoalesceDelay),
+		Moderator:        moderator,
 	}
 }
 
@@ -151,6 +232,11 @@ func (s *Skippy) Run() error {
 
 	s.Scheduler.Start()
 
+	RegisterReminderButtons(s)
+	loadReminders(s)
+	loadDigests(s)
+	loadWeatherWatches(s)
+
 	s.Scheduler.AddDurationJob(POLL_INTERVAL, func() {
 		PollPresenceStatus(context.Background(), s)
 	})
@@ -14,11 +14,10 @@ type State struct {
 }
 
 type ChatThread struct {
-	openAIThread   openai.Thread
-	awaitsResponse bool
-	alwaysRespond  bool
-	mu             sync.Mutex
-	messages       []openai.ChatCompletionMessage
+	openAIThread  openai.Thread
+	alwaysRespond bool
+	mu            sync.Mutex
+	messages      []openai.ChatCompletionMessage
 }
 
 func (thread *ChatThread) Lock() {
@@ -56,6 +55,53 @@ func (s *State) SetThreadMessages(threadID string, messages []openai.ChatComplet
 	s.threadMap[threadID].messages = messages
 }
 
+// Gets a copy of the messages held by a thread
+func (s *State) GetThreadMessages(threadID string) []openai.ChatCompletionMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	thread, exists := s.threadMap[threadID]
+	if !exists {
+		return nil
+	}
+	return append([]openai.ChatCompletionMessage{}, thread.messages...)
+}
+
+// Gets the number of messages held by a thread
+func (s *State) GetThreadLength(threadID string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	thread, exists := s.threadMap[threadID]
+	if !exists {
+		return 0
+	}
+	return len(thread.messages)
+}
+
+// Copies the messages from one thread to another creating it if needed
+func (s *State) CopyThread(fromThreadID string, toThreadID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, exists := s.threadMap[fromThreadID]
+	if !exists {
+		return
+	}
+	to, exists := s.threadMap[toThreadID]
+	if !exists {
+		to = &ChatThread{}
+		s.threadMap[toThreadID] = to
+	}
+	to.messages = append([]openai.ChatCompletionMessage{}, from.messages...)
+}
+
+// Clears the messages of a thread while keeping its settings
+func (s *State) ResetThread(threadID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if thread, exists := s.threadMap[threadID]; exists {
+		thread.messages = nil
+	}
+}
+
 func (s *State) UpdatePresence(userID string, opts ...UserPresenceOption) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -96,32 +142,23 @@ func (s *State) ToggleAlwaysRespond(threadID string, client *openai.Client) bool
 	return updateVal
 }
 
-func (s *State) GetAlwaysRespond(threadID string) bool {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	thread, exists := s.threadMap[threadID]
-	if !exists {
-		return false
-	}
-	return thread.alwaysRespond
-}
-
-func (s *State) SetAwaitsResponse(threadID string, awaitsResponse bool, client *openai.Client) {
+func (s *State) SetAlwaysRespond(threadID string, alwaysRespond bool) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, threadExists := s.threadMap[threadID]
-	if !threadExists {
-		s.NewThread(threadID)
+	thread, exists := s.threadMap[threadID]
+	if !exists {
+		thread = &ChatThread{}
+		s.threadMap[threadID] = thread
 	}
-	s.threadMap[threadID].awaitsResponse = awaitsResponse
+	thread.alwaysRespond = alwaysRespond
 }
 
-func (s *State) GetAwaitsResponse(threadID string) bool {
+func (s *State) GetAlwaysRespond(threadID string) bool {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	thread, exists := s.threadMap[threadID]
 	if !exists {
 		return false
 	}
-	return thread.awaitsResponse
+	return thread.alwaysRespond
 }
@@ -0,0 +1,427 @@
+package skippy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// most symbols that can be looked up in one tool call
+	MAX_STOCK_SYMBOLS = 10
+	// most days of history that can be requested
+	MAX_STOCK_HISTORY_DAYS = 30
+	// currency crypto is priced in when one is not given
+	DEFAULT_QUOTE_CURRENCY = "USD"
+)
+
+var (
+	SYMBOL_SEARCH_ENDPOINT = APIEndpoint{Name: "symbol search", TTL: 24 * time.Hour, Timeout: DEFAULT_API_TIMEOUT}
+	EXCHANGE_RATE_ENDPOINT = APIEndpoint{Name: "exchange rate", TTL: time.Minute, Timeout: DEFAULT_API_TIMEOUT}
+	DAILY_SERIES_ENDPOINT  = APIEndpoint{Name: "daily history", TTL: time.Hour, Timeout: DEFAULT_API_TIMEOUT}
+)
+
+// https://www.alphavantage.co/documentation/
+type GlobalQuote struct {
+	Symbol           string `json:"01. symbol"`
+	Open             string `json:"02. open"`
+	High             string `json:"03. high"`
+	Low              string `json:"04. low"`
+	Price            string `json:"05. price"`
+	Volume           string `json:"06. volume"`
+	LatestTradingDay string `json:"07. latest trading day"`
+	PreviousClose    string `json:"08. previous close"`
+	Change           string `json:"09. change"`
+	ChangePercent    string `json:"10. change percent"`
+}
+
+type ApiResponse struct {
+	GlobalQuote GlobalQuote `json:"Global Quote"`
+}
+
+type SymbolMatch struct {
+	Symbol     string `json:"1. symbol"`
+	Name       string `json:"2. name"`
+	Type       string `json:"3. type"`
+	Region     string `json:"4. region"`
+	Currency   string `json:"8. currency"`
+	MatchScore string `json:"9. matchScore"`
+}
+
+type SymbolSearchResponse struct {
+	BestMatches []SymbolMatch `json:"bestMatches"`
+}
+
+// used for both crypto and physical currencies
+type ExchangeRate struct {
+	FromCode      string `json:"1. From_Currency Code"`
+	FromName      string `json:"2. From_Currency Name"`
+	ToCode        string `json:"3. To_Currency Code"`
+	ToName        string `json:"4. To_Currency Name"`
+	Rate          string `json:"5. Exchange Rate"`
+	LastRefreshed string `json:"6. Last Refreshed"`
+	TimeZone      string `json:"7. Time Zone"`
+}
+
+type ExchangeRateResponse struct {
+	ExchangeRate ExchangeRate `json:"Realtime Currency Exchange Rate"`
+}
+
+type DailyBar struct {
+	Open   string `json:"1. open"`
+	High   string `json:"2. high"`
+	Low    string `json:"3. low"`
+	Close  string `json:"4. close"`
+	Volume string `json:"5. volume"`
+}
+
+// daily series keyed by date. only the series for the requested function is set
+type DailySeriesResponse struct {
+	Stock  map[string]DailyBar `json:"Time Series (Daily)"`
+	FX     map[string]DailyBar `json:"Time Series FX (Daily)"`
+	Crypto map[string]DailyBar `json:"Time Series (Digital Currency Daily)"`
+}
+
+// The parts of a quote given to the model
+type StockQuote struct {
+	Symbol           string  `json:"symbol"`
+	Price            float64 `json:"price"`
+	Change           float64 `json:"change"`
+	ChangePercent    float64 `json:"change_percent"`
+	PreviousClose    float64 `json:"previous_close"`
+	LatestTradingDay string  `json:"latest_trading_day"`
+}
+
+type ExchangeQuote struct {
+	From          string  `json:"from"`
+	To            string  `json:"to"`
+	Rate          float64 `json:"rate"`
+	LastRefreshed string  `json:"last_refreshed"`
+}
+
+type DailyPrice struct {
+	Date  string  `json:"date"`
+	Close float64 `json:"close"`
+	// change from the previous close. 0 for the oldest day
+	ChangePercent float64 `json:"change_percent"`
+}
+
+// The result of looking up one symbol. Error is set instead of failing the whole lookup
+type StockResult struct {
+	// what was asked for when it was resolved to a different symbol
+	Query   string         `json:"query,omitempty"`
+	Name    string         `json:"name,omitempty"`
+	Quote   *StockQuote    `json:"quote,omitempty"`
+	Rate    *ExchangeQuote `json:"rate,omitempty"`
+	History []DailyPrice   `json:"history,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type StockResults struct {
+	Stocks     []StockResult `json:"stocks,omitempty"`
+	Crypto     []StockResult `json:"crypto,omitempty"`
+	Currencies []StockResult `json:"currencies,omitempty"`
+}
+
+func (q GlobalQuote) ToStockQuote() (StockQuote, error) {
+	quote := StockQuote{Symbol: q.Symbol, LatestTradingDay: q.LatestTradingDay}
+	var err error
+	if quote.Price, err = strconv.ParseFloat(q.Price, 64); err != nil {
+		return quote, fmt.Errorf("invalid price %q", q.Price)
+	}
+	if quote.Change, err = strconv.ParseFloat(q.Change, 64); err != nil {
+		return quote, fmt.Errorf("invalid change %q", q.Change)
+	}
+	percent := strings.TrimSuffix(q.ChangePercent, "%")
+	if quote.ChangePercent, err = strconv.ParseFloat(percent, 64); err != nil {
+		return quote, fmt.Errorf("invalid change percent %q", q.ChangePercent)
+	}
+	if quote.PreviousClose, err = strconv.ParseFloat(q.PreviousClose, 64); err != nil {
+		return quote, fmt.Errorf("invalid previous close %q", q.PreviousClose)
+	}
+	return quote, nil
+}
+
+func (r ExchangeRate) ToExchangeQuote() (ExchangeQuote, error) {
+	rate, err := strconv.ParseFloat(r.Rate, 64)
+	if err != nil {
+		return ExchangeQuote{}, fmt.Errorf("invalid exchange rate %q", r.Rate)
+	}
+	return ExchangeQuote{From: r.FromCode, To: r.ToCode, Rate: rate, LastRefreshed: r.LastRefreshed}, nil
+}
+
+// Gets the last days of a series from oldest to newest
+func (r DailySeriesResponse) LastDays(days int) ([]DailyPrice, error) {
+	series := r.Stock
+	if series == nil {
+		series = r.FX
+	}
+	if series == nil {
+		series = r.Crypto
+	}
+
+	var dates []string
+	for date := range series {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	// one extra day is kept to get the change of the first day
+	if len(dates) > days+1 {
+		dates = dates[len(dates)-days-1:]
+	}
+
+	var prices []DailyPrice
+	for i, date := range dates {
+		price, err := strconv.ParseFloat(series[date].Close, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid close %q on %s", series[date].Close, date)
+		}
+		prices = append(prices, DailyPrice{Date: date, Close: price})
+		if i > 0 && prices[i-1].Close != 0 {
+			change := (price - prices[i-1].Close) / prices[i-1].Close * 100
+			prices[i].ChangePercent = roundTo(change, 2)
+		}
+	}
+	if len(prices) > days {
+		prices = prices[len(prices)-days:]
+	}
+	return prices, nil
+}
+
+func roundTo(value float64, places int) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'f', places, 64), 64)
+	return rounded
+}
+
+// Builds an Alpha Vantage request for the function
+func alphaVantageURL(config *Config, function string, params map[string]string) (string, error) {
+	u, err := url.Parse(config.StockAPIURL)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	q.Set("function", function)
+	for key, value := range params {
+		q.Set(key, value)
+	}
+	q.Set("apikey", config.StockAPIKey)
+
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func getStockQuote(ctx context.Context, symbol string, config *Config) (StockQuote, error) {
+	u, err := alphaVantageURL(config, "GLOBAL_QUOTE", map[string]string{"symbol": symbol})
+	if err != nil {
+		return StockQuote{}, err
+	}
+
+	apiResponse := ApiResponse{}
+	if err := getAPIJSON(ctx, STOCK_QUOTE_ENDPOINT, u, &apiResponse); err != nil {
+		return StockQuote{}, err
+	}
+	// unknown symbols return an empty quote
+	if apiResponse.GlobalQuote.Symbol == "" {
+		return StockQuote{}, errUnknownSymbol{symbol}
+	}
+
+	quote, err := apiResponse.GlobalQuote.ToStockQuote()
+	if err != nil {
+		return quote, err
+	}
+	log.Printf("the price of %s is %.2f\n", quote.Symbol, quote.Price)
+	return quote, nil
+}
+
+type errUnknownSymbol struct {
+	symbol string
+}
+
+func (e errUnknownSymbol) Error() string {
+	return fmt.Sprintf("no quote found for %s", e.symbol)
+}
+
+// Finds the best matching symbol for a company name or partial symbol
+func searchSymbol(ctx context.Context, keywords string, config *Config) (SymbolMatch, error) {
+	u, err := alphaVantageURL(config, "SYMBOL_SEARCH", map[string]string{"keywords": keywords})
+	if err != nil {
+		return SymbolMatch{}, err
+	}
+
+	var searchResponse SymbolSearchResponse
+	if err := getAPIJSON(ctx, SYMBOL_SEARCH_ENDPOINT, u, &searchResponse); err != nil {
+		return SymbolMatch{}, err
+	}
+	if len(searchResponse.BestMatches) == 0 {
+		return SymbolMatch{}, fmt.Errorf("no symbol found for %s", keywords)
+	}
+	// matches are sorted by score
+	return searchResponse.BestMatches[0], nil
+}
+
+func getExchangeRate(ctx context.Context, from string, to string, config *Config) (ExchangeQuote, error) {
+	u, err := alphaVantageURL(config, "CURRENCY_EXCHANGE_RATE", map[string]string{
+		"from_currency": from,
+		"to_currency":   to,
+	})
+	if err != nil {
+		return ExchangeQuote{}, err
+	}
+
+	var rateResponse ExchangeRateResponse
+	if err := getAPIJSON(ctx, EXCHANGE_RATE_ENDPOINT, u, &rateResponse); err != nil {
+		return ExchangeQuote{}, err
+	}
+	if rateResponse.ExchangeRate.FromCode == "" {
+		return ExchangeQuote{}, fmt.Errorf("no exchange rate found for %s/%s", from, to)
+	}
+	return rateResponse.ExchangeRate.ToExchangeQuote()
+}
+
+// Gets the daily closes of a series function such as TIME_SERIES_DAILY
+func getDailyHistory(
+	ctx context.Context,
+	function string,
+	params map[string]string,
+	days int,
+	config *Config,
+) ([]DailyPrice, error) {
+	u, err := alphaVantageURL(config, function, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var seriesResponse DailySeriesResponse
+	if err := getAPIJSON(ctx, DAILY_SERIES_ENDPOINT, u, &seriesResponse); err != nil {
+		return nil, err
+	}
+	return seriesResponse.LastDays(days)
+}
+
+// Gets a stock quote resolving company names to their symbol
+func getStockResult(ctx context.Context, query string, historyDays int, config *Config) StockResult {
+	result := StockResult{}
+	symbol := strings.ToUpper(strings.TrimSpace(query))
+
+	var quote StockQuote
+	var err error
+	// names with spaces can not be symbols
+	if strings.Contains(symbol, " ") {
+		err = errUnknownSymbol{symbol}
+	} else {
+		quote, err = getStockQuote(ctx, symbol, config)
+	}
+	if errors.As(err, &errUnknownSymbol{}) {
+		var match SymbolMatch
+		match, err = searchSymbol(ctx, strings.TrimSpace(query), config)
+		if err == nil {
+			result.Query = query
+			result.Name = match.Name
+			symbol = match.Symbol
+			quote, err = getStockQuote(ctx, symbol, config)
+		}
+	}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Quote = &quote
+
+	if historyDays > 0 {
+		result.History, err = getDailyHistory(ctx, "TIME_SERIES_DAILY", map[string]string{"symbol": symbol}, historyDays, config)
+		if err != nil {
+			result.Error = "unable to get history: " + err.Error()
+		}
+	}
+	return result
+}
+
+// Gets the price of a crypto currency or a currency pair. crypto history uses its own series
+func getExchangeResult(ctx context.Context, pair string, crypto bool, historyDays int, config *Config) StockResult {
+	result := StockResult{}
+	from, to, err := parseCurrencyPair(pair, crypto)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	rate, err := getExchangeRate(ctx, from, to, config)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Rate = &rate
+
+	if historyDays > 0 {
+		if crypto {
+			result.History, err = getDailyHistory(ctx, "DIGITAL_CURRENCY_DAILY", map[string]string{
+				"symbol": from,
+				"market": to,
+			}, historyDays, config)
+		} else {
+			result.History, err = getDailyHistory(ctx, "FX_DAILY", map[string]string{
+				"from_symbol": from,
+				"to_symbol":   to,
+			}, historyDays, config)
+		}
+		if err != nil {
+			result.Error = "unable to get history: " + err.Error()
+		}
+	}
+	return result
+}
+
+// Splits pairs like EUR/USD, EUR-USD or EURUSD. crypto without a pair is priced in DEFAULT_QUOTE_CURRENCY
+func parseCurrencyPair(pair string, crypto bool) (string, string, error) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	parts := strings.FieldsFunc(pair, func(r rune) bool {
+		return r == '/' || r == '-' || r == ' '
+	})
+	switch {
+	case len(parts) == 2:
+		return parts[0], parts[1], nil
+	case len(parts) == 1 && crypto:
+		return parts[0], DEFAULT_QUOTE_CURRENCY, nil
+	case len(parts) == 1 && len(pair) == 6:
+		return pair[:3], pair[3:], nil
+	default:
+		return "", "", fmt.Errorf("%s is not a currency pair like EUR/USD", pair)
+	}
+}
+
+// Looks up every symbol. Lookups that fail have their error set in the results
+func getStockResults(
+	ctx context.Context,
+	symbols []string,
+	crypto []string,
+	currencyPairs []string,
+	historyDays int,
+	config *Config,
+) (StockResults, error) {
+	if len(symbols)+len(crypto)+len(currencyPairs) == 0 {
+		return StockResults{}, fmt.Errorf("no symbols were given")
+	}
+	if len(symbols)+len(crypto)+len(currencyPairs) > MAX_STOCK_SYMBOLS {
+		return StockResults{}, fmt.Errorf("only %d symbols can be looked up at once", MAX_STOCK_SYMBOLS)
+	}
+	historyDays = min(max(historyDays, 0), MAX_STOCK_HISTORY_DAYS)
+
+	var results StockResults
+	for _, symbol := range symbols {
+		results.Stocks = append(results.Stocks, getStockResult(ctx, symbol, historyDays, config))
+	}
+	for _, coin := range crypto {
+		results.Crypto = append(results.Crypto, getExchangeResult(ctx, coin, true, historyDays, config))
+	}
+	for _, pair := range currencyPairs {
+		results.Currencies = append(results.Currencies, getExchangeResult(ctx, pair, false, historyDays, config))
+	}
+	return results, nil
+}
@@ -0,0 +1,107 @@
+package skippy
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// ThreadScope determines how conversations are split into ChatThreads
+type ThreadScope string
+
+const (
+	// one conversation per channel
+	SCOPE_CHANNEL ThreadScope = "channel"
+	// one conversation per discord thread. conversations in regular channels are split per user
+	SCOPE_DISCORD_THREAD ThreadScope = "thread"
+	// one conversation per user in each channel
+	SCOPE_USER ThreadScope = "user"
+
+	USER_THREAD_FORMAT        = "%s|%s"
+	AUTO_THREAD_ARCHIVE_MINS  = 60
+	AUTO_THREAD_NAME_MAX_LEN  = 100
+	AUTO_THREAD_DEFAULT_NAME  = "Conversation"
+	AUTO_THREAD_MESSAGE_COUNT = 20
+)
+
+// Gets the ChatThread id to use for a message from userID on channelID
+func GetThreadID(channelID string, userID string, s *Skippy) string {
+	switch s.Config.ThreadScope {
+	case SCOPE_USER:
+		return makeUserThreadID(channelID, userID)
+	case SCOPE_DISCORD_THREAD:
+		if isDiscordThread(channelID, s) {
+			return channelID
+		}
+		return makeUserThreadID(channelID, userID)
+	default:
+		return channelID
+	}
+}
+
+func makeUserThreadID(channelID string, userID string) string {
+	if userID == "" {
+		return channelID
+	}
+	return fmt.Sprintf(USER_THREAD_FORMAT, channelID, userID)
+}
+
+func isDiscordThread(channelID string, s *Skippy) bool {
+	channel, err := s.DiscordSession.GetState().Channel(channelID)
+	if err != nil {
+		return false
+	}
+	return channel.IsThread()
+}
+
+// Gets the ChatThread id for a discord thread that was just created.
+// New threads may not be in the state cache yet so isDiscordThread can't be used
+func getNewDiscordThreadID(channelID string, userID string, s *Skippy) string {
+	if s.Config.ThreadScope == SCOPE_USER {
+		return makeUserThreadID(channelID, userID)
+	}
+	return channelID
+}
+
+// Moves the author's long conversation into a new discord thread started from m.
+// The conversation history is copied over so it can continue in the thread and
+// is reset in the channel, otherwise every later mention would start another thread.
+// Returns the channel id and ChatThread id of the discord thread and true if one was created
+func maybeStartDiscordThread(m *discordgo.Message, threadID string, s *Skippy) (string, string, bool) {
+	if !s.Config.AutoCreateThreads || m.GuildID == "" || isDiscordThread(m.ChannelID, s) {
+		return "", "", false
+	}
+
+	if s.State.GetThreadLength(threadID) < s.Config.AutoThreadMessageCount {
+		return "", "", false
+	}
+
+	name := removeBotMention(m.Content, s.DiscordSession.GetState().User.ID)
+	if name == "" {
+		name = AUTO_THREAD_DEFAULT_NAME
+	}
+	if runes := []rune(name); len(runes) > AUTO_THREAD_NAME_MAX_LEN {
+		name = string(runes[:AUTO_THREAD_NAME_MAX_LEN])
+	}
+
+	channel, err := s.DiscordSession.MessageThreadStartComplex(m.ChannelID, m.ID, &discordgo.ThreadStart{
+		Name:                name,
+		AutoArchiveDuration: AUTO_THREAD_ARCHIVE_MINS,
+	})
+	if err != nil {
+		log.Println("unable to start discord thread: ", err)
+		return "", "", false
+	}
+
+	discordThreadID := getNewDiscordThreadID(channel.ID, m.Author.ID, s)
+	s.State.CopyThread(threadID, discordThreadID)
+	// always respond is set on the channel so it is kept in the thread
+	if s.State.GetAlwaysRespond(m.ChannelID) {
+		s.State.SetAlwaysRespond(channel.ID, true)
+	}
+	// start fresh in the channel since the conversation moved
+	s.State.ResetThread(threadID)
+
+	return channel.ID, discordThreadID, true
+}
@@ -3,47 +3,110 @@ package skippy
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
 	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/bwmarrin/discordgo"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 const (
 	// ai functions
-	GetStockPriceKey     string = "get_stock_price"
-	GetWeatherKey        string = "get_weather"
-	SendChannelMessage   string = "send_channel_message"
-	SetReminder          string = "set_reminder"
-	GenerateImage        string = "generate_image"
-	ToggleMorningMessage string = "set_morning_message"
-	GenerateEvent        string = "generate_event"
+	GetStockPriceKey      string = "get_stock_price"
+	GetWeatherKey         string = "get_weather"
+	SendChannelMessage    string = "send_channel_message"
+	SetReminder           string = "set_reminder"
+	ListReminders         string = "list_reminders"
+	CancelReminder        string = "cancel_reminder"
+	EditReminder          string = "edit_reminder"
+	SnoozeReminder        string = "snooze_reminder"
+	GenerateImage         string = "generate_image"
+	EditImage             string = "edit_image"
+	CreateImageVariation  string = "create_image_variation"
+	EnableMorningMessage  string = "enable_morning_message"
+	DisableMorningMessage string = "disable_morning_message"
+	UpdateMorningMessage  string = "update_morning_message"
+	GenerateEvent         string = "generate_event"
 )
 
-type FuncArgs struct {
-	JsonValue string
-	FuncName  string
-	ToolID    string
-}
-
 type StockFuncArgs struct {
-	Symbol string
+	// stock symbols or company names
+	Symbols []string `json:"symbols,omitempty"`
+	// crypto symbols optionally paired with a currency. ex: BTC or ETH/EUR
+	Crypto        []string `json:"crypto,omitempty"`
+	CurrencyPairs []string `json:"currency_pairs,omitempty"`
+	// days of daily closes to include. 0 for only the current price
+	HistoryDays int `json:"history_days,omitempty"`
 }
 
 type WeatherFuncArgs struct {
-	Location string
+	Location string `json:"location"`
+	// the user's preferred units if empty
+	Units string `json:"units,omitempty"`
+	Days  int    `json:"days,omitempty"`
+	// forecast hours to include. ex: 18:00 or 2024-08-02 18:00
+	HourlyFrom string `json:"hourly_from,omitempty"`
+	HourlyTo   string `json:"hourly_to,omitempty"`
 }
 
 type GenerateImageFuncArgs struct {
 	Prompt string `json:"prompt"`
+	// Config.ImageModel if empty
+	Model   string `json:"model,omitempty"`
+	Size    string `json:"size,omitempty"`
+	Quality string `json:"quality,omitempty"`
+	Style   string `json:"style,omitempty"`
+}
+
+// images are found by name in the user's message and the message they replied to
+type EditImageFuncArgs struct {
+	Prompt string `json:"prompt"`
+	// the first image if empty
+	Image string `json:"image,omitempty"`
+	// image whose transparent areas are edited
+	Mask string `json:"mask,omitempty"`
+	Size string `json:"size,omitempty"`
+}
+
+type ImageVariationFuncArgs struct {
+	// the first image if empty
+	Image string `json:"image,omitempty"`
+	Size  string `json:"size,omitempty"`
 }
 
 type MorningMsgFuncArgs struct {
-	Enable           bool     `json:"enable"`
+	// DEFAULT_DIGEST_NAME if empty
+	Name             string   `json:"name,omitempty"`
 	Time             string   `json:"time"`
+	Timezone         string   `json:"timezone,omitempty"`
+	Days             string   `json:"days,omitempty"`
 	WeatherLocations []string `json:"weather_locations,omitempty"`
 	Stocks           []string `json:"stocks,omitempty"`
+	Sections         []string `json:"sections,omitempty"`
+	FeedURL          string   `json:"feed_url,omitempty"`
+}
+
+// nil lists are left unchanged and empty lists clear them
+type UpdateMorningMsgFuncArgs struct {
+	// finds the digest to change. can be empty if the channel has one digest
+	Name             string   `json:"name,omitempty"`
+	Time             string   `json:"time,omitempty"`
+	Timezone         string   `json:"timezone,omitempty"`
+	Days             string   `json:"days,omitempty"`
+	WeatherLocations []string `json:"weather_locations"`
+	Stocks           []string `json:"stocks"`
+	Sections         []string `json:"sections"`
+	FeedURL          string   `json:"feed_url,omitempty"`
+}
+
+type DisableMorningMsgFuncArgs struct {
+	// every digest in the channel is disabled if empty
+	Name string `json:"name,omitempty"`
 }
 
 // used for
@@ -51,6 +114,50 @@ type ReminderFuncArgs struct {
 	Message     string `json:"message"`
 	TimerLength int    `json:"timer_length,omitempty"`
 	UserID      string `json:"user_id,omitempty"`
+	// the user the reminder is for if it is not the user that asked for it
+	TargetUserID string `json:"target_user_id,omitempty"`
+	// one of REMINDER_DELIVERY_CHANNEL or REMINDER_DELIVERY_DM
+	Delivery string `json:"delivery,omitempty"`
+	// channel to send the reminder in. the current channel if empty
+	ChannelID string `json:"channel_id,omitempty"`
+	// minutes after the reminder to follow up. nil for the default follow ups
+	FollowUpMinutes []int `json:"follow_up_minutes,omitempty"`
+	ReminderTimeFuncArgs
+}
+
+// when a reminder is sent. at most one of these should be set
+type ReminderTimeFuncArgs struct {
+	// absolute time in Timezone
+	Time string `json:"time,omitempty"`
+	// cron expression in Timezone
+	Recurrence string `json:"recurrence,omitempty"`
+	// IANA timezone. Config.Timezone is used if empty
+	Timezone string `json:"timezone,omitempty"`
+}
+
+// finds a reminder by id or by searching the reminder messages
+type FindReminderFuncArgs struct {
+	ID     uint   `json:"id,omitempty"`
+	Search string `json:"search,omitempty"`
+}
+
+type EditReminderFuncArgs struct {
+	FindReminderFuncArgs
+	Message     string `json:"message,omitempty"`
+	TimerLength int    `json:"timer_length,omitempty"`
+	// minutes after the reminder to follow up. nil to leave unchanged
+	FollowUpMinutes []int `json:"follow_up_minutes,omitempty"`
+	ReminderTimeFuncArgs
+}
+
+type SnoozeReminderFuncArgs struct {
+	FindReminderFuncArgs
+	Minutes int `json:"minutes,omitempty"`
+}
+
+type SendChannelMessageFuncArgs struct {
+	ChannelID string `json:"channel_id"`
+	Message   string `json:"message"`
 }
 
 type EventFuncArgs struct {
@@ -59,270 +166,658 @@ type EventFuncArgs struct {
 	NotificationMessage string `json:"notification_message"`
 }
 
+// Runs the tool calls in parallel and returns the outputs in call order.
+// Each tool gets its own timeout within the request context
 func GetToolOutputs(
 	ctx context.Context,
 	toolCalls []openai.ToolCall,
-	dgChannID string,
+	toolCtx ToolContext,
 	s *Skippy,
 ) []openai.ChatCompletionMessage {
-	var toolOutputs []openai.ChatCompletionMessage
-outerloop:
-	for _, toolCall := range toolCalls {
-		funcArg := FuncArgs{
-			ToolID:    toolCall.ID,
-			FuncName:  toolCall.Function.Name,
-			JsonValue: toolCall.Function.Arguments,
-		}
-
-		log.Printf("recieved function request:%+v", funcArg)
-		switch funcName := funcArg.FuncName; funcName {
-		case ToggleMorningMessage:
-			log.Println("toggle_morning_message()")
-			output, err := handleMorningMessage(
-				ctx,
-				funcArg,
-				dgChannID,
-				s,
-			)
-			if err != nil {
-				log.Println("error handling morning message: ", err)
-			}
-			// the bot will confuse multiple functions calls with this one so
-			// we only want to set the morning message if it is called
-			toolOutputs = makeNoOpToolMessage(toolCalls, funcArg.ToolID, output)
-			break outerloop
-		case GetStockPriceKey:
-			log.Println("get_stock_price()")
-
-			output, err := handleGetStockPrice(funcArg, s.Config.StockAPIKey)
-			if err != nil {
-				log.Println("error handling get_stock_price: ", err)
+	toolOutputs := make([]openai.ChatCompletionMessage, len(toolCalls))
+	var wg sync.WaitGroup
+	for i, toolCall := range toolCalls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			toolOutputs[i] = openai.ChatCompletionMessage{
+				ToolCallID: toolCall.ID,
+				Content:    runTool(ctx, toolCall, toolCtx, s),
+				Role:       openai.ChatMessageRoleTool,
 			}
+		}()
+	}
+	wg.Wait()
 
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: funcArg.ToolID, Content: output, Role: openai.ChatMessageRoleTool},
-			)
+	return toolOutputs
+}
 
-		case GetWeatherKey:
-			log.Println(GetWeatherKey)
+// Runs a single tool call and gets the output for the model
+func runTool(ctx context.Context, toolCall openai.ToolCall, toolCtx ToolContext, s *Skippy) string {
+	funcName := toolCall.Function.Name
+	toolCtx.ToolID = toolCall.ID
+	log.Printf("recieved function request:%s %s", funcName, toolCall.Function.Arguments)
 
-			output, err := handleGetWeather(funcArg, s)
-			if err != nil {
-				log.Println("error handling get_weather: ", err)
-			}
+	tool, ok := TOOL_REGISTRY.Get(funcName)
+	if !ok || tool.Handler == nil || !isToolEnabled(funcName, toolCtx.GuildID, s) {
+		return makeToolError(TOOL_ERROR_UNKNOWN, fmt.Sprintf("%s is not an available tool", funcName))
+	}
 
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: funcArg.ToolID, Content: output, Role: openai.ChatMessageRoleTool},
-			)
-		case GenerateImage:
-			log.Println(GenerateImage)
-			output, err := getAndSendImage(
-				context.Background(),
-				funcArg,
-				dgChannID,
-				s,
-			)
-			if err != nil {
-				log.Println("unable to get image: ", err)
-			}
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: funcArg.ToolID, Content: output, Role: openai.ChatMessageRoleTool},
-			)
-		case SetReminder:
-			log.Println("set_reminder()")
-			output, err := setReminder(
-				context.Background(),
-				funcArg,
-				dgChannID,
-				s,
-			)
-			if err != nil {
-				log.Println("error sending channel message: ", err)
+	timeout := tool.Timeout
+	if timeout == 0 {
+		timeout = s.Config.ToolTimeout
+	}
+	ctx, cancel := withOptionalTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		output string
+		err    error
+	}
+	// buffered so the handler can finish after a timeout
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("%s panicked: %v\n", funcName, r)
+				done <- result{output: makeToolError(TOOL_ERROR_FAILED, fmt.Sprintf("%s crashed", funcName))}
 			}
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: funcArg.ToolID, Content: output, Role: openai.ChatMessageRoleTool},
-			)
-		default:
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: funcArg.ToolID, Content: "unkown tool used", Role: openai.ChatMessageRoleTool},
-			)
+		}()
+		output, err := tool.Handler(ctx, toolCtx, toolCall.Function.Arguments, s)
+		done <- result{output: output, err: err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			log.Printf("error handling %s: %s\n", funcName, res.err)
 		}
+		if res.err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return makeToolTimeoutError(funcName, timeout)
+		}
+		return res.output
+	case <-ctx.Done():
+		log.Printf("%s did not finish: %s\n", funcName, ctx.Err())
+		return makeToolTimeoutError(funcName, timeout)
 	}
-
-	return toolOutputs
 }
 
-func handleGetWeather(funcArg FuncArgs, s *Skippy) (string, error) {
-	weatherFuncArgs := WeatherFuncArgs{}
-	err := json.Unmarshal([]byte(funcArg.JsonValue), &weatherFuncArgs)
+func handleGetWeather(ctx context.Context, toolCtx ToolContext, weatherFuncArgs WeatherFuncArgs, s *Skippy) (string, error) {
+	log.Println("getting weather for: ", weatherFuncArgs.Location)
+
+	units := weatherFuncArgs.Units
+	if units == "" {
+		units = getWeatherUnits(toolCtx.UserID, s)
+	}
+	report, err := getWeatherReport(ctx, WeatherRequest{
+		Location:   weatherFuncArgs.Location,
+		Units:      units,
+		Days:       weatherFuncArgs.Days,
+		HourlyFrom: weatherFuncArgs.HourlyFrom,
+		HourlyTo:   weatherFuncArgs.HourlyTo,
+	}, s.Config)
 	if err != nil {
-		log.Println("Could not unmarshal func args: ", string(funcArg.JsonValue))
-		return "Error deserializing data", err
+		log.Println("Unable to get weather: ", err)
+		return makeToolError(TOOL_ERROR_FAILED, "unable to get the weather: "+err.Error()), err
 	}
 
-	log.Println("getting weather for: ", weatherFuncArgs.Location)
+	// the key numbers are posted as is so the response does not need to repeat all of them
+	prefix := "the key numbers were already posted in an embed. only comment on what the user asked about and any alerts: "
+	if _, err := s.DiscordSession.ChannelMessageSendEmbed(toolCtx.ChannelID, makeWeatherEmbed(report)); err != nil {
+		log.Println("unable to send weather embed: ", err)
+		prefix = "the weather could not be posted in an embed. give the key numbers the user asked about and any alerts: "
+	}
 
-	output, err := getWeather(weatherFuncArgs.Location, s.Config.WeatherAPIKey)
+	output, err := formatWeatherReport(report)
 	if err != nil {
-		log.Println("Unable to get stock price: ", err)
-		return "There was a problem making that api call", err
+		return "unable to format the weather", err
 	}
+	return prefix + output, nil
+}
+
+func handleGetStockPrice(ctx context.Context, toolCtx ToolContext, stockFuncArgs StockFuncArgs, s *Skippy) (string, error) {
+	log.Println("getting price for: ", stockFuncArgs.Symbols, stockFuncArgs.Crypto, stockFuncArgs.CurrencyPairs)
 
-	return output, nil
+	results, err := getStockResults(
+		ctx,
+		stockFuncArgs.Symbols,
+		stockFuncArgs.Crypto,
+		stockFuncArgs.CurrencyPairs,
+		stockFuncArgs.HistoryDays,
+		s.Config,
+	)
+	if err != nil {
+		return makeToolError(TOOL_ERROR_FAILED, "unable to get the stock price: "+err.Error()), err
+	}
+
+	output, err := json.Marshal(results)
+	if err != nil {
+		return "unable to format the prices", err
+	}
+	return string(output), nil
+}
+
+func enableMorningMessage(
+	ctx context.Context,
+	toolCtx ToolContext,
+	morningMsgFuncArgs MorningMsgFuncArgs,
+	s *Skippy,
+) (string, error) {
+	name := morningMsgFuncArgs.Name
+	if name == "" {
+		name = DEFAULT_DIGEST_NAME
+	}
+
+	// a digest with the same name is replaced
+	digest, _, err := findChannelDigest(toolCtx.ChannelID, name, s)
+	if err != nil {
+		return "unable to get the digests in this channel", err
+	}
+	if digest == nil {
+		digest = &Digest{
+			GuildID:   toolCtx.GuildID,
+			ChannelID: toolCtx.ChannelID,
+			Name:      name,
+		}
+	}
+	digest.Time = morningMsgFuncArgs.Time
+	digest.Timezone = morningMsgFuncArgs.Timezone
+	digest.Days = morningMsgFuncArgs.Days
+	digest.WeatherLocations = morningMsgFuncArgs.WeatherLocations
+	digest.Stocks = morningMsgFuncArgs.Stocks
+	digest.Sections = morningMsgFuncArgs.Sections
+	digest.FeedURL = morningMsgFuncArgs.FeedURL
+
+	return saveMorningMsg(*digest, s)
 }
 
-func handleGetStockPrice(funcArg FuncArgs, apiKey string) (string, error) {
-	stockFuncArgs := StockFuncArgs{}
-	err := json.Unmarshal([]byte(funcArg.JsonValue), &stockFuncArgs)
+func disableMorningMessage(
+	ctx context.Context,
+	toolCtx ToolContext,
+	disableFuncArgs DisableMorningMsgFuncArgs,
+	s *Skippy,
+) (string, error) {
+	digests, err := s.DB.GetDigestsByChannel(toolCtx.ChannelID)
 	if err != nil {
-		log.Println("Could not unmarshal func args: ", string(funcArg.JsonValue))
-		return "Error deserializing data", err
+		return "unable to get the digests in this channel", err
+	}
+
+	var disabled []string
+	for _, digest := range digests {
+		if disableFuncArgs.Name != "" && !strings.EqualFold(digest.Name, disableFuncArgs.Name) {
+			continue
+		}
+		if err := deleteDigest(digest.ID, s); err != nil {
+			return "unable to disable the morning message", err
+		}
+		disabled = append(disabled, digest.Name)
 	}
 
-	log.Println("getting price for: ", stockFuncArgs.Symbol)
+	if len(disabled) == 0 {
+		return "there is no morning message to disable in this channel. these are the channel's digests:\n" +
+			formatDigests(digests), nil
+	}
+	return "disabled " + strings.Join(disabled, ", "), nil
+}
 
-	output, err := getStockPrice(stockFuncArgs.Symbol, apiKey)
+func updateMorningMessage(
+	ctx context.Context,
+	toolCtx ToolContext,
+	updateFuncArgs UpdateMorningMsgFuncArgs,
+	s *Skippy,
+) (string, error) {
+	digest, digests, err := findChannelDigest(toolCtx.ChannelID, updateFuncArgs.Name, s)
 	if err != nil {
-		log.Println("Unable to get stock price: ", err)
-		return "There was a problem making that api call", err
+		return "unable to get the digests in this channel", err
+	}
+	if len(digests) == 0 {
+		return "there is no morning message set in this channel. it needs to be enabled first", nil
+	}
+	if digest == nil {
+		return "ask the user which of the channel's digests they meant:\n" + formatDigests(digests), nil
+	}
+
+	if updateFuncArgs.Time != "" {
+		digest.Time = updateFuncArgs.Time
+	}
+	if updateFuncArgs.Timezone != "" {
+		digest.Timezone = updateFuncArgs.Timezone
+	}
+	if updateFuncArgs.Days != "" {
+		digest.Days = updateFuncArgs.Days
+	}
+	if updateFuncArgs.WeatherLocations != nil {
+		digest.WeatherLocations = updateFuncArgs.WeatherLocations
 	}
+	if updateFuncArgs.Stocks != nil {
+		digest.Stocks = updateFuncArgs.Stocks
+	}
+	if updateFuncArgs.Sections != nil {
+		digest.Sections = updateFuncArgs.Sections
+	}
+	if updateFuncArgs.FeedURL != "" {
+		digest.FeedURL = updateFuncArgs.FeedURL
+	}
+
+	return saveMorningMsg(*digest, s)
+}
 
-	return output, nil
+func saveMorningMsg(digest Digest, s *Skippy) (string, error) {
+	digest, err := saveDigest(digest, s)
+	if err != nil {
+		return fmt.Sprintf("unable to schedule the morning message: %s", err), nil
+	}
+	return "morning message set: " + formatDigestDetails(digest), nil
 }
 
-func handleMorningMessage(
+func getAndSendImage(
 	ctx context.Context,
-	funcArg FuncArgs,
-	dgChannID string,
+	toolCtx ToolContext,
+	generateImageFuncArgs GenerateImageFuncArgs,
 	s *Skippy,
 ) (string, error) {
-	morningMsgFuncArgs := MorningMsgFuncArgs{}
-	err := json.Unmarshal([]byte(funcArg.JsonValue), &morningMsgFuncArgs)
+	if isImagePromptFlagged(ctx, toolCtx, generateImageFuncArgs.Prompt, s) {
+		return makeToolError(TOOL_ERROR_FLAGGED, IMAGE_PROMPT_FLAGGED), nil
+	}
+
+	imgReq, err := makeImageRequest(generateImageFuncArgs.Prompt, ImageOptions{
+		Model:   generateImageFuncArgs.Model,
+		Size:    generateImageFuncArgs.Size,
+		Quality: generateImageFuncArgs.Quality,
+		Style:   generateImageFuncArgs.Style,
+	}, s.Config)
 	if err != nil {
-		log.Println("Could not unmarshal func args: ", string(funcArg.JsonValue))
-		return "Error deserializing data", err
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), nil
 	}
 
-	output := toggleMorningMsg(
-		ctx,
-		morningMsgFuncArgs,
-		dgChannID,
-		s,
-	)
+	generated, ok := reserveImage(toolCtx, imgReq, s)
+	if !ok {
+		return fmt.Sprintf(IMAGE_LIMIT_RESPONSE, s.Config.UserDailyImageLimit), nil
+	}
+	image, revisedPrompt, err := generateImage(ctx, imgReq, s.AIClient)
+	if err != nil {
+		releaseImage(generated, s)
+		log.Println("unable to generate images", err)
+		return "Unable to generate image", err
+	}
+	chargeImage(generated, s)
 
-	return output, nil
+	log.Printf("recieved %d byte image attempting to send on channel %s\n", len(image), toolCtx.ChannelID)
+	if err := sendImage(toolCtx.ChannelID, image, "", nonEmpty(revisedPrompt, imgReq.Prompt)[0], s); err != nil {
+		return "Unable to send the image", err
+	}
+	return "image generated and posted in the channel", nil
 }
 
-func toggleMorningMsg(
+func handleEditImage(
 	ctx context.Context,
-	morningMsgFuncArgs MorningMsgFuncArgs,
-	channelID string,
+	toolCtx ToolContext,
+	editFuncArgs EditImageFuncArgs,
 	s *Skippy,
-) string {
-	if !morningMsgFuncArgs.Enable {
-		s.Scheduler.CancelMorningMsgJob(channelID)
-		return "worked"
+) (string, error) {
+	if isImagePromptFlagged(ctx, toolCtx, editFuncArgs.Prompt, s) {
+		return makeToolError(TOOL_ERROR_FLAGGED, IMAGE_PROMPT_FLAGGED), nil
 	}
 
-	log.Println("Given time is: ", morningMsgFuncArgs.Time)
-	givenTime, err := ParseCommonTime(morningMsgFuncArgs.Time)
+	size, err := checkEditImageSize(editFuncArgs.Size)
+	if err != nil {
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), nil
+	}
+	input, err := findToolImage(toolCtx.Images, editFuncArgs.Image, editFuncArgs.Mask)
 	if err != nil {
-		return "could not format time"
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), nil
+	}
+	img, err := getEditImage(ctx, input, s)
+	if err != nil {
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), err
 	}
 
-	if s.Scheduler.HasMorningMsgJob(channelID) {
-		s.Scheduler.CancelMorningMsgJob(channelID)
+	var mask *image.NRGBA
+	if editFuncArgs.Mask != "" {
+		maskInput, err := findToolImage(toolCtx.Images, editFuncArgs.Mask, "")
+		if err != nil {
+			return makeToolError(TOOL_ERROR_FAILED, err.Error()), nil
+		}
+		if mask, err = getEditImage(ctx, maskInput, s); err != nil {
+			return makeToolError(TOOL_ERROR_FAILED, err.Error()), err
+		}
 	}
 
-	log.Println("Setting the Morning Msg for: ", givenTime)
+	generated, ok := reserveImage(toolCtx, openai.ImageRequest{Prompt: editFuncArgs.Prompt, Model: IMAGE_EDIT_MODEL, Size: size}, s)
+	if !ok {
+		return fmt.Sprintf(IMAGE_LIMIT_RESPONSE, s.Config.UserDailyImageLimit), nil
+	}
+	edited, err := editImage(ctx, editFuncArgs.Prompt, img, mask, size, s.AIClient)
+	if err != nil {
+		releaseImage(generated, s)
+		log.Println("unable to edit image", err)
+		return "Unable to edit the image", err
+	}
+	chargeImage(generated, s)
 
-	s.Scheduler.AddMorningMsgJob(
-		channelID,
-		givenTime,
-		func() {
-			sendMorningMsg(ctx, morningMsgFuncArgs, channelID, s)
-		},
-	)
+	if err := sendImage(toolCtx.ChannelID, edited, "Edited "+input.Name, editFuncArgs.Prompt, s); err != nil {
+		return "Unable to send the image", err
+	}
+	return "image edited and posted in the channel", nil
+}
 
-	return "worked"
+func handleCreateImageVariation(
+	ctx context.Context,
+	toolCtx ToolContext,
+	variationFuncArgs ImageVariationFuncArgs,
+	s *Skippy,
+) (string, error) {
+	size, err := checkEditImageSize(variationFuncArgs.Size)
+	if err != nil {
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), nil
+	}
+	input, err := findToolImage(toolCtx.Images, variationFuncArgs.Image, "")
+	if err != nil {
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), nil
+	}
+	img, err := getEditImage(ctx, input, s)
+	if err != nil {
+		return makeToolError(TOOL_ERROR_FAILED, err.Error()), err
+	}
+
+	generated, ok := reserveImage(toolCtx, openai.ImageRequest{Prompt: "variation of " + input.Name, Model: IMAGE_EDIT_MODEL, Size: size}, s)
+	if !ok {
+		return fmt.Sprintf(IMAGE_LIMIT_RESPONSE, s.Config.UserDailyImageLimit), nil
+	}
+	variation, err := createImageVariation(ctx, img, size, s.AIClient)
+	if err != nil {
+		releaseImage(generated, s)
+		log.Println("unable to create image variation", err)
+		return "Unable to create a variation of the image", err
+	}
+	chargeImage(generated, s)
+
+	if err := sendImage(toolCtx.ChannelID, variation, "Variation of "+input.Name, "", s); err != nil {
+		return "Unable to send the image", err
+	}
+	return "image variation posted in the channel", nil
 }
 
-func getAndSendImage(
+func handleSendChannelMessage(
 	ctx context.Context,
-	funcArg FuncArgs,
-	channelID string,
+	toolCtx ToolContext,
+	channelMsg SendChannelMessageFuncArgs,
 	s *Skippy,
 ) (string, error) {
-	generateImageFuncArgs := GenerateImageFuncArgs{}
+	channelID := parseChannelID(channelMsg.ChannelID)
+
+	if channelID != toolCtx.ChannelID {
+		inGuild, err := isGuildChannel(toolCtx.GuildID, channelID, s)
+		if err != nil {
+			return "Unable to find that channel", err
+		}
+		if !inGuild {
+			return "That channel is not in this server", nil
+		}
+		// the bot should not post anywhere the user can't post themselves
+		canSend, err := canSendMessages(toolCtx.UserID, channelID, s)
+		if err != nil {
+			return "Unable to check the user's permissions in that channel", err
+		}
+		if !canSend {
+			return "The user can not send messages in that channel", nil
+		}
+	}
 
-	err := json.Unmarshal([]byte(funcArg.JsonValue), &generateImageFuncArgs)
+	log.Printf("attempting to send message on %s\n", channelID)
+	err := sendChunkedChannelMessage(s.DiscordSession, channelID, channelMsg.Message)
 	if err != nil {
-		log.Println("Could not unmarshal func args: ", string(funcArg.JsonValue))
-		return "Unable to deserialize data", nil
+		return "Unable to send message", err
 	}
+	return "message sent", nil
+}
+
+// Gets the id from a channel mention. the model will sometimes use the mention instead of the id
+func parseChannelID(channelID string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(channelID, "<#"), ">")
+}
+
+// Gets the id from a user mention
+func parseUserID(userID string) string {
+	return strings.TrimSuffix(strings.TrimLeft(strings.TrimPrefix(userID, "<@"), "!"), ">")
+}
 
-	imgUrl, err := GetImgUrl(generateImageFuncArgs.Prompt, s.AIClient)
+// Checks if a user can send messages in a channel
+func canSendMessages(userID string, channelID string, s *Skippy) (bool, error) {
+	if userID == "" {
+		return false, nil
+	}
+	permissions, err := s.DiscordSession.UserChannelPermissions(userID, channelID)
 	if err != nil {
-		log.Println("unable to generate images", err)
-		return "Unable to generate image", err
+		return false, err
 	}
+	return permissions&discordgo.PermissionSendMessages != 0, nil
+}
 
-	log.Printf("recieved image url (%s) attempting to send on channel %s\n", imgUrl, channelID)
-	err = sendChunkedChannelMessage(s.DiscordSession, channelID, imgUrl)
-	return "image generated", err
+// Checks if a channel is in the guild
+func isGuildChannel(guildID string, channelID string, s *Skippy) (bool, error) {
+	if guildID == "" {
+		return false, nil
+	}
+	channels, err := s.DiscordSession.GuildChannels(guildID)
+	if err != nil {
+		return false, err
+	}
+	for _, channel := range channels {
+		if channel.ID == channelID {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func setReminder(
 	ctx context.Context,
-	funcArg FuncArgs,
-	channelID string,
+	toolCtx ToolContext,
+	channelMsg ReminderFuncArgs,
 	s *Skippy,
 ) (string, error) {
-	var channelMsg ReminderFuncArgs
+	userID := toolCtx.UserID
+	if userID == "" {
+		userID = channelMsg.UserID
+	}
 
-	err := json.Unmarshal([]byte(funcArg.JsonValue), &channelMsg)
+	remindAt, timezone, err := getReminderTime(channelMsg.TimerLength, channelMsg.ReminderTimeFuncArgs, s)
 	if err != nil {
-		log.Println("Could not unmarshal func args: ", string(funcArg.JsonValue))
-		return "Unable to deserialize data", err
+		return err.Error(), nil
+	}
+	if remindAt.IsZero() && channelMsg.Recurrence == "" {
+		return "a timer_length, time or recurrence is required", nil
 	}
 
-	duration := time.Duration(channelMsg.TimerLength) * time.Second
+	targetUserID := userID
+	if channelMsg.TargetUserID != "" {
+		targetUserID = parseUserID(channelMsg.TargetUserID)
+	}
+	ok, reason, err := canRemindUser(toolCtx.GuildID, userID, targetUserID, s)
+	if !ok {
+		return reason, err
+	}
 
-	log.Printf(
-		"attempting to send reminder on %s in %s\n",
-		channelID,
-		duration,
-	)
+	message := channelMsg.Message
+	channelID := toolCtx.ChannelID
+	delivery := REMINDER_DELIVERY_CHANNEL
+	switch {
+	case channelMsg.Delivery == REMINDER_DELIVERY_DM:
+		dmChannel, err := s.DiscordSession.UserChannelCreate(targetUserID)
+		if err != nil {
+			return "unable to DM that user", err
+		}
+		channelID = dmChannel.ID
+		delivery = REMINDER_DELIVERY_DM
+	case channelMsg.ChannelID != "" && parseChannelID(channelMsg.ChannelID) != toolCtx.ChannelID:
+		channelID = parseChannelID(channelMsg.ChannelID)
+		inGuild, err := isGuildChannel(toolCtx.GuildID, channelID, s)
+		if err != nil {
+			return "Unable to find that channel", err
+		}
+		if !inGuild {
+			return "That channel is not in this server", nil
+		}
+	}
+	// make sure the user knows the reminder is for them
+	if delivery == REMINDER_DELIVERY_CHANNEL && targetUserID != userID &&
+		!strings.Contains(message, UserMention(targetUserID)) {
+		message = UserMention(targetUserID) + " " + message
+	}
 
-	s.Scheduler.AddReminderJob(
-		channelID,
-		duration,
-		func() {
-			sendChunkedChannelMessage(s.DiscordSession, channelID, channelMsg.Message)
-			s.State.SetAwaitsResponse(channelID, true, s.AIClient)
-			for _, duration := range s.Config.ReminderDurations {
-				s.Scheduler.AddReminderJob(channelID, duration, func() {
-					sendAdditionalReminder(
-						ctx,
-						channelID,
-						channelMsg.UserID,
-						s,
-					)
-				})
-			}
-		},
-	)
+	reminder, err := createReminder(Reminder{
+		GuildID:      toolCtx.GuildID,
+		ChannelID:    channelID,
+		UserID:       userID,
+		TargetUserID: targetUserID,
+		Delivery:     delivery,
+		Message:      message,
+		RemindAt:     remindAt,
+		Recurrence:   channelMsg.Recurrence,
+		Timezone:     timezone,
+		FollowUps:    makeFollowUps(channelMsg.FollowUpMinutes),
+	}, s)
+	if err != nil {
+		return "unable to set the reminder", err
+	}
 
-	return "worked", nil
+	return "set " + formatReminder(reminder), nil
+}
+
+func listReminders(
+	ctx context.Context,
+	toolCtx ToolContext,
+	_ struct{},
+	s *Skippy,
+) (string, error) {
+	reminders, err := s.DB.GetRemindersByUser(toolCtx.UserID)
+	if err != nil {
+		return "unable to get the reminders", err
+	}
+	return formatReminders(reminders), nil
+}
+
+func handleCancelReminder(
+	ctx context.Context,
+	toolCtx ToolContext,
+	findFuncArgs FindReminderFuncArgs,
+	s *Skippy,
+) (string, error) {
+	reminder, output, ok := findReminder(toolCtx.UserID, REMINDER_ACTION_CANCEL, findFuncArgs, s)
+	if !ok {
+		return output, nil
+	}
+
+	cancelReminder(reminder.ID, s)
+	return fmt.Sprintf("canceled reminder #%d: %s", reminder.ID, reminder.Message), nil
+}
+
+func handleEditReminder(
+	ctx context.Context,
+	toolCtx ToolContext,
+	editFuncArgs EditReminderFuncArgs,
+	s *Skippy,
+) (string, error) {
+	reminder, output, ok := findReminder(toolCtx.UserID, REMINDER_ACTION_EDIT, editFuncArgs.FindReminderFuncArgs, s)
+	if !ok {
+		return output, nil
+	}
+
+	remindAt, timezone, err := getReminderTime(editFuncArgs.TimerLength, editFuncArgs.ReminderTimeFuncArgs, s)
+	if err != nil {
+		return err.Error(), nil
+	}
+	if editFuncArgs.Recurrence != "" || !remindAt.IsZero() {
+		reminder.Timezone = timezone
+	}
+	if editFuncArgs.FollowUpMinutes != nil {
+		reminder.FollowUps = makeFollowUps(editFuncArgs.FollowUpMinutes)
+	}
+
+	reminder, err = editReminder(reminder, editFuncArgs.Message, remindAt, editFuncArgs.Recurrence, s)
+	if err != nil {
+		return "unable to edit the reminder", err
+	}
+	return "updated " + formatReminder(reminder), nil
+}
+
+func handleSnoozeReminder(
+	ctx context.Context,
+	toolCtx ToolContext,
+	snoozeFuncArgs SnoozeReminderFuncArgs,
+	s *Skippy,
+) (string, error) {
+	reminder, output, ok := findReminder(toolCtx.UserID, REMINDER_ACTION_SNOOZE, snoozeFuncArgs.FindReminderFuncArgs, s)
+	if !ok {
+		return output, nil
+	}
+
+	minutes := snoozeFuncArgs.Minutes
+	if minutes <= 0 {
+		minutes = DEFAULT_SNOOZE_MINUTES
+	}
+
+	reminder, err := snoozeReminder(reminder, time.Duration(minutes)*time.Minute, s)
+	if err != nil {
+		return "unable to snooze the reminder", err
+	}
+	return "snoozed " + formatReminder(reminder), nil
+}
+
+// Gets when a reminder should be sent from a timer length or absolute time
+// and the timezone it is in. The time is zero if neither is set
+func getReminderTime(timerLength int, args ReminderTimeFuncArgs, s *Skippy) (time.Time, string, error) {
+	location, err := getLocation(args.Timezone, s.Config)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("unknown timezone %s", args.Timezone)
+	}
+
+	switch {
+	case args.Time != "":
+		remindAt, err := parseReminderTime(args.Time, location)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("unable to read the time %s. use the format YYYY-MM-DDTHH:MM", args.Time)
+		}
+		if remindAt.Before(time.Now()) {
+			return time.Time{}, "", fmt.Errorf("%s is in the past", args.Time)
+		}
+		return remindAt, location.String(), nil
+	case timerLength > 0:
+		return time.Now().Add(time.Duration(timerLength) * time.Second), location.String(), nil
+	default:
+		return time.Time{}, location.String(), nil
+	}
+}
+
+// Finds exactly one reminder the user can take action on. If none or more than one
+// match the returned output explains why to the model
+func findReminder(userID string, action string, findFuncArgs FindReminderFuncArgs, s *Skippy) (Reminder, string, bool) {
+	if findFuncArgs.ID == 0 && findFuncArgs.Search == "" {
+		return Reminder{}, "an id or search is required", false
+	}
+
+	reminders, err := findReminders(userID, action, findFuncArgs.ID, findFuncArgs.Search, s)
+	if err != nil {
+		log.Println("unable to find reminders: ", err)
+		return Reminder{}, "unable to get the user's reminders", false
+	}
+	switch len(reminders) {
+	case 0:
+		userReminders, _ := s.DB.GetRemindersByUser(userID)
+		return Reminder{}, fmt.Sprintf("no reminders the user can %s matched. only the creator can cancel or edit a reminder. these are the user's reminders:\n", action) +
+			formatReminders(userReminders), false
+	case 1:
+		return reminders[0], "", true
+	default:
+		return Reminder{}, "more than one reminder matched. ask the user which one they meant:\n" +
+			formatReminders(reminders), false
+	}
 }
 
 func sendAdditionalReminder(
 	ctx context.Context,
+	guildID string,
 	channelID string,
 	userID string,
 	s *Skippy,
@@ -344,6 +839,7 @@ func sendAdditionalReminder(
 		ctx,
 		s,
 		ResponseReq{
+			GuildID:      guildID,
 			ChannelID:    channelID,
 			UserID:       userID,
 			Message:      message,
@@ -354,39 +850,19 @@ func sendAdditionalReminder(
 
 func sendMorningMsg(
 	ctx context.Context,
-	morningMsgFuncArgs MorningMsgFuncArgs,
-	channelID string,
+	digest Digest,
 	s *Skippy,
 ) {
-	message := "Please tell everyone @here good morning."
-	for _, location := range morningMsgFuncArgs.WeatherLocations {
-		weather, err := getWeather(location, s.Config.WeatherAPIKey)
-		if err != nil {
-			log.Printf("unable to get weather for %s: %s\n", location, err)
-			continue
-		}
-		message += location + ":" + weather
-	}
-
-	for _, stock := range morningMsgFuncArgs.Stocks {
-		stockPrice, err := getStockPrice(stock, s.Config.StockAPIKey)
-		if err != nil {
-			log.Printf("unable to get weather for %s: %s\n", stock, err)
-			continue
-		}
-		message += stock + ":" + stockPrice
-	}
+	channelID := digest.ChannelID
+	message := BuildDigestPrompt(ctx, digest, s)
 
 	log.Println("getting morning message with prompt: ", message)
 
-	// TODO: remove
-	// reset the thread every morning
-	s.State.NewThread(channelID)
-
 	getAndSendResponse(
 		ctx,
 		s,
 		ResponseReq{
+			GuildID:                digest.GuildID,
 			ChannelID:              channelID,
 			Message:                message,
 			AdditionalInstructions: MORNING_MESSAGE_INSTRUCTIONS,
@@ -394,23 +870,3 @@ func sendMorningMsg(
 		},
 	)
 }
-
-// Creates a list of no-op tool outputs except for the provided toolID and output
-func makeNoOpToolMessage(tools []openai.ToolCall, toolID string, output string) []openai.ChatCompletionMessage {
-	var toolOutputs []openai.ChatCompletionMessage
-	for _, tool := range tools {
-		if tool.ID == toolID {
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: toolID, Content: output, Role: openai.ChatMessageRoleTool},
-			)
-		} else {
-			toolOutputs = append(
-				toolOutputs,
-				openai.ChatCompletionMessage{ToolCallID: tool.ID, Content: "no-op", Role: openai.ChatMessageRoleTool},
-			)
-		}
-	}
-
-	return toolOutputs
-}
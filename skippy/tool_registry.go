@@ -0,0 +1,260 @@
+package skippy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+	"github.com/sashabaranov/go-openai/jsonschema"
+)
+
+// Information about where a tool was called from
+type ToolContext struct {
+	GuildID   string
+	ChannelID string
+	UserID    string
+	ToolID    string
+	// images from the user's message followed by the images in the message they replied to
+	Images []ImageInput
+}
+
+// Handles a tool call with the raw json arguments from the model.
+// The returned string is sent back to the model as the tool output
+type ToolHandler func(ctx context.Context, toolCtx ToolContext, args string, s *Skippy) (string, error)
+
+type RegisteredTool struct {
+	Definition openai.FunctionDefinition
+	Handler    ToolHandler
+	// how long the handler has to finish. Config.ToolTimeout is used if 0
+	Timeout time.Duration
+}
+
+const (
+	DEFAULT_TOOL_TIMEOUT = 15 * time.Second
+	TOOL_ERROR_TIMEOUT   = "timeout"
+	TOOL_ERROR_UNKNOWN   = "unknown_tool"
+	TOOL_ERROR_FAILED    = "failed"
+	TOOL_ERROR_FLAGGED   = "flagged"
+)
+
+// Returned to the model in place of the tool output when a tool can not be run
+type ToolError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// Holds the definitions and handlers for the tools the model can call.
+// Definitions are loaded from json files and handlers are registered by name
+type ToolRegistry struct {
+	tools map[string]*RegisteredTool
+}
+
+type functionDefinition struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	Parameters  *jsonschema.Definition `json:"parameters"`
+}
+
+// Creates a registry with the definitions from every json file in fsys
+func NewToolRegistry(fsys fs.FS) (*ToolRegistry, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ToolRegistry{
+		tools: make(map[string]*RegisteredTool),
+	}
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		var def functionDefinition
+		if err := json.Unmarshal(data, &def); err != nil {
+			return nil, fmt.Errorf("unable to read tool definition %s: %w", file, err)
+		}
+		if def.Name == "" {
+			return nil, fmt.Errorf("tool definition %s is missing a name", file)
+		}
+		if _, ok := r.tools[def.Name]; ok {
+			return nil, fmt.Errorf("duplicate tool definition %s", def.Name)
+		}
+
+		r.tools[def.Name] = &RegisteredTool{
+			Definition: openai.FunctionDefinition{
+				Name:        def.Name,
+				Description: def.Description,
+				Parameters:  def.Parameters,
+			},
+		}
+	}
+
+	return r, nil
+}
+
+// Sets the handler for a defined tool
+func (r *ToolRegistry) Register(name string, handler ToolHandler) error {
+	tool, ok := r.tools[name]
+	if !ok {
+		return fmt.Errorf("no definition for tool %s", name)
+	}
+	tool.Handler = handler
+	return nil
+}
+
+func (r *ToolRegistry) SetTimeout(name string, timeout time.Duration) error {
+	tool, ok := r.tools[name]
+	if !ok {
+		return fmt.Errorf("no definition for tool %s", name)
+	}
+	tool.Timeout = timeout
+	return nil
+}
+
+// Registers a handler that receives the tool arguments decoded into T
+func RegisterTool[T any](
+	r *ToolRegistry,
+	name string,
+	handler func(ctx context.Context, toolCtx ToolContext, args T, s *Skippy) (string, error),
+) error {
+	return r.Register(name, decodeArgs(name, handler))
+}
+
+func decodeArgs[T any](
+	name string,
+	handler func(ctx context.Context, toolCtx ToolContext, args T, s *Skippy) (string, error),
+) ToolHandler {
+	return func(ctx context.Context, toolCtx ToolContext, jsonArgs string, s *Skippy) (string, error) {
+		var args T
+		if err := json.Unmarshal([]byte(jsonArgs), &args); err != nil {
+			log.Printf("Could not unmarshal %s args: %s\n", name, jsonArgs)
+			return "Error deserializing data", err
+		}
+		return handler(ctx, toolCtx, args, s)
+	}
+}
+
+func (r *ToolRegistry) Get(name string) (*RegisteredTool, bool) {
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// Gets the openai tool for a definition. Tools without a handler
+// can still be used to get structured output with ResponseReq.ReturnToolOutput
+func (r *ToolRegistry) Tool(name string) (openai.Tool, error) {
+	tool, ok := r.tools[name]
+	if !ok {
+		return openai.Tool{}, fmt.Errorf("no definition for tool %s", name)
+	}
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &tool.Definition,
+	}, nil
+}
+
+// Gets every tool with a handler sorted by name
+func (r *ToolRegistry) Tools() []openai.Tool {
+	return r.filter(func(string) bool { return true })
+}
+
+// Gets the tools that are enabled in a guild.
+// Config.DisabledTools applies to every guild and the tools turned off with /tools to a single guild
+func (r *ToolRegistry) GuildTools(guildID string, s *Skippy) []openai.Tool {
+	disabled := getDisabledTools(guildID, s)
+	return r.filter(func(name string) bool {
+		return !slices.Contains(disabled, name)
+	})
+}
+
+func (r *ToolRegistry) filter(include func(string) bool) []openai.Tool {
+	var names []string
+	for name, tool := range r.tools {
+		if tool.Handler != nil && include(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	tools := []openai.Tool{}
+	for _, name := range names {
+		tools = append(tools, openai.Tool{
+			Type:     openai.ToolTypeFunction,
+			Function: &r.tools[name].Definition,
+		})
+	}
+	return tools
+}
+
+// Tools turned off in a guild with /tools
+type ToolSetting struct {
+	GuildID       string   `gorm:"primaryKey"`
+	DisabledTools []string `gorm:"serializer:json"`
+}
+
+// Gets the tools turned off for every guild and the ones turned off in the guild
+func getDisabledTools(guildID string, s *Skippy) []string {
+	disabled := slices.Clone(s.Config.DisabledTools)
+	if guildID == "" {
+		return disabled
+	}
+	setting, err := s.DB.GetToolSetting(guildID)
+	if err != nil {
+		log.Println("unable to get guild tools: ", err)
+		return disabled
+	}
+	return append(disabled, setting.DisabledTools...)
+}
+
+func isToolEnabled(name string, guildID string, s *Skippy) bool {
+	return !slices.Contains(getDisabledTools(guildID, s), name)
+}
+
+func makeToolChoices(tools []openai.Tool) []*discordgo.ApplicationCommandOptionChoice {
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for i, tool := range tools {
+		if i >= MAX_COMMAND_CHOICES {
+			break
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  tool.Function.Name,
+			Value: tool.Function.Name,
+		})
+	}
+	return choices
+}
+
+func formatToolSetting(setting ToolSetting, config *Config) string {
+	content := "All tools are on in this server"
+	if len(setting.DisabledTools) > 0 {
+		content = "Tools turned off in this server: " + strings.Join(setting.DisabledTools, ", ")
+	}
+	if len(config.DisabledTools) > 0 {
+		content += "\nTools turned off for every server: " + strings.Join(config.DisabledTools, ", ")
+	}
+	return content
+}
+
+func makeToolError(kind string, message string) string {
+	output, err := json.Marshal(ToolError{Error: kind, Message: message})
+	if err != nil {
+		return message
+	}
+	return string(output)
+}
+
+func makeToolTimeoutError(name string, timeout time.Duration) string {
+	return makeToolError(
+		TOOL_ERROR_TIMEOUT,
+		fmt.Sprintf("%s did not finish within %s. Let the user know it took too long and they can try again", name, timeout),
+	)
+}
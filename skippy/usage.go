@@ -0,0 +1,278 @@
+package skippy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+const (
+	DEFAULT_IMAGE_PRICE       = 0.04
+	DEFAULT_USAGE_DAYS        = 30
+	BUDGET_EXCEEDED_RESPONSE  = "%s has burned through the spending budget for today. Try again tomorrow."
+	USAGE_SUMMARY_FIELD_LIMIT = 10
+)
+
+var ErrBudgetExceeded = errors.New("spending budget exceeded")
+
+// The model, size and quality an image is priced by
+type ImagePriceKey struct {
+	Model   string
+	Size    string
+	Quality string
+}
+
+// price in dollars per image
+var DEFAULT_IMAGE_PRICES = map[ImagePriceKey]float64{
+	{openai.CreateImageModelDallE3, openai.CreateImageSize1024x1024, openai.CreateImageQualityStandard}: 0.04,
+	{openai.CreateImageModelDallE3, openai.CreateImageSize1024x1792, openai.CreateImageQualityStandard}: 0.08,
+	{openai.CreateImageModelDallE3, openai.CreateImageSize1792x1024, openai.CreateImageQualityStandard}: 0.08,
+	{openai.CreateImageModelDallE3, openai.CreateImageSize1024x1024, openai.CreateImageQualityHD}:       0.08,
+	{openai.CreateImageModelDallE3, openai.CreateImageSize1024x1792, openai.CreateImageQualityHD}:       0.12,
+	{openai.CreateImageModelDallE3, openai.CreateImageSize1792x1024, openai.CreateImageQualityHD}:       0.12,
+	{openai.CreateImageModelDallE2, openai.CreateImageSize1024x1024, ""}:                                0.02,
+	{openai.CreateImageModelDallE2, openai.CreateImageSize512x512, ""}:                                  0.018,
+	{openai.CreateImageModelDallE2, openai.CreateImageSize256x256, ""}:                                  0.016,
+}
+
+// price in dollars per million tokens
+type ModelPrice struct {
+	Prompt     float64
+	Completion float64
+}
+
+var DEFAULT_MODEL_PRICES = map[string]ModelPrice{
+	openai.GPT4o:         {Prompt: 5, Completion: 15},
+	openai.GPT4o20240806: {Prompt: 2.5, Completion: 10},
+	openai.GPT4oMini:     {Prompt: 0.15, Completion: 0.6},
+	openai.GPT4Turbo:     {Prompt: 10, Completion: 30},
+	openai.GPT3Dot5Turbo: {Prompt: 0.5, Completion: 1.5},
+}
+
+// A record of the tokens and calls used by a single response
+type Usage struct {
+	ID               uint `gorm:"primaryKey"`
+	GuildID          string
+	ChannelID        string
+	UserID           string
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	ToolCalls        int
+	// images that were made
+	ImageCalls int
+	// cost in dollars of the images that were made. included in Cost
+	ImageCost float64 `gorm:"-"`
+	// cost in dollars
+	Cost      float64
+	CreatedAt time.Time
+}
+
+// Daily spending limit set for a guild with /budget overriding Config.GuildDailyBudget
+type BudgetSetting struct {
+	GuildID string `gorm:"primaryKey"`
+	// daily limit in dollars. 0 for no limit
+	DailyBudget float64
+}
+
+// Total usage grouped by user and model
+type UsageSummary struct {
+	UserID           string
+	Model            string
+	Requests         int
+	PromptTokens     int
+	CompletionTokens int
+	ImageCalls       int
+	Cost             float64
+}
+
+func (u *Usage) addResponse(resp openai.ChatCompletionResponse) {
+	// the model may be different from the requested one when a fallback is used
+	if resp.Model != "" {
+		u.Model = resp.Model
+	}
+	u.PromptTokens += resp.Usage.PromptTokens
+	u.CompletionTokens += resp.Usage.CompletionTokens
+	// images are charged by the tools once they are made
+	for _, choice := range resp.Choices {
+		u.ToolCalls += len(choice.Message.ToolCalls)
+	}
+}
+
+func calculateCost(u Usage, config *Config) float64 {
+	cost := u.ImageCost
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 {
+		return cost
+	}
+	price, ok := getModelPrice(u.Model, config)
+	if !ok {
+		log.Printf("no price configured for model %s\n", u.Model)
+	}
+	cost += float64(u.PromptTokens) * price.Prompt / 1_000_000
+	cost += float64(u.CompletionTokens) * price.Completion / 1_000_000
+	return cost
+}
+
+// Gets the price of an image that was made using the same defaults as the image tools.
+// Falls back to Config.ImagePrice for images without a configured price
+func getImagePrice(image GeneratedImage, config *Config) float64 {
+	key := ImagePriceKey{Model: image.Model, Size: image.Size, Quality: image.Quality}
+	if key.Model == "" {
+		key.Model = config.ImageModel
+	}
+	if key.Size == "" {
+		key.Size = DEFAULT_IMAGE_SIZE
+	}
+	if slices.Contains(NO_IMAGE_STYLE_MODELS, key.Model) {
+		key.Quality = ""
+	} else if key.Quality == "" {
+		key.Quality = openai.CreateImageQualityStandard
+	}
+
+	if price, ok := config.ImagePrices[key]; ok {
+		return price
+	}
+	log.Printf("no price configured for %s %s %s image\n", key.Model, key.Size, key.Quality)
+	return config.ImagePrice
+}
+
+// Gets the price for a model. Responses include the model version (gpt-4o-mini-2024-07-18)
+// so fall back to the longest configured model name that prefixes it
+func getModelPrice(model string, config *Config) (ModelPrice, bool) {
+	if price, ok := config.ModelPrices[model]; ok {
+		return price, true
+	}
+	var match string
+	for name := range config.ModelPrices {
+		if strings.HasPrefix(model, name) && len(name) > len(match) {
+			match = name
+		}
+	}
+	price, ok := config.ModelPrices[match]
+	return price, ok
+}
+
+func recordUsage(u Usage, s *Skippy) {
+	if u.PromptTokens == 0 && u.CompletionTokens == 0 && u.ImageCost == 0 {
+		return
+	}
+	u.Cost = calculateCost(u, s.Config)
+	log.Printf("tokens used: %d prompt %d completion $%.4f\n", u.PromptTokens, u.CompletionTokens, u.Cost)
+	if err := s.DB.CreateUsage(&u); err != nil {
+		log.Println("unable to record usage: ", err)
+	}
+}
+
+// Gets the model to use for a request within the guild and user budgets.
+// When a budget is exceeded the BudgetModel is used if configured
+// otherwise ErrBudgetExceeded is returned
+func getBudgetModel(model string, guildID string, userID string, s *Skippy) (string, error) {
+	overBudget, err := isOverBudget(guildID, userID, s)
+	if err != nil {
+		log.Println("unable to check budget: ", err)
+		return model, nil
+	}
+	if !overBudget {
+		return model, nil
+	}
+	if s.Config.BudgetModel == "" {
+		return "", ErrBudgetExceeded
+	}
+	log.Printf("budget exceeded for guild %s user %s using %s\n", guildID, userID, s.Config.BudgetModel)
+	return s.Config.BudgetModel, nil
+}
+
+func isOverBudget(guildID string, userID string, s *Skippy) (bool, error) {
+	startOfDay := startOfDay(time.Now())
+
+	if guildBudget := getGuildBudget(guildID, s); guildBudget > 0 && guildID != "" {
+		cost, err := s.DB.GetUsageCost(guildID, "", startOfDay)
+		if err != nil {
+			return false, err
+		}
+		if cost >= guildBudget {
+			return true, nil
+		}
+	}
+
+	if s.Config.UserDailyBudget > 0 && userID != "" {
+		cost, err := s.DB.GetUsageCost("", userID, startOfDay)
+		if err != nil {
+			return false, err
+		}
+		if cost >= s.Config.UserDailyBudget {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Gets the daily budget set for the guild falling back to Config.GuildDailyBudget
+func getGuildBudget(guildID string, s *Skippy) float64 {
+	if guildID != "" {
+		setting, err := s.DB.GetBudgetSetting(guildID)
+		if err != nil {
+			log.Println("unable to get guild budget: ", err)
+		} else if setting != nil {
+			return setting.DailyBudget
+		}
+	}
+	return s.Config.GuildDailyBudget
+}
+
+func formatBudget(budget float64, spent float64) string {
+	if budget <= 0 {
+		return fmt.Sprintf("There is no daily budget. $%.2f has been spent today", spent)
+	}
+	return fmt.Sprintf("The daily budget is $%.2f. $%.2f has been spent today", budget, spent)
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+func formatUsageSummary(summaries []UsageSummary, days int) string {
+	if len(summaries) == 0 {
+		return fmt.Sprintf("No usage in the last %d days", days)
+	}
+
+	var total float64
+	var tokens int
+	var images int
+	userCosts := make(map[string]float64)
+	var users []string
+	for _, summary := range summaries {
+		total += summary.Cost
+		tokens += summary.PromptTokens + summary.CompletionTokens
+		images += summary.ImageCalls
+		if _, ok := userCosts[summary.UserID]; !ok {
+			users = append(users, summary.UserID)
+		}
+		userCosts[summary.UserID] += summary.Cost
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return userCosts[users[i]] > userCosts[users[j]]
+	})
+
+	content := fmt.Sprintf("**Usage for the last %d days**\n", days)
+	content += fmt.Sprintf("Total: $%.2f, %d tokens, %d images\n", total, tokens, images)
+	for i, userID := range users {
+		if i >= USAGE_SUMMARY_FIELD_LIMIT {
+			break
+		}
+		name := "scheduled"
+		if userID != "" {
+			name = UserMention(userID)
+		}
+		content += fmt.Sprintf("- %s: $%.2f\n", name, userCosts[userID])
+	}
+
+	return content
+}
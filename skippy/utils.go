@@ -1,13 +1,18 @@
 package skippy
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"slices"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -68,23 +73,73 @@ func isMentioned(mentions []*discordgo.User, currUser *discordgo.User) bool {
 	return false
 }
 
-func ParseCommonTime(timeString string) (time.Time, error) {
-	timeFmt := "15:04"
-	parsedTime, err := time.Parse(timeFmt, timeString)
+// Reads a float from an environment variable. Returns 0 if it is not set or invalid
+func parseEnvFloat(key string) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("invalid value for %s: %s\n", key, err)
+		return 0
+	}
+	return f
+}
+
+// Reads an int from an environment variable. Returns 0 if it is not set or invalid
+func parseEnvInt(key string) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0
+	}
+	i, err := strconv.Atoi(value)
 	if err != nil {
-		log.Printf("could not format time: %s. attempting to use AM/PM format", err)
+		log.Printf("invalid value for %s: %s\n", key, err)
+		return 0
+	}
+	return i
+}
+
+// Reads a comma separated list from an environment variable
+func parseEnvList(key string) []string {
+	return parseList(os.Getenv(key))
+}
 
-		timeFmt := "3:04 PM"
-		parsedTime, err = time.Parse(timeFmt, timeString)
-		if err != nil {
-			return parsedTime, err
+// Splits a comma separated list and removes empty items
+func parseList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
 		}
 	}
+	return list
+}
 
-	return parsedTime, nil
+// formats accepted by ParseCommonTime
+var COMMON_TIME_FORMATS = []string{
+	"15:04",
+	"3:04 PM",
+	"3:04PM",
+	"3 PM",
+	"3PM",
+}
+
+// Parses a time of day. The date and timezone of the returned time should be ignored
+func ParseCommonTime(timeString string) (time.Time, error) {
+	timeString = strings.ToUpper(strings.TrimSpace(timeString))
+	for _, timeFmt := range COMMON_TIME_FORMATS {
+		if parsedTime, err := time.Parse(timeFmt, timeString); err == nil {
+			return parsedTime, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not parse time of day %s", timeString)
 }
 
-//lint:ignore U1000 saving for later
 func removeQuery(url string) string {
 	// Find the index of the first occurrence of "?"
 	index := strings.Index(url, "?")
@@ -98,21 +153,125 @@ func removeQuery(url string) string {
 	return url
 }
 
-//lint:ignore U1000 saving for later
-func downloadAttachment(url string, filename string) error {
-	resp, err := http.Get(url)
+// Downloads the content at url. Returns an error if the content is larger than maxSize bytes.
+// A maxSize of 0 or less is no limit
+func downloadAttachment(ctx context.Context, client *http.Client, url string, maxSize int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	log.Println("download successful attempting to write")
-	out, err := os.Create(filename)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to download attachment: %s", resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if maxSize > 0 {
+		// read one extra byte to detect content over the limit
+		body = io.LimitReader(resp.Body, maxSize+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize > 0 && int64(len(data)) > maxSize {
+		return nil, fmt.Errorf("attachment is larger than %d bytes", maxSize)
+	}
+
+	log.Println("download successful")
+	return data, nil
+}
+
+// Checks that a url given by a user is http or https and does not point at a
+// private, loopback or link local address so it can't be used to reach internal services
+func checkPublicURL(ctx context.Context, rawURL string, config *Config) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return fmt.Errorf("%s is not an http or https url", rawURL)
+	}
+	if config.AllowPrivateURLs {
+		return nil
+	}
+	return checkPublicHost(ctx, parsed.Hostname())
+}
+
+func checkPublicHost(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("unable to look up %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !isPublicIP(addr.IP) {
+			return fmt.Errorf("%s is not a public address", host)
+		}
+	}
+	return nil
+}
+
+// Gets the client for urls given by users. The host of a url can resolve to a
+// different address or redirect after it is checked so the client checks every
+// connection it makes unless Config.AllowPrivateURLs is set
+func GetPublicClient(config *Config) *http.Client {
+	if config.AllowPrivateURLs {
+		return http.DefaultClient
+	}
+	return publicClient
+}
+
+var publicClient = newPublicClient()
+
+func newPublicClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be the address that is checked instead of the host
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: 30 * time.Second,
+		Control: checkPublicDial,
+	}).DialContext
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= MAX_REDIRECTS {
+				return fmt.Errorf("stopped after %d redirects", MAX_REDIRECTS)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirected to %s which is not an http or https url", req.URL)
+			}
+			return checkPublicHost(req.Context(), req.URL.Hostname())
+		},
+	}
+}
+
+// Rejects connections to addresses that are not public. The address is already resolved
+// so a host can't switch to a private address after it was looked up
+func checkPublicDial(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
 	if err != nil {
 		return err
 	}
-	defer out.Close()
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("%s is not a public address", host)
+	}
+	return nil
+}
 
-	_, err = io.Copy(out, resp.Body)
-	return err
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// Cuts text to fit in one of discord's embed limits. The limits count characters
+// and the ellipsis that is added is counted too
+func truncateEmbedText(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-1]) + "…"
 }
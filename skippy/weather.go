@@ -0,0 +1,393 @@
+package skippy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	WEATHER_UNITS_IMPERIAL = "imperial"
+	WEATHER_UNITS_METRIC   = "metric"
+	// most forecast days WeatherAPI returns. free plans get less
+	MAX_WEATHER_FORECAST_DAYS = 14
+	// time format of forecast hours. ex: 2024-08-02 18:00
+	WEATHER_HOUR_FORMAT = "2006-01-02 15:04"
+	WEATHER_EMBED_COLOR = 0x3498db
+	// alerts shown in the embed so it stays under discord's 6000 character total
+	MAX_WEATHER_EMBED_ALERTS = 2
+)
+
+// us epa index -> description
+var AIR_QUALITY_CATEGORIES = map[int]string{
+	1: "Good",
+	2: "Moderate",
+	3: "Unhealthy for sensitive groups",
+	4: "Unhealthy",
+	5: "Very unhealthy",
+	6: "Hazardous",
+}
+
+// A user's preferred weather units
+type WeatherPreference struct {
+	UserID string `gorm:"primaryKey"`
+	Units  string
+}
+
+type WeatherRequest struct {
+	Location string
+	// WEATHER_UNITS_IMPERIAL or WEATHER_UNITS_METRIC
+	Units string
+	// days to forecast starting today
+	Days int
+	// forecast hours to include. 15:04 for today or 2006-01-02 15:04. no hours are included if empty
+	HourlyFrom string
+	HourlyTo   string
+}
+
+// Compact weather given to the model and shown in the embed
+type WeatherReport struct {
+	Location  string          `json:"location"`
+	LocalTime string          `json:"local_time"`
+	Units     WeatherUnits    `json:"units"`
+	Current   CurrentWeather  `json:"current"`
+	Days      []DailyWeather  `json:"days"`
+	Hours     []HourlyWeather `json:"hours,omitempty"`
+	Alerts    []WeatherAlert  `json:"alerts,omitempty"`
+}
+
+// the units of the report's numbers
+type WeatherUnits struct {
+	Temperature   string `json:"temperature"`
+	Wind          string `json:"wind"`
+	Precipitation string `json:"precipitation"`
+}
+
+type CurrentWeather struct {
+	Temperature   float64           `json:"temperature"`
+	FeelsLike     float64           `json:"feels_like"`
+	Condition     string            `json:"condition"`
+	Humidity      int               `json:"humidity"`
+	Wind          float64           `json:"wind"`
+	WindDirection string            `json:"wind_direction"`
+	Precipitation float64           `json:"precipitation"`
+	UV            float64           `json:"uv"`
+	AirQuality    *AirQualityReport `json:"air_quality,omitempty"`
+}
+
+type AirQualityReport struct {
+	USEPAIndex int     `json:"us_epa_index"`
+	Category   string  `json:"category"`
+	PM2_5      float64 `json:"pm2_5"`
+}
+
+type DailyWeather struct {
+	Date          string  `json:"date"`
+	High          float64 `json:"high"`
+	Low           float64 `json:"low"`
+	Condition     string  `json:"condition"`
+	ChanceOfRain  int     `json:"chance_of_rain"`
+	ChanceOfSnow  int     `json:"chance_of_snow"`
+	Precipitation float64 `json:"precipitation"`
+	MaxWind       float64 `json:"max_wind"`
+	Sunrise       string  `json:"sunrise"`
+	Sunset        string  `json:"sunset"`
+}
+
+type HourlyWeather struct {
+	Time          string  `json:"time"`
+	Temperature   float64 `json:"temperature"`
+	Condition     string  `json:"condition"`
+	ChanceOfRain  int     `json:"chance_of_rain"`
+	ChanceOfSnow  int     `json:"chance_of_snow"`
+	Precipitation float64 `json:"precipitation"`
+	Wind          float64 `json:"wind"`
+}
+
+type WeatherAlert struct {
+	Event       string `json:"event"`
+	Headline    string `json:"headline"`
+	Severity    string `json:"severity"`
+	Urgency     string `json:"urgency"`
+	Areas       string `json:"areas,omitempty"`
+	Effective   string `json:"effective"`
+	Expires     string `json:"expires"`
+	Instruction string `json:"instruction,omitempty"`
+}
+
+func getWeatherData(ctx context.Context, location string, days int, config *Config) (WeatherData, error) {
+	u, err := url.Parse(config.WeatherAPIURL + "/forecast.json")
+	if err != nil {
+		return WeatherData{}, err
+	}
+
+	q := u.Query()
+	q.Set("key", config.WeatherAPIKey)
+	q.Set("q", location)
+	q.Set("days", strconv.Itoa(days))
+	q.Set("aqi", "yes")
+	q.Set("alerts", "yes")
+
+	u.RawQuery = q.Encode()
+
+	weatherData := WeatherData{}
+	err = getAPIJSON(ctx, WEATHER_FORECAST_ENDPOINT, u.String(), &weatherData)
+	return weatherData, err
+}
+
+func getWeatherReport(ctx context.Context, request WeatherRequest, config *Config) (WeatherReport, error) {
+	request.Days = min(max(request.Days, 1), MAX_WEATHER_FORECAST_DAYS)
+	if request.Units != WEATHER_UNITS_METRIC {
+		request.Units = WEATHER_UNITS_IMPERIAL
+	}
+
+	weatherData, err := getWeatherData(ctx, request.Location, request.Days, config)
+	if err != nil {
+		return WeatherReport{}, err
+	}
+	return makeWeatherReport(weatherData, request)
+}
+
+func makeWeatherReport(weatherData WeatherData, request WeatherRequest) (WeatherReport, error) {
+	metric := request.Units == WEATHER_UNITS_METRIC
+	pick := func(imperial float64, metricValue float64) float64 {
+		if metric {
+			return metricValue
+		}
+		return imperial
+	}
+
+	location := weatherData.Location
+	report := WeatherReport{
+		Location:  strings.Join(nonEmpty(location.Name, location.Region, location.Country), ", "),
+		LocalTime: location.Localtime,
+		Units:     WeatherUnits{Temperature: "F", Wind: "mph", Precipitation: "in"},
+	}
+	if metric {
+		report.Units = WeatherUnits{Temperature: "C", Wind: "kph", Precipitation: "mm"}
+	}
+
+	current := weatherData.Current
+	report.Current = CurrentWeather{
+		Temperature:   pick(current.TempF, current.TempC),
+		FeelsLike:     pick(current.FeelslikeF, current.FeelslikeC),
+		Condition:     current.Condition.Text,
+		Humidity:      current.Humidity,
+		Wind:          pick(current.WindMph, current.WindKph),
+		WindDirection: current.WindDir,
+		Precipitation: pick(current.PrecipIn, current.PrecipMm),
+		UV:            current.UV,
+	}
+	if current.AirQuality != nil {
+		report.Current.AirQuality = &AirQualityReport{
+			USEPAIndex: current.AirQuality.USEPAIndex,
+			Category:   AIR_QUALITY_CATEGORIES[current.AirQuality.USEPAIndex],
+			PM2_5:      current.AirQuality.PM2_5,
+		}
+	}
+
+	from, to, err := getHourlyWindow(request, weatherData)
+	if err != nil {
+		return report, err
+	}
+	for _, forecastDay := range weatherData.Forecast.Forecastday {
+		day := forecastDay.Day
+		report.Days = append(report.Days, DailyWeather{
+			Date:          forecastDay.Date,
+			High:          pick(day.MaxtempF, day.MaxtempC),
+			Low:           pick(day.MintempF, day.MintempC),
+			Condition:     day.Condition.Text,
+			ChanceOfRain:  day.DailyChanceOfRain,
+			ChanceOfSnow:  day.DailyChanceOfSnow,
+			Precipitation: pick(day.TotalprecipIn, day.TotalprecipMm),
+			MaxWind:       pick(day.MaxwindMph, day.MaxwindKph),
+			Sunrise:       forecastDay.Astro.Sunrise,
+			Sunset:        forecastDay.Astro.Sunset,
+		})
+
+		if from == "" {
+			continue
+		}
+		for _, hour := range forecastDay.Hour {
+			if hour.Time < from || hour.Time > to {
+				continue
+			}
+			report.Hours = append(report.Hours, HourlyWeather{
+				Time:          hour.Time,
+				Temperature:   pick(hour.TempF, hour.TempC),
+				Condition:     hour.Condition.Text,
+				ChanceOfRain:  hour.ChanceOfRain,
+				ChanceOfSnow:  hour.ChanceOfSnow,
+				Precipitation: pick(hour.PrecipIn, hour.PrecipMm),
+				Wind:          pick(hour.WindMph, hour.WindKph),
+			})
+		}
+	}
+
+	for _, alert := range weatherData.Alerts.Alert {
+		report.Alerts = append(report.Alerts, WeatherAlert{
+			Event:       alert.Event,
+			Headline:    alert.Headline,
+			Severity:    alert.Severity,
+			Urgency:     alert.Urgency,
+			Areas:       alert.Areas,
+			Effective:   alert.Effective,
+			Expires:     alert.Expires,
+			Instruction: alert.Instruction,
+		})
+	}
+	return report, nil
+}
+
+// Gets the window of forecast hours as WEATHER_HOUR_FORMAT strings so they can be compared with Hour.Time.
+// Times without a date are on the first forecast day. A single time gets that hour
+func getHourlyWindow(request WeatherRequest, weatherData WeatherData) (string, string, error) {
+	if request.HourlyFrom == "" && request.HourlyTo == "" {
+		return "", "", nil
+	}
+
+	today := strings.Split(weatherData.Location.Localtime, " ")[0]
+	if len(weatherData.Forecast.Forecastday) > 0 {
+		today = weatherData.Forecast.Forecastday[0].Date
+	}
+	parse := func(value string) (string, error) {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "-") {
+			hour, err := ParseCommonTime(value)
+			if err != nil {
+				return "", err
+			}
+			value = today + " " + hour.Format("15:04")
+		}
+		if _, err := time.Parse(WEATHER_HOUR_FORMAT, value); err != nil {
+			return "", fmt.Errorf("invalid forecast hour %s", value)
+		}
+		return value, nil
+	}
+
+	from, err := parse(nonEmpty(request.HourlyFrom, request.HourlyTo)[0])
+	if err != nil {
+		return "", "", err
+	}
+	to, err := parse(nonEmpty(request.HourlyTo, request.HourlyFrom)[0])
+	if err != nil {
+		return "", "", err
+	}
+	// forecast hours start on the hour so a single time gets the hour it is in
+	if from == to {
+		from = from[:len(from)-2] + "00"
+	}
+	return from, to, nil
+}
+
+// Gets the compact weather json used by digests
+func getWeather(ctx context.Context, location string, config *Config) (string, error) {
+	report, err := getWeatherReport(ctx, WeatherRequest{Location: location, Units: config.WeatherUnits}, config)
+	if err != nil {
+		return "", err
+	}
+	return formatWeatherReport(report)
+}
+
+func formatWeatherReport(report WeatherReport) (string, error) {
+	jsonReport, err := json.Marshal(report)
+	return string(jsonReport), err
+}
+
+// Makes an embed with the key numbers of the report so they do not depend on the model
+func makeWeatherEmbed(report WeatherReport) *discordgo.MessageEmbed {
+	units := report.Units
+	current := report.Current
+	fields := []*discordgo.MessageEmbedField{
+		{
+			Name:   "Now",
+			Value:  fmt.Sprintf("%.0f°%s, feels like %.0f°%s", current.Temperature, units.Temperature, current.FeelsLike, units.Temperature),
+			Inline: true,
+		},
+		{
+			Name:   "Wind",
+			Value:  fmt.Sprintf("%.0f %s %s", current.Wind, units.Wind, current.WindDirection),
+			Inline: true,
+		},
+		{
+			Name:   "Humidity",
+			Value:  fmt.Sprintf("%d%%", current.Humidity),
+			Inline: true,
+		},
+	}
+	if current.AirQuality != nil {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:   "Air quality",
+			Value:  fmt.Sprintf("%s (%d)", current.AirQuality.Category, current.AirQuality.USEPAIndex),
+			Inline: true,
+		})
+	}
+	// alerts and the hourly field come first, the days fill the rest of the fields
+	alerts := report.Alerts[:min(len(report.Alerts), MAX_WEATHER_EMBED_ALERTS)]
+	days := MAX_EMBED_FIELDS - len(fields) - len(alerts)
+	if len(report.Hours) > 0 {
+		days--
+	}
+	for _, day := range report.Days[:min(len(report.Days), days)] {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name: day.Date,
+			Value: fmt.Sprintf(
+				"%s\n%.0f° / %.0f°%s, %d%% rain",
+				day.Condition, day.High, day.Low, units.Temperature, day.ChanceOfRain,
+			),
+			Inline: true,
+		})
+	}
+	if len(report.Hours) > 0 {
+		var hours []string
+		for _, hour := range report.Hours {
+			hours = append(hours, fmt.Sprintf(
+				"`%s` %.0f°%s %s, %d%% rain",
+				hour.Time[len(hour.Time)-5:], hour.Temperature, units.Temperature, hour.Condition, hour.ChanceOfRain,
+			))
+		}
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Hourly",
+			Value: truncateEmbedText(strings.Join(hours, "\n"), MAX_EMBED_FIELD_VALUE),
+		})
+	}
+	for _, alert := range alerts {
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  truncateEmbedText("⚠️ "+alert.Event, MAX_EMBED_TITLE),
+			Value: truncateEmbedText(fmt.Sprintf("%s\nUntil %s", alert.Headline, alert.Expires), MAX_EMBED_FIELD_VALUE),
+		})
+	}
+
+	return &discordgo.MessageEmbed{
+		Title:       truncateEmbedText("Weather for "+report.Location, MAX_EMBED_TITLE),
+		Description: current.Condition,
+		Color:       WEATHER_EMBED_COLOR,
+		Fields:      fields,
+		Footer:      &discordgo.MessageEmbedFooter{Text: "Local time " + report.LocalTime},
+	}
+}
+
+// Gets the user's saved units falling back to Config.WeatherUnits
+func getWeatherUnits(userID string, s *Skippy) string {
+	units, err := s.DB.GetWeatherUnits(userID)
+	if err != nil || units == "" {
+		return s.Config.WeatherUnits
+	}
+	return units
+}
+
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,262 @@
+package skippy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	DEFAULT_WEATHER_WATCH_INTERVAL = 30 * time.Minute
+	// days of the forecast checked for thresholds starting today
+	WEATHER_WATCH_DAYS          = 2
+	MAX_CHANNEL_WEATHER_WATCHES = 5
+	// temperatures at or below this are announced
+	FREEZING_TEMP_C = 0
+	// rain over the rest of a day at or above this is announced
+	HEAVY_RAIN_MM             = 25
+	WEATHER_WATCH_EMBED_COLOR = 0xe74c3c
+)
+
+// alert severities that are announced. WeatherAPI also sends Minor, Moderate and Unknown
+var SEVERE_ALERT_SEVERITIES = []string{"Severe", "Extreme"}
+
+// A location a channel is watching for severe weather.
+// The forecast is checked every Config.WeatherWatchInterval
+type WeatherWatch struct {
+	ID        uint `gorm:"primaryKey"`
+	GuildID   string
+	ChannelID string `gorm:"index"`
+	// location sent to WeatherAPI
+	Location string
+	// name of the location WeatherAPI found
+	Name  string
+	Units string
+	// keys of the alerts and thresholds that were posted -> when they can be forgotten
+	Announced map[string]time.Time `gorm:"serializer:json"`
+	CreatedAt time.Time
+}
+
+// Severe weather found while checking a watch
+type WeatherWatchEvent struct {
+	// identifies the event so it is only posted once
+	Key         string
+	Title       string
+	Description string
+	// when the event is over and its key can be forgotten
+	Expires time.Time
+}
+
+// Saves a new watch after checking the location and schedules it
+func addWeatherWatch(watch WeatherWatch, s *Skippy) (WeatherWatch, error) {
+	watches, err := s.DB.GetWeatherWatchesByChannel(watch.ChannelID)
+	if err != nil {
+		return watch, err
+	}
+	if len(watches) >= MAX_CHANNEL_WEATHER_WATCHES {
+		return watch, fmt.Errorf("a channel can only watch %d locations", MAX_CHANNEL_WEATHER_WATCHES)
+	}
+
+	if watch.Units != WEATHER_UNITS_METRIC {
+		watch.Units = WEATHER_UNITS_IMPERIAL
+	}
+	report, err := getWeatherReport(
+		context.Background(),
+		WeatherRequest{Location: watch.Location, Units: watch.Units, Days: WEATHER_WATCH_DAYS},
+		s.Config,
+	)
+	if err != nil {
+		return watch, err
+	}
+	watch.Name = report.Location
+
+	if err := s.DB.CreateWeatherWatch(&watch); err != nil {
+		return watch, err
+	}
+	return watch, scheduleWeatherWatch(watch, s)
+}
+
+func scheduleWeatherWatch(watch WeatherWatch, s *Skippy) error {
+	s.Scheduler.CancelWeatherWatchJob(watch.ID)
+	log.Printf("scheduling weather watch %d on %s every %s\n", watch.ID, watch.ChannelID, s.Config.WeatherWatchInterval)
+	return s.Scheduler.AddWeatherWatchJob(watch.ID, s.Config.WeatherWatchInterval, func() {
+		if err := CheckWeatherWatch(context.Background(), watch.ID, s); err != nil {
+			log.Printf("unable to check weather watch %d: %s\n", watch.ID, err)
+		}
+	})
+}
+
+func deleteWeatherWatch(id uint, s *Skippy) error {
+	s.Scheduler.CancelWeatherWatchJob(id)
+	return s.DB.DeleteWeatherWatch(id)
+}
+
+// Posts the severe alerts and crossed thresholds of a watch that have not been posted yet
+func CheckWeatherWatch(ctx context.Context, id uint, s *Skippy) error {
+	watch, err := s.DB.GetWeatherWatch(id)
+	if err != nil {
+		return err
+	}
+
+	weatherData, err := getWeatherData(ctx, watch.Location, WEATHER_WATCH_DAYS, s.Config)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	announced := make(map[string]time.Time)
+	for key, expires := range watch.Announced {
+		if now.Before(expires) {
+			announced[key] = expires
+		}
+	}
+
+	var events []WeatherWatchEvent
+	for _, event := range findWeatherWatchEvents(weatherData, watch.Units, now) {
+		if _, ok := announced[event.Key]; ok {
+			continue
+		}
+		events = append(events, event)
+		announced[event.Key] = event.Expires
+	}
+
+	if len(events) > 0 {
+		if _, err := s.DiscordSession.ChannelMessageSendEmbed(watch.ChannelID, makeWeatherWatchEmbed(*watch, events)); err != nil {
+			return err
+		}
+	} else if len(announced) == len(watch.Announced) {
+		return nil
+	}
+
+	watch.Announced = announced
+	return s.DB.UpdateWeatherWatch(watch)
+}
+
+// Finds the severe alerts and the freezing temperatures and heavy rain in the rest of the forecast
+func findWeatherWatchEvents(weatherData WeatherData, units string, now time.Time) []WeatherWatchEvent {
+	metric := units == WEATHER_UNITS_METRIC
+	var events []WeatherWatchEvent
+
+	for _, alert := range weatherData.Alerts.Alert {
+		if !slices.Contains(SEVERE_ALERT_SEVERITIES, alert.Severity) {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, alert.Expires)
+		if err != nil {
+			expires = now.Add(24 * time.Hour)
+		} else if expires.Before(now) {
+			continue
+		}
+		description := alert.Headline
+		if alert.Instruction != "" {
+			description += "\n" + alert.Instruction
+		}
+		events = append(events, WeatherWatchEvent{
+			Key:         fmt.Sprintf("alert|%s|%s", alert.Event, alert.Effective),
+			Title:       fmt.Sprintf("⚠️ %s (%s)", alert.Event, alert.Severity),
+			Description: description + fmt.Sprintf("\nUntil %s", alert.Expires),
+			Expires:     expires,
+		})
+	}
+
+	// only the hours that have not passed are checked
+	currentHour := ""
+	if localTime, err := time.Parse(WEATHER_HOUR_FORMAT, weatherData.Location.Localtime); err == nil {
+		currentHour = localTime.Truncate(time.Hour).Format(WEATHER_HOUR_FORMAT)
+	}
+	for _, forecastDay := range weatherData.Forecast.Forecastday {
+		// kept until every day of the forecast being checked has passed
+		expires := now.Add(WEATHER_WATCH_DAYS * 24 * time.Hour)
+
+		var coldest *Hour
+		var rainMm, rainIn float64
+		for _, hour := range forecastDay.Hour {
+			if hour.Time < currentHour {
+				continue
+			}
+			if hour.TempC <= FREEZING_TEMP_C && (coldest == nil || hour.TempC < coldest.TempC) {
+				coldest = &hour
+			}
+			rainMm += hour.PrecipMm
+			rainIn += hour.PrecipIn
+		}
+
+		if coldest != nil {
+			temperature, unit := coldest.TempF, "F"
+			if metric {
+				temperature, unit = coldest.TempC, "C"
+			}
+			events = append(events, WeatherWatchEvent{
+				Key:   "freezing|" + forecastDay.Date,
+				Title: "🥶 Freezing temperatures",
+				Description: fmt.Sprintf(
+					"Down to %.0f°%s at %s on %s",
+					temperature, unit, coldest.Time[len(coldest.Time)-5:], forecastDay.Date,
+				),
+				Expires: expires,
+			})
+		}
+		if rainMm >= HEAVY_RAIN_MM {
+			rain := fmt.Sprintf("%.1f in", rainIn)
+			if metric {
+				rain = fmt.Sprintf("%.0f mm", rainMm)
+			}
+			events = append(events, WeatherWatchEvent{
+				Key:         "rain|" + forecastDay.Date,
+				Title:       "🌧️ Heavy rain",
+				Description: fmt.Sprintf("%s of rain expected on %s", rain, forecastDay.Date),
+				Expires:     expires,
+			})
+		}
+	}
+	return events
+}
+
+func makeWeatherWatchEmbed(watch WeatherWatch, events []WeatherWatchEvent) *discordgo.MessageEmbed {
+	var fields []*discordgo.MessageEmbedField
+	for _, event := range events {
+		fields = append(fields, &discordgo.MessageEmbedField{Name: event.Title, Value: event.Description})
+	}
+	return &discordgo.MessageEmbed{
+		Title:  "Weather watch for " + watch.Name,
+		Color:  WEATHER_WATCH_EMBED_COLOR,
+		Fields: fields,
+		Footer: &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("Watch #%d. Stop it with /%s %s", watch.ID, WEATHER_WATCH, REMOVE)},
+	}
+}
+
+// Reschedules the saved watches when the bot starts
+func loadWeatherWatches(s *Skippy) {
+	watches, err := s.DB.GetAllWeatherWatches()
+	if err != nil {
+		log.Println("unable to load weather watches: ", err)
+		return
+	}
+
+	for _, watch := range watches {
+		if err := scheduleWeatherWatch(watch, s); err != nil {
+			log.Printf("unable to schedule weather watch %d: %s\n", watch.ID, err)
+		}
+	}
+}
+
+func formatWeatherWatch(watch WeatherWatch) string {
+	return fmt.Sprintf("`#%d` **%s** in %s units", watch.ID, watch.Name, watch.Units)
+}
+
+func formatWeatherWatches(watches []WeatherWatch) string {
+	if len(watches) == 0 {
+		return "This channel is not watching the weather anywhere"
+	}
+
+	var lines []string
+	for _, watch := range watches {
+		lines = append(lines, formatWeatherWatch(watch))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,218 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestGetMessageFiles(t *testing.T) {
+	t.Parallel()
+	contents := map[string]string{
+		"/small.txt":   "hello world",
+		"/large.txt":   strings.Repeat("a", 200),
+		"/unicode.txt": strings.Repeat("é", 100),
+		"/binary.txt":  string([]byte{0xff, 0xfe, 0xfd}),
+	}
+	fileServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := contents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(fileServer.Close)
+
+	attachment := func(name string) *discordgo.MessageAttachment {
+		return &discordgo.MessageAttachment{
+			Filename:    name,
+			URL:         fileServer.URL + "/" + name,
+			ContentType: "text/plain; charset=utf-8",
+			Size:        len(contents["/"+name]),
+		}
+	}
+
+	tests := []struct {
+		name           string
+		attachment     *discordgo.MessageAttachment
+		maxFileSize    int64
+		maxFileChars   int
+		summarizeFails bool
+		// expected in the file context. no file is expected if empty
+		file string
+		// expected in the notes. no notes are expected if empty
+		note string
+	}{
+		{
+			name:        "small file is included",
+			attachment:  attachment("small.txt"),
+			maxFileSize: 100,
+			file:        fmt.Sprintf(skippy.FILE_FORMAT, "small.txt", "hello world", "small.txt"),
+		},
+		{
+			name:        "no size limit",
+			attachment:  attachment("large.txt"),
+			maxFileSize: 0,
+			file:        strings.Repeat("a", 200),
+		},
+		{
+			name:        "file over the size limit is skipped",
+			attachment:  attachment("large.txt"),
+			maxFileSize: 100,
+			note:        fmt.Sprintf(skippy.FILE_SIZE_NOTE_FORMAT, "large.txt"),
+		},
+		{
+			name:        "download over the size limit is skipped",
+			attachment:  &discordgo.MessageAttachment{Filename: "large.txt", URL: fileServer.URL + "/large.txt", ContentType: "text/plain"},
+			maxFileSize: 100,
+			note:        fmt.Sprintf(skippy.FILE_ERROR_NOTE_FORMAT, "large.txt"),
+		},
+		{
+			name:         "file over the char limit is summarized",
+			attachment:   attachment("large.txt"),
+			maxFileSize:  1000,
+			maxFileChars: 50,
+			file:         fmt.Sprintf(skippy.FILE_SUMMARY_FORMAT, "large.txt", 200, "a summary", "large.txt"),
+		},
+		{
+			name:           "file is truncated when it can not be summarized",
+			attachment:     attachment("unicode.txt"),
+			maxFileSize:    1000,
+			maxFileChars:   51,
+			summarizeFails: true,
+			// é is two bytes so the 51st byte is dropped instead of splitting a character
+			file: fmt.Sprintf(skippy.FILE_FORMAT, "unicode.txt", strings.Repeat("é", 25)+skippy.FILE_TRUNCATED_NOTE, "unicode.txt"),
+		},
+		{
+			name:        "invalid utf8 is not included",
+			attachment:  attachment("binary.txt"),
+			maxFileSize: 100,
+			note:        fmt.Sprintf(skippy.FILE_ERROR_NOTE_FORMAT, "binary.txt"),
+		},
+		{
+			name:        "missing file is not included",
+			attachment:  attachment("missing.txt"),
+			maxFileSize: 100,
+			note:        fmt.Sprintf(skippy.FILE_ERROR_NOTE_FORMAT, "missing.txt"),
+		},
+		{
+			name:        "images are ignored",
+			attachment:  &discordgo.MessageAttachment{Filename: "image.png", URL: fileServer.URL + "/image.png", ContentType: "image/png"},
+			maxFileSize: 100,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+				if test.summarizeFails {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"error": {"message": "bad request"}}`))
+					return
+				}
+				writeCompletion(w, req.Model, "a summary")
+			})
+			fake.Config.MaxFileSize = test.maxFileSize
+			fake.Config.MaxFileChars = test.maxFileChars
+
+			files, notes := skippy.GetMessageFiles(context.Background(), skippy.ResponseReq{
+				Attachments: []*discordgo.MessageAttachment{test.attachment},
+			}, openai.GPT4o, fake)
+
+			if test.file == "" && len(files) != 0 {
+				t.Error("Expected no files, got ", files)
+			}
+			if test.file != "" && (len(files) != 1 || !strings.Contains(files[0], test.file)) {
+				t.Errorf("Expected a file containing %q, got %q", test.file, files)
+			}
+			for _, file := range files {
+				if !utf8.ValidString(file) {
+					t.Error("Expected the file to be valid utf8, got ", file)
+				}
+			}
+			if test.note == "" && len(notes) != 0 {
+				t.Error("Expected no notes, got ", notes)
+			}
+			if test.note != "" && (len(notes) != 1 || notes[0] != test.note) {
+				t.Errorf("Expected the note %q, got %q", test.note, notes)
+			}
+		})
+	}
+}
+
+func TestFileSummaryUsage(t *testing.T) {
+	t.Parallel()
+	content := strings.Repeat("a", 200)
+	fileServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(fileServer.Close)
+
+	requests := 0
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		requests = n
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: req.Model,
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "a summary"},
+				FinishReason: openai.FinishReasonStop,
+			}},
+			Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 10},
+		})
+	})
+	fake.Config.MaxFileChars = 50
+	getResponse := func(guildID string) error {
+		_, err := skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+			GuildID:   guildID,
+			ChannelID: GenerateRandomID(10),
+			UserID:    USER_ID,
+			Message:   "what is in this file?",
+			Attachments: []*discordgo.MessageAttachment{{
+				Filename:    "large.txt",
+				URL:         fileServer.URL + "/large.txt",
+				ContentType: "text/plain",
+				Size:        len(content),
+			}},
+			DisableTools: true,
+		})
+		return err
+	}
+
+	// files are not summarized for users that are over the budget
+	overBudgetID := GenerateRandomID(10)
+	if err := s.DB.SetBudgetSetting(&skippy.BudgetSetting{GuildID: overBudgetID, DailyBudget: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.CreateUsage(&skippy.Usage{GuildID: overBudgetID, UserID: USER_ID, Cost: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := getResponse(overBudgetID); !errors.Is(err, skippy.ErrBudgetExceeded) || requests != 0 {
+		t.Fatal("Expected no requests when the budget is exceeded, got ", err, requests)
+	}
+
+	guildID := GenerateRandomID(10)
+	if err := getResponse(guildID); err != nil {
+		t.Fatal(err)
+	}
+	summaries, err := s.DB.GetUsageSummary(guildID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].Requests != 2 || summaries[0].PromptTokens != 200 {
+		t.Error("Expected the summary and the response to be recorded, got ", summaries)
+	}
+}
@@ -0,0 +1,376 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestDigests(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{ChannelID: channelID}
+
+	skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{
+			{ID: "1", Function: openai.FunctionCall{
+				Name:      skippy.EnableMorningMessage,
+				Arguments: `{"name": "weekday", "time": "7:30 AM", "days": "1-5", "timezone": "America/New_York"}`,
+			}},
+		},
+		toolCtx,
+		s,
+	)
+
+	digestCommand := func(subcommand string, options ...*discordgo.ApplicationCommandInteractionDataOption) {
+		runDigestCommand("", channelID, subcommand, options...)
+	}
+
+	digestCommand(
+		skippy.CREATE,
+		stringOption(skippy.NAME, "weekend"),
+		stringOption(skippy.TIME, "10:00"),
+		stringOption(skippy.DAYS, skippy.DIGEST_WEEKENDS),
+		stringOption(skippy.STOCKS, "GME, AMC"),
+	)
+
+	digests := getChannelDigests(t, channelID)
+	if len(digests) != 2 {
+		t.Fatal("Expected the channel to have two digests, got ", len(digests))
+	}
+	for _, digest := range digests {
+		if !s.Scheduler.HasDigestJob(digest.ID) {
+			t.Errorf("Expected digest %s to be scheduled", digest.Name)
+		}
+		switch digest.Name {
+		case "weekday":
+			if digest.Time != "07:30" || digest.Timezone != "America/New_York" || digest.Days != skippy.DIGEST_WEEKDAYS {
+				t.Error("Expected weekday digest settings to be saved, got ", digest)
+			}
+			location, _ := time.LoadLocation("America/New_York")
+			if nextRun := digest.NextRun.In(location); nextRun.Hour() != 7 || nextRun.Minute() != 30 {
+				t.Error("Expected digest to be sent at 7:30 in its timezone, got ", digest.NextRun)
+			}
+		case "weekend":
+			if len(digest.Stocks) != 2 || strings.Join(digest.Stocks, ",") != "GME,AMC" {
+				t.Error("Expected weekend digest stocks to be saved, got ", digest.Stocks)
+			}
+		}
+	}
+
+	weekend := digests[0]
+	if weekend.Name != "weekend" {
+		weekend = digests[1]
+	}
+	idOption := &discordgo.ApplicationCommandInteractionDataOption{
+		Type:  discordgo.ApplicationCommandOptionInteger,
+		Name:  skippy.ID,
+		Value: float64(weekend.ID),
+	}
+
+	// a bad edit should not replace a working digest
+	digestCommand(skippy.EDIT, idOption, stringOption(skippy.DAYS, "not a day"))
+	digestCommand(skippy.EDIT, idOption, stringOption(skippy.STOCKS, skippy.NONE), stringOption(skippy.TIME, "11:15"))
+	edited, err := s.DB.GetDigest(weekend.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited.Days != skippy.DIGEST_WEEKENDS || edited.Time != "11:15" || len(edited.Stocks) != 0 {
+		t.Error("Expected digest to be edited, got ", edited)
+	}
+
+	digestCommand(skippy.DELETE, idOption)
+	if len(getChannelDigests(t, channelID)) != 1 || s.Scheduler.HasDigestJob(weekend.ID) {
+		t.Error("Expected digest to be deleted")
+	}
+}
+
+func TestDigestSections(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	feeds := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer feeds.Close()
+
+	runDigestCommand(
+		GUILD_ID,
+		channelID,
+		skippy.CREATE,
+		stringOption(skippy.NAME, "sections"),
+		stringOption(skippy.TIME, "8:00"),
+		stringOption(skippy.SECTIONS, "weather, news, not a section"),
+	)
+	if len(getChannelDigests(t, channelID)) != 0 {
+		t.Fatal("Expected a digest with an unknown section to not be saved")
+	}
+
+	runDigestCommand(
+		GUILD_ID,
+		channelID,
+		skippy.CREATE,
+		stringOption(skippy.NAME, "sections"),
+		stringOption(skippy.TIME, "8:00"),
+		stringOption(skippy.SECTIONS, "Birthdays, game_stats, reminders, events, news"),
+		stringOption(skippy.FEED, feeds.URL+"/feed.xml"),
+	)
+	digests := getChannelDigests(t, channelID)
+	if len(digests) != 1 {
+		t.Fatal("Expected the digest to be saved, got ", len(digests))
+	}
+	digest := digests[0]
+	if strings.Join(digest.Sections, ",") != "birthdays,game_stats,reminders,events,news" {
+		t.Fatal("Expected the sections to be saved in order, got ", digest.Sections)
+	}
+
+	location, err := time.LoadLocation(s.Config.Timezone)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().In(location)
+	skippy.OnInteraction(&discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:    discordgo.InteractionApplicationCommand,
+			GuildID: GUILD_ID,
+			Member:  &discordgo.Member{User: &discordgo.User{ID: USER_ID}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.BIRTHDAY,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Type: discordgo.ApplicationCommandOptionSubCommand,
+						Name: skippy.SET,
+						Options: []*discordgo.ApplicationCommandInteractionDataOption{
+							intOption(skippy.MONTH, int(now.Month())),
+							intOption(skippy.DAY, now.Day()),
+						},
+					},
+				},
+			},
+		},
+	}, s)
+
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 12, 0, 0, 0, location)
+	game := GenerateRandomID(10)
+	if err := s.DB.CreateGameSession(&skippy.GameSession{
+		UserID:    USER_ID,
+		Game:      game,
+		StartedAt: yesterday,
+		Duration:  2 * time.Hour,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.CreateReminder(&skippy.Reminder{
+		ChannelID: channelID,
+		UserID:    USER_ID,
+		Message:   "water the plants",
+		RemindAt:  time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	prompt := skippy.BuildDigestPrompt(context.Background(), digest, s)
+	expected := []string{
+		skippy.UserMention(USER_ID) + "'s birthday",
+		USERNAME + " played " + game + " for 2h0m0s",
+		"water the plants",
+		"Cake confirmed to be real https://example.com/cake",
+		"Test chamber 19 reopens",
+	}
+	last := -1
+	for _, text := range expected {
+		index := strings.Index(prompt, text)
+		if index <= last {
+			t.Fatalf("Expected %q after the previous section in the prompt:\n%s", text, prompt)
+		}
+		last = index
+	}
+	if strings.Contains(prompt, "could not be loaded") {
+		t.Error("Expected every section to load, got ", prompt)
+	}
+
+	digest.FeedURL = feeds.URL + "/atom.xml"
+	prompt = skippy.BuildDigestPrompt(context.Background(), digest, s)
+	if !strings.Contains(prompt, "Beer can found orbiting Earth https://example.com/beer-can") {
+		t.Error("Expected atom feed headlines in the prompt, got ", prompt)
+	}
+
+	// failed sections are reported instead of skipped
+	digest.FeedURL = feeds.URL + "/missing.xml"
+	prompt = skippy.BuildDigestPrompt(context.Background(), digest, s)
+	if !strings.Contains(prompt, "could not be loaded") || !strings.Contains(prompt, "Headlines: news feed: 404 Not Found") {
+		t.Error("Expected the failed news section to be reported, got ", prompt)
+	}
+}
+
+func TestDigestPermissionAndFeed(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	feeds := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer feeds.Close()
+	fake := newFakeCompletionSkippy(t, nil)
+	fake.Config.AllowPrivateURLs = false
+
+	create := func(feedURL string) *discordgo.InteractionCreate {
+		return makeDigestCommand(
+			GUILD_ID,
+			channelID,
+			skippy.CREATE,
+			stringOption(skippy.TIME, "8:00"),
+			stringOption(skippy.FEED, feedURL),
+		)
+	}
+
+	interaction := create("https://example.com/feed.xml")
+	interaction.Member.Permissions = 0
+	skippy.OnInteraction(interaction, fake)
+	if len(getChannelDigests(t, channelID)) != 0 {
+		t.Fatal("Expected members without Manage Server to not create digests")
+	}
+
+	for _, feedURL := range []string{
+		feeds.URL + "/feed.xml",
+		"http://10.0.0.1/feed.xml",
+		"http://169.254.169.254/latest/meta-data",
+		"file:///etc/passwd",
+	} {
+		skippy.OnInteraction(create(feedURL), fake)
+		if len(getChannelDigests(t, channelID)) != 0 {
+			t.Error("Expected the feed to be rejected, got ", feedURL)
+		}
+	}
+
+	// saved digests are checked again before the feed is read
+	prompt := skippy.BuildDigestPrompt(context.Background(), skippy.Digest{
+		Time:     "18:30",
+		Sections: []string{skippy.SECTION_NEWS},
+		FeedURL:  feeds.URL + "/feed.xml",
+	}, fake)
+	if !strings.Contains(prompt, "not a public address") {
+		t.Error("Expected the private feed to not be read, got ", prompt)
+	}
+	if !strings.Contains(prompt, "good evening") {
+		t.Error("Expected an evening digest to say good evening, got ", prompt)
+	}
+
+	// the address is checked when connecting so a host can't resolve or redirect to a private address after the check
+	if _, err := skippy.GetPublicClient(fake.Config).Get(feeds.URL + "/feed.xml"); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Error("Expected the client to not connect to a private address, got ", err)
+	}
+
+	large := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<rss><channel>"))
+		w.Write([]byte(strings.Repeat(" ", skippy.MAX_API_RESPONSE_SIZE)))
+		w.Write([]byte("</channel></rss>"))
+	}))
+	defer large.Close()
+	fake.Config.AllowPrivateURLs = true
+	prompt = skippy.BuildDigestPrompt(context.Background(), skippy.Digest{
+		Time:     "18:30",
+		Sections: []string{skippy.SECTION_NEWS},
+		FeedURL:  large.URL,
+	}, fake)
+	if !strings.Contains(prompt, "larger than") {
+		t.Error("Expected a feed over the size limit to not be read, got ", prompt)
+	}
+}
+
+func runDigestCommand(
+	guildID string,
+	channelID string,
+	subcommand string,
+	options ...*discordgo.ApplicationCommandInteractionDataOption,
+) {
+	skippy.OnInteraction(makeDigestCommand(guildID, channelID, subcommand, options...), s)
+}
+
+func makeDigestCommand(
+	guildID string,
+	channelID string,
+	subcommand string,
+	options ...*discordgo.ApplicationCommandInteractionDataOption,
+) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			GuildID:   guildID,
+			ChannelID: channelID,
+			Member: &discordgo.Member{
+				User:        &discordgo.User{ID: USER_ID},
+				Permissions: discordgo.PermissionManageServer,
+			},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.DIGEST,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subcommand, Options: options},
+				},
+			},
+		},
+	}
+}
+
+func TestDigestKeepsConversation(t *testing.T) {
+	t.Parallel()
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		writeCompletion(w, req.Model, "good morning")
+	})
+	channelID := GenerateRandomID(10)
+	fake.State.NewThread(channelID)
+	fake.State.SetThreadMessages(channelID, []openai.ChatCompletionMessage{
+		{Role: openai.ChatMessageRoleUser, Content: "remember the plan for friday"},
+	})
+
+	// a digest in the channel should not clear what the channel was talking about
+	digest := skippy.Digest{ChannelID: channelID, Time: "8:00", Sections: []string{skippy.SECTION_REMINDERS}}
+	if err := s.DB.CreateDigest(&digest); err != nil {
+		t.Fatal(err)
+	}
+	skippy.SendDigest(digest.ID, fake)
+
+	messages := fake.State.GetThreadMessages(channelID)
+	if len(messages) < 2 || messages[0].Content != "remember the plan for friday" {
+		t.Error("Expected the channel conversation to be kept after the digest, got ", messages)
+	}
+}
+
+func stringOption(name string, value string) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Type:  discordgo.ApplicationCommandOptionString,
+		Name:  name,
+		Value: value,
+	}
+}
+
+func intOption(name string, value int) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Type:  discordgo.ApplicationCommandOptionInteger,
+		Name:  name,
+		Value: float64(value),
+	}
+}
+
+func getChannelDigests(t *testing.T, channelID string) []skippy.Digest {
+	t.Helper()
+	digests, err := s.DB.GetDigestsByChannel(channelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return digests
+}
+
+// Checks if the channel has a scheduled digest
+func hasDigestJob(t *testing.T, channelID string) bool {
+	t.Helper()
+	for _, digest := range getChannelDigests(t, channelID) {
+		if s.Scheduler.HasDigestJob(digest.ID) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,364 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// Serves recorded Alpha Vantage responses from testdata/alphavantage named FUNCTION_KEY.json
+// where KEY is the symbol, keywords or currency pair. Requests for LIMIT get the rate limit response
+func newStockAPIServer(t *testing.T) (*httptest.Server, map[string]int) {
+	t.Helper()
+	var mu sync.Mutex
+	requests := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var keys []string
+		for _, param := range []string{"symbol", "keywords", "from_currency", "to_currency", "from_symbol", "to_symbol", "market"} {
+			if value := query.Get(param); value != "" {
+				keys = append(keys, value)
+			}
+		}
+		key := strings.Join(keys, "_")
+		mu.Lock()
+		requests[query.Get("function")+"_"+key]++
+		mu.Unlock()
+
+		name := query.Get("function") + "_" + key + ".json"
+		if key == "LIMIT" {
+			name = "rate_limit.json"
+		}
+		body, err := os.ReadFile(filepath.Join("testdata", "alphavantage", name))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server, requests
+}
+
+// Serves recorded WeatherAPI responses from testdata/weatherapi named ENDPOINT_LOCATION.json
+func newWeatherAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimSuffix(filepath.Base(r.URL.Path), ".json") + "_" + r.URL.Query().Get("q") + ".json"
+		body, err := os.ReadFile(filepath.Join("testdata", "weatherapi", name))
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"code": 1006, "message": "No matching location found."}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Copies the shared skippy with a config that uses the fixture servers
+func newFixtureSkippy(stockURL string, weatherURL string) *skippy.Skippy {
+	config := *s.Config
+	config.StockAPIURL = stockURL
+	config.StockAPIKey = "fixture"
+	config.WeatherAPIURL = weatherURL
+	config.WeatherAPIKey = "fixture"
+	fixture := *s
+	fixture.Config = &config
+	return &fixture
+}
+
+// Calls the stock tool and decodes its results
+func getStockResults(t *testing.T, fixture *skippy.Skippy, arguments string) skippy.StockResults {
+	t.Helper()
+	outputs := skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.GetStockPriceKey,
+			Arguments: arguments,
+		}}},
+		skippy.ToolContext{ChannelID: GenerateRandomID(10)},
+		fixture,
+	)
+
+	var results skippy.StockResults
+	if err := json.Unmarshal([]byte(outputs[0].Content), &results); err != nil {
+		t.Fatalf("Expected the results to be json, got %s: %s", outputs[0].Content, err)
+	}
+	return results
+}
+
+func TestStockPriceFixtures(t *testing.T) {
+	t.Parallel()
+	server, requests := newStockAPIServer(t)
+	fixture := newFixtureSkippy(server.URL+"/query", "")
+
+	results := getStockResults(t, fixture, `{"symbols": ["IBM", "LIMIT", "UNKNOWN"]}`)
+	if len(results.Stocks) != 3 {
+		t.Fatal("Expected a result for each symbol, got ", results)
+	}
+	quote := results.Stocks[0].Quote
+	if quote == nil || quote.Symbol != "IBM" || quote.Price != 191.45 || quote.Change != 2.33 || quote.ChangePercent != 1.232 {
+		t.Error("Expected the quote with its change, got ", quote)
+	}
+	if !strings.Contains(results.Stocks[1].Error, "25 requests per day") {
+		t.Error("Expected the rate limit message to be reported, got ", results.Stocks[1])
+	}
+
+	getStockResults(t, fixture, `{"symbols": ["IBM", "LIMIT"]}`)
+	if requests["GLOBAL_QUOTE_IBM"] != 1 {
+		t.Error("Expected the second quote to be cached, got requests: ", requests["GLOBAL_QUOTE_IBM"])
+	}
+	// errors are not cached
+	if requests["GLOBAL_QUOTE_LIMIT"] != 2 {
+		t.Error("Expected the rate limit response to not be cached, got requests: ", requests["GLOBAL_QUOTE_LIMIT"])
+	}
+}
+
+func TestStockSearchCryptoAndHistory(t *testing.T) {
+	t.Parallel()
+	server, _ := newStockAPIServer(t)
+	fixture := newFixtureSkippy(server.URL+"/query", "")
+
+	results := getStockResults(t, fixture, `{
+		"symbols": ["nvidia"],
+		"crypto": ["btc"],
+		"currency_pairs": ["EUR/USD"],
+		"history_days": 3
+	}`)
+	if len(results.Stocks) != 1 || len(results.Crypto) != 1 || len(results.Currencies) != 1 {
+		t.Fatal("Expected a result for each symbol, got ", results)
+	}
+
+	nvidia := results.Stocks[0]
+	if nvidia.Quote == nil || nvidia.Quote.Symbol != "NVDA" || nvidia.Name != "NVIDIA Corp" || nvidia.Query != "nvidia" {
+		t.Error("Expected nvidia to be resolved to NVDA, got ", nvidia)
+	}
+	if len(nvidia.History) != 3 {
+		t.Fatal("Expected 3 days of history, got ", nvidia.History)
+	}
+	if nvidia.History[0].Date != "2024-07-31" || nvidia.History[2].Close != 107.27 {
+		t.Error("Expected history from oldest to newest, got ", nvidia.History)
+	}
+	// 103.73 to 117.02
+	if nvidia.History[0].ChangePercent != 12.81 {
+		t.Error("Expected the change from the previous close, got ", nvidia.History[0].ChangePercent)
+	}
+
+	bitcoin := results.Crypto[0]
+	if bitcoin.Rate == nil || bitcoin.Rate.From != "BTC" || bitcoin.Rate.To != "USD" || bitcoin.Rate.Rate != 61234.56 {
+		t.Error("Expected bitcoin to be priced in USD, got ", bitcoin)
+	}
+	// there is no crypto history fixture so the failure is reported with the price
+	if !strings.Contains(bitcoin.Error, "unable to get history") {
+		t.Error("Expected the missing history to be reported, got ", bitcoin.Error)
+	}
+
+	euro := results.Currencies[0]
+	if euro.Rate == nil || euro.Rate.From != "EUR" || euro.Rate.Rate != 1.0912 {
+		t.Error("Expected the EUR/USD exchange rate, got ", euro)
+	}
+}
+
+func TestWeatherFixtures(t *testing.T) {
+	t.Parallel()
+	server := newWeatherAPIServer(t)
+	fixture := newFixtureSkippy("", server.URL)
+	channelID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+
+	getWeather := func(arguments string) string {
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name:      skippy.GetWeatherKey,
+				Arguments: arguments,
+			}}},
+			skippy.ToolContext{ChannelID: channelID, UserID: userID},
+			fixture,
+		)
+		return outputs[0].Content
+	}
+	decodeReport := func(output string) skippy.WeatherReport {
+		t.Helper()
+		var report skippy.WeatherReport
+		if err := json.Unmarshal([]byte(output[strings.Index(output, "{"):]), &report); err != nil {
+			t.Fatalf("Expected the report to be json, got %s: %s", output, err)
+		}
+		return report
+	}
+
+	skippy.OnInteraction(&discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:   discordgo.InteractionApplicationCommand,
+			Member: &discordgo.Member{User: &discordgo.User{ID: userID}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name:    skippy.WEATHER_UNITS,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{stringOption(skippy.UNITS, skippy.WEATHER_UNITS_METRIC)},
+			},
+		},
+	}, s)
+
+	report := decodeReport(getWeather(`{"location": "London", "hourly_from": "6pm"}`))
+	if report.Units.Temperature != "C" || report.Current.Temperature != 21 || report.Days[0].High != 23.4 {
+		t.Error("Expected the user's metric units to be used, got ", report)
+	}
+	if len(report.Hours) != 1 || report.Hours[0].Time != "2024-08-02 18:00" || report.Hours[0].ChanceOfRain != 89 {
+		t.Error("Expected only the 6pm forecast, got ", report.Hours)
+	}
+	if report.Current.AirQuality == nil || report.Current.AirQuality.Category != "Good" {
+		t.Error("Expected the air quality, got ", report.Current.AirQuality)
+	}
+	if len(report.Alerts) != 1 || report.Alerts[0].Event != "Yellow thunderstorm warning" {
+		t.Error("Expected the weather alert, got ", report.Alerts)
+	}
+
+	dg.mu.Lock()
+	embeds := dg.channelEmbeds[channelID]
+	dg.mu.Unlock()
+	if len(embeds) != 1 {
+		t.Fatal("Expected the weather embed to be posted, got ", len(embeds))
+	}
+	var embedText []string
+	for _, field := range embeds[0].Fields {
+		embedText = append(embedText, field.Name+" "+field.Value)
+	}
+	if !strings.Contains(strings.Join(embedText, "\n"), "21°C") || !strings.Contains(strings.Join(embedText, "\n"), "`18:00` 19°C") {
+		t.Error("Expected the embed to have the key numbers, got ", embedText)
+	}
+
+	report = decodeReport(getWeather(`{"location": "London", "units": "imperial", "hourly_from": "17:00", "hourly_to": "19:00"}`))
+	if report.Units.Temperature != "F" || report.Current.Temperature != 69.8 || len(report.Hours) != 3 {
+		t.Error("Expected the requested units and hours, got ", report)
+	}
+
+	if output := getWeather(`{"location": "nowhere"}`); !strings.Contains(output, "No matching location found") {
+		t.Error("Expected the api error to be reported, got ", output)
+	}
+}
+
+// Fails to send embeds so the weather tool has to give the numbers itself
+type failingEmbedSession struct {
+	*MockDiscordSession
+}
+
+func (m *failingEmbedSession) ChannelMessageSendEmbed(
+	channelID string, embed *discordgo.MessageEmbed,
+	options ...discordgo.RequestOption,
+) (*discordgo.Message, error) {
+	return nil, errors.New("Invalid Form Body")
+}
+
+func TestWeatherEmbedLimits(t *testing.T) {
+	t.Parallel()
+	body, err := os.ReadFile(filepath.Join("testdata", "weatherapi", "forecast_London.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var forecast map[string]any
+	if err := json.Unmarshal(body, &forecast); err != nil {
+		t.Fatal(err)
+	}
+	// two weeks of every hour and more alerts than fit in an embed
+	forecastDays := forecast["forecast"].(map[string]any)["forecastday"].([]any)
+	templateDay := forecastDays[0].(map[string]any)
+	templateHour := templateDay["hour"].([]any)[0].(map[string]any)
+	forecastDays = nil
+	for day := range 14 {
+		date := time.Date(2024, 8, 2+day, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+		var hours []any
+		for hour := range 24 {
+			forecastHour := maps.Clone(templateHour)
+			forecastHour["time"] = fmt.Sprintf("%s %02d:00", date, hour)
+			hours = append(hours, forecastHour)
+		}
+		forecastDay := maps.Clone(templateDay)
+		forecastDay["date"] = date
+		forecastDay["hour"] = hours
+		forecastDays = append(forecastDays, forecastDay)
+	}
+	forecast["forecast"].(map[string]any)["forecastday"] = forecastDays
+	templateAlert := forecast["alerts"].(map[string]any)["alert"].([]any)[0].(map[string]any)
+	var alerts []any
+	for range 30 {
+		alert := maps.Clone(templateAlert)
+		alert["event"] = strings.Repeat("Thunderstorm ", 30)
+		alert["headline"] = strings.Repeat("Met Office yellow warning for thunderstorms. ", 30)
+		alerts = append(alerts, alert)
+	}
+	forecast["alerts"].(map[string]any)["alert"] = alerts
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(forecast)
+	}))
+	t.Cleanup(server.Close)
+
+	fixture := newFixtureSkippy("", server.URL)
+	channelID := GenerateRandomID(10)
+	getWeather := func(fixture *skippy.Skippy) string {
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name:      skippy.GetWeatherKey,
+				Arguments: `{"location": "London", "days": 14, "hourly_from": "2024-08-02 00:00", "hourly_to": "2024-08-15 23:00"}`,
+			}}},
+			skippy.ToolContext{ChannelID: channelID, UserID: GenerateRandomID(10)},
+			fixture,
+		)
+		return outputs[0].Content
+	}
+
+	if output := getWeather(fixture); !strings.Contains(output, "already posted in an embed") {
+		t.Error("Expected the model to be told the embed was posted, got ", output)
+	}
+	dg.mu.Lock()
+	embeds := dg.channelEmbeds[channelID]
+	dg.mu.Unlock()
+	if len(embeds) != 1 {
+		t.Fatal("Expected the weather embed to be posted, got ", len(embeds))
+	}
+	fields := embeds[0].Fields
+	if len(fields) > skippy.MAX_EMBED_FIELDS {
+		t.Error("Expected at most 25 embed fields, got ", len(fields))
+	}
+	total := len([]rune(embeds[0].Title)) + len([]rune(embeds[0].Description)) + len([]rune(embeds[0].Footer.Text))
+	var hasHourly, hasAlert bool
+	for _, field := range fields {
+		if len([]rune(field.Name)) > skippy.MAX_EMBED_TITLE || len([]rune(field.Value)) > skippy.MAX_EMBED_FIELD_VALUE {
+			t.Errorf("Expected the field to fit discord's limits, got %d and %d characters", len([]rune(field.Name)), len([]rune(field.Value)))
+		}
+		total += len([]rune(field.Name)) + len([]rune(field.Value))
+		hasHourly = hasHourly || field.Name == "Hourly"
+		hasAlert = hasAlert || strings.HasPrefix(field.Name, "⚠️")
+	}
+	if total > 6000 {
+		t.Error("Expected the embed to be under 6000 characters, got ", total)
+	}
+	if !hasHourly || !hasAlert {
+		t.Error("Expected the hourly forecast and alerts to be kept, got ", fields)
+	}
+
+	failing := *fixture
+	failing.DiscordSession = &failingEmbedSession{dg}
+	if output := getWeather(&failing); !strings.Contains(output, "could not be posted") {
+		t.Error("Expected the model to be told the embed was not posted, got ", output)
+	}
+}
@@ -0,0 +1,359 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"skippybot/skippy"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+var fixtureImage = []byte("\x89PNG\r\n\x1a\nfixture")
+
+// An edit or variation request
+type imageUpload struct {
+	Path   string
+	Values map[string][]string
+	// decoded images by form field
+	Images map[string]image.Image
+}
+
+// Responds to image generations, edits and variations with fixtureImage and records the requests
+func newImageAPIServer(t *testing.T) (*httptest.Server, *[]openai.ImageRequest, *[]imageUpload) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []openai.ImageRequest
+	var uploads []imageUpload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/images/generations":
+			body, _ := io.ReadAll(r.Body)
+			var imgReq openai.ImageRequest
+			if err := json.Unmarshal(body, &imgReq); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			mu.Lock()
+			requests = append(requests, imgReq)
+			mu.Unlock()
+		case "/images/edits", "/images/variations":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			upload := imageUpload{Path: r.URL.Path, Values: r.MultipartForm.Value, Images: make(map[string]image.Image)}
+			for field, headers := range r.MultipartForm.File {
+				file, _ := headers[0].Open()
+				img, err := png.Decode(file)
+				file.Close()
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				upload.Images[field] = img
+			}
+			mu.Lock()
+			uploads = append(uploads, upload)
+			mu.Unlock()
+		default:
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openai.ImageResponse{
+			Data: []openai.ImageResponseDataInner{{
+				B64JSON:       base64.StdEncoding.EncodeToString(fixtureImage),
+				RevisedPrompt: "revised " + r.URL.Path,
+			}},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests, &uploads
+}
+
+// Serves pngs that are filled with a color and have the size and alpha in the name.
+// ex: /20x10_255.png
+func newImageFileServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var width, height, alpha int
+		if _, err := fmt.Sscanf(r.URL.Path, "/%dx%d_%d.png", &width, &height, &alpha); err != nil {
+			w.Write([]byte("not an image"))
+			return
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		draw.Draw(img, img.Bounds(), image.NewUniform(color.NRGBA{R: 200, A: uint8(alpha)}), image.Point{}, draw.Src)
+		png.Encode(w, img)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGenerateImage(t *testing.T) {
+	t.Parallel()
+	server, requests, _ := newImageAPIServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	fixture.Config.UserDailyImageLimit = 1
+	channelID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{ChannelID: channelID, UserID: GenerateRandomID(10)}
+
+	generateImage := func(arguments string) string {
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name:      skippy.GenerateImage,
+				Arguments: arguments,
+			}}},
+			toolCtx,
+			fixture,
+		)
+		return outputs[0].Content
+	}
+
+	if output := generateImage(`{"prompt": "a cat", "model": "dall-e-2", "size": "1792x1024"}`); !strings.Contains(output, "sizes are 256x256") {
+		t.Error("Expected the size to be checked against the model, got ", output)
+	}
+	if len(*requests) != 0 {
+		t.Fatal("Expected invalid options to not be sent, got ", *requests)
+	}
+
+	generateImage(`{"prompt": "a cat", "size": "1792x1024", "quality": "hd", "style": "natural"}`)
+	if len(*requests) != 1 {
+		t.Fatal("Expected an image to be generated, got ", len(*requests))
+	}
+	imgReq := (*requests)[0]
+	if imgReq.Model != skippy.DEFAULT_IMAGE_MODEL || imgReq.Size != "1792x1024" || imgReq.Quality != "hd" ||
+		imgReq.Style != "natural" || imgReq.ResponseFormat != openai.CreateImageResponseFormatB64JSON {
+		t.Error("Expected the image options and a base64 response to be requested, got ", imgReq)
+	}
+
+	dg.mu.Lock()
+	embeds := dg.channelEmbeds[channelID]
+	files := dg.channelFiles[channelID]
+	dg.mu.Unlock()
+	if len(embeds) != 1 || len(files) != 1 {
+		t.Fatalf("Expected the image to be uploaded in an embed, got %d embeds %d files", len(embeds), len(files))
+	}
+	if embeds[0].Image == nil || embeds[0].Image.URL != "attachment://"+files[0].Name || embeds[0].Description != "revised /images/generations" {
+		t.Error("Expected the embed to show the attachment, got ", embeds[0])
+	}
+	if image, _ := io.ReadAll(files[0].Reader); string(image) != string(fixtureImage) {
+		t.Error("Expected the decoded image to be uploaded, got ", image)
+	}
+
+	if output := generateImage(`{"prompt": "a dog"}`); !strings.Contains(output, "used all 1 of their images") {
+		t.Error("Expected the daily image limit to be reached, got ", output)
+	}
+	if len(*requests) != 1 {
+		t.Error("Expected no image to be generated over the limit, got ", len(*requests))
+	}
+}
+
+func TestImageLimitParallel(t *testing.T) {
+	t.Parallel()
+	server, requests, _ := newImageAPIServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	fixture.Config.UserDailyImageLimit = 2
+	toolCtx := skippy.ToolContext{ChannelID: GenerateRandomID(10), UserID: GenerateRandomID(10)}
+
+	var toolCalls []openai.ToolCall
+	for i := 0; i < 5; i++ {
+		toolCalls = append(toolCalls, openai.ToolCall{ID: strconv.Itoa(i), Function: openai.FunctionCall{
+			Name:      skippy.GenerateImage,
+			Arguments: `{"prompt": "a cat"}`,
+		}})
+	}
+	skippy.GetToolOutputs(context.Background(), toolCalls, toolCtx, fixture)
+	if len(*requests) != fixture.Config.UserDailyImageLimit {
+		t.Errorf("Expected parallel calls to stop at the limit of %d, got %d", fixture.Config.UserDailyImageLimit, len(*requests))
+	}
+
+	// images that fail are not counted
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error": {"message": "bad request"}}`, http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	fixture.AIClient = skippy.NewAIClient("fixture", failing.URL)
+	toolCtx.UserID = GenerateRandomID(10)
+	skippy.GetToolOutputs(context.Background(), toolCalls[:1], toolCtx, fixture)
+	count, err := s.DB.CountGeneratedImages(toolCtx.UserID, time.Now().Add(-time.Hour))
+	if err != nil || count != 0 {
+		t.Error("Expected the failed image to be released, got ", count, err)
+	}
+}
+
+func TestEditImage(t *testing.T) {
+	t.Parallel()
+	server, _, uploads := newImageAPIServer(t)
+	files := newImageFileServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	channelID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{ChannelID: channelID, UserID: GenerateRandomID(10)}
+
+	callImageTool := func(name string, arguments string, images ...skippy.ImageInput) string {
+		toolCtx.Images = images
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{Name: name, Arguments: arguments}}},
+			toolCtx,
+			fixture,
+		)
+		return outputs[0].Content
+	}
+	photo := skippy.ImageInput{Name: "photo.png", URL: files.URL + "/20x10_255.png"}
+	// masks smaller than the image are scaled up to it
+	mask := skippy.ImageInput{Name: "mask.png", URL: files.URL + "/4x4_0.png"}
+
+	if output := callImageTool(skippy.EditImage, `{"prompt": "a watercolor"}`); !strings.Contains(output, "no image") {
+		t.Error("Expected an image to be required, got ", output)
+	}
+	if output := callImageTool(skippy.EditImage, `{"prompt": "a watercolor", "image": "cat.png"}`, photo); !strings.Contains(output, "images are photo.png") {
+		t.Error("Expected the image names to be listed, got ", output)
+	}
+	if output := callImageTool(skippy.EditImage, `{"prompt": "a watercolor", "size": "1792x1024"}`, photo); !strings.Contains(output, "can only be 256x256") {
+		t.Error("Expected the size to be checked, got ", output)
+	}
+	if len(*uploads) != 0 {
+		t.Fatal("Expected invalid edits to not be sent, got ", len(*uploads))
+	}
+
+	callImageTool(skippy.EditImage, `{"prompt": "a watercolor", "size": "512x512"}`, photo)
+	callImageTool(skippy.EditImage, `{"prompt": "add a hat", "mask": "mask.png"}`, mask, photo)
+	callImageTool(skippy.CreateImageVariation, `{}`, photo)
+	if len(*uploads) != 3 {
+		t.Fatal("Expected two edits and a variation, got ", len(*uploads))
+	}
+
+	edit := (*uploads)[0]
+	if edit.Path != "/images/edits" || edit.Values["prompt"][0] != "a watercolor" || edit.Values["size"][0] != "512x512" ||
+		edit.Values["response_format"][0] != openai.CreateImageResponseFormatB64JSON {
+		t.Error("Expected the edit options to be sent, got ", edit.Values)
+	}
+	if size := edit.Images["image"].Bounds().Size(); size.X != 10 || size.Y != 10 {
+		t.Error("Expected the image to be cropped to a square, got ", size)
+	}
+	// opaque images get a transparent mask so the whole image is redrawn
+	if edit.Images["mask"] == nil {
+		t.Fatal("Expected a mask for an opaque image")
+	}
+	if _, _, _, alpha := edit.Images["mask"].At(5, 5).RGBA(); alpha != 0 {
+		t.Error("Expected the generated mask to be transparent, got ", alpha)
+	}
+
+	masked := (*uploads)[1]
+	if _, _, _, alpha := masked.Images["image"].At(5, 5).RGBA(); alpha == 0 {
+		t.Error("Expected the first image that is not the mask to be edited")
+	}
+	if masked.Images["mask"] == nil || masked.Values["size"][0] != skippy.DEFAULT_IMAGE_SIZE {
+		t.Fatal("Expected the mask and default size to be sent, got ", masked.Values)
+	}
+	if size := masked.Images["mask"].Bounds().Size(); size != masked.Images["image"].Bounds().Size() {
+		t.Error("Expected the mask to be the size of the image, got ", size)
+	}
+
+	if variation := (*uploads)[2]; variation.Path != "/images/variations" || variation.Images["image"] == nil {
+		t.Error("Expected a variation of the image, got ", variation)
+	}
+
+	dg.mu.Lock()
+	embeds := dg.channelEmbeds[channelID]
+	dg.mu.Unlock()
+	if len(embeds) != 3 || embeds[0].Title != "Edited photo.png" || embeds[2].Title != "Variation of photo.png" {
+		t.Error("Expected each image to be posted, got ", embeds)
+	}
+}
+
+func TestEditImageURL(t *testing.T) {
+	t.Parallel()
+	server, _, uploads := newImageAPIServer(t)
+	files := newImageFileServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	fixture.Config.MaxImageSize = 1024
+
+	for _, test := range []struct {
+		name          string
+		url           string
+		allowPrivate  bool
+		expectedError string
+	}{
+		{"only http urls are downloaded", "file:///etc/passwd", true, "unable to download"},
+		{"private hosts are not downloaded", files.URL + "/10x10_255.png", false, "unable to download"},
+		{"images over the size limit are not downloaded", files.URL + "/1000x1000_255.png", true, "unable to download"},
+	} {
+		fixture.Config.AllowPrivateURLs = test.allowPrivate
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{Name: skippy.EditImage, Arguments: `{"prompt": "a watercolor"}`}}},
+			skippy.ToolContext{
+				ChannelID: GenerateRandomID(10),
+				UserID:    GenerateRandomID(10),
+				Images:    []skippy.ImageInput{{Name: "photo.png", URL: test.url}},
+			},
+			fixture,
+		)
+		if !strings.Contains(outputs[0].Content, test.expectedError) {
+			t.Errorf("Expected %s, got %s", test.name, outputs[0].Content)
+		}
+	}
+	if len(*uploads) != 0 {
+		t.Error("Expected no images to be sent, got ", len(*uploads))
+	}
+}
+
+func TestImageEmbedLimits(t *testing.T) {
+	t.Parallel()
+	server, _, _ := newImageAPIServer(t)
+	files := newImageFileServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	channelID := GenerateRandomID(10)
+	name := strings.Repeat("é", 300) + ".png"
+
+	skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.EditImage,
+			Arguments: `{"prompt": "` + strings.Repeat("a watercolor ", 500) + `"}`,
+		}}},
+		skippy.ToolContext{
+			ChannelID: channelID,
+			UserID:    GenerateRandomID(10),
+			Images:    []skippy.ImageInput{{Name: name, URL: files.URL + "/10x10_255.png"}},
+		},
+		fixture,
+	)
+
+	dg.mu.Lock()
+	embeds := dg.channelEmbeds[channelID]
+	dg.mu.Unlock()
+	if len(embeds) != 1 {
+		t.Fatal("Expected the image to be posted, got ", len(embeds))
+	}
+	if title := utf8.RuneCountInString(embeds[0].Title); title != skippy.MAX_EMBED_TITLE {
+		t.Error("Expected the title to be cut to the embed limit, got ", title)
+	}
+	if description := utf8.RuneCountInString(embeds[0].Description); description != skippy.MAX_EMBED_DESCRIPTION {
+		t.Error("Expected the description to be cut to the embed limit, got ", description)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"skippybot/skippy"
 
 	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
 )
 
 func TestToggleAlwaysRespond(t *testing.T) {
@@ -284,3 +285,178 @@ func TestTrackGameUsage(t *testing.T) {
 		t.Error("Expected user config to not exist")
 	}
 }
+
+func TestReset(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+
+	msg := &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        "1",
+			ChannelID: channelID,
+			Content:   "test",
+			Author: &discordgo.User{
+				ID: userID,
+			},
+			Mentions: []*discordgo.User{
+				{
+					ID: BOT_ID,
+				},
+			},
+		},
+	}
+	skippy.OnMessageCreate(msg, s)
+
+	if len(s.State.GetThreadMessages(channelID)) == 0 {
+		t.Fatal("Expected thread to have messages")
+	}
+
+	// without permission the shared conversation should not be reset
+	interaction := &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: channelID,
+			Member: &discordgo.Member{
+				User: &discordgo.User{
+					ID: userID,
+				},
+			},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.RESET,
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	if len(s.State.GetThreadMessages(channelID)) == 0 {
+		t.Error("Expected thread to not be reset without permission")
+	}
+
+	interaction.Member.Permissions = discordgo.PermissionManageMessages
+	skippy.OnInteraction(interaction, s)
+
+	if len(s.State.GetThreadMessages(channelID)) != 0 {
+		t.Error("Expected thread to be reset")
+	}
+}
+
+func TestUsage(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	guildID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+
+	msg := &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        "1",
+			GuildID:   guildID,
+			ChannelID: channelID,
+			Content:   "test",
+			Author: &discordgo.User{
+				ID: userID,
+			},
+			Mentions: []*discordgo.User{
+				{
+					ID: BOT_ID,
+				},
+			},
+		},
+	}
+	skippy.OnMessageCreate(msg, s)
+
+	summaries, err := s.DB.GetUsageSummary(guildID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(summaries) != 1 {
+		t.Fatal("Expected usage to be recorded")
+	}
+	if summaries[0].UserID != userID || summaries[0].Cost <= 0 {
+		t.Error("Expected usage to have user and cost")
+	}
+}
+
+func TestModel(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	guildID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+
+	interaction := &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			GuildID:   guildID,
+			ChannelID: channelID,
+			Member: &discordgo.Member{
+				User: &discordgo.User{
+					ID: userID,
+				},
+			},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.MODEL,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Type:  discordgo.ApplicationCommandOptionString,
+						Name:  skippy.MODEL,
+						Value: openai.GPT4oMini,
+					},
+				},
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	if model, _ := s.DB.GetModel(guildID, channelID); model != "" {
+		t.Error("Expected model to not be set without permission")
+	}
+
+	interaction.Member.Permissions = discordgo.PermissionManageServer
+	skippy.OnInteraction(interaction, s)
+
+	if model, _ := s.DB.GetModel(guildID, channelID); model != openai.GPT4oMini {
+		t.Error("Expected channel model to be set")
+	}
+
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name: skippy.MODEL,
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Type:  discordgo.ApplicationCommandOptionString,
+				Name:  skippy.MODEL,
+				Value: openai.GPT4Turbo,
+			},
+			{
+				Type:  discordgo.ApplicationCommandOptionString,
+				Name:  skippy.SCOPE,
+				Value: skippy.MODEL_SCOPE_GUILD,
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	if model, _ := s.DB.GetModel(guildID, channelID); model != openai.GPT4oMini {
+		t.Error("Expected channel model to override the guild model")
+	}
+	if model, _ := s.DB.GetModel(guildID, GenerateRandomID(10)); model != openai.GPT4Turbo {
+		t.Error("Expected other channels to use the guild model")
+	}
+
+	// o1 models reject the system messages every request has
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name: skippy.MODEL,
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Type:  discordgo.ApplicationCommandOptionString,
+				Name:  skippy.MODEL,
+				Value: skippy.O1_MINI,
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	if model, _ := s.DB.GetModel(guildID, channelID); model != openai.GPT4oMini {
+		t.Error("Expected o1 models to not be allowed, got ", model)
+	}
+}
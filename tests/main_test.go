@@ -7,6 +7,7 @@ import (
 	"log"
 	"math/rand"
 	"os"
+	"skippybot/components"
 	"skippybot/skippy"
 	"strings"
 	"testing"
@@ -71,8 +72,17 @@ func setup() (
 	s *skippy.Skippy, err error,
 ) {
 	dg = &MockDiscordSession{
-		channelMessages:     make(map[string][]string),
-		channelTypingCalled: make(map[string]bool),
+		channelMessages:      make(map[string][]string),
+		channelTypingCalled:  make(map[string]bool),
+		messages:             make(map[string]*discordgo.Message),
+		channelComponents:    make(map[string][]discordgo.MessageComponent),
+		messageEdits:         make(map[string]*discordgo.MessageEdit),
+		channelEmbeds:        make(map[string][]*discordgo.MessageEmbed),
+		channelFiles:         make(map[string][]*discordgo.File),
+		interactionResponses: make(map[string][]*discordgo.InteractionResponse),
+		startedThreads:       make(map[string][]*discordgo.Channel),
+		guildChannels:        make(map[string][]*discordgo.Channel),
+		channelPermissions:   make(map[string]int64),
 	}
 	dg.State = discordgo.NewState()
 	dg.State.Ready = discordgo.Ready{
@@ -117,9 +127,7 @@ func setup() (
 		return
 	}
 
-	clientConfig := openai.DefaultConfig(openAIKey)
-	// clientConfig.BaseURL = "https://api.groq.com/openai/v1/"
-	client := openai.NewClientWithConfig(clientConfig)
+	client := skippy.NewAIClient(openAIKey, "")
 	state := skippy.NewState()
 
 	mrog, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
@@ -128,7 +136,7 @@ func setup() (
 		return
 	}
 
-	err = mrog.AutoMigrate(&skippy.GameSession{})
+	err = mrog.AutoMigrate(&skippy.GameSession{}, &skippy.Usage{}, &skippy.ModelSetting{}, &skippy.Reminder{}, &skippy.ReminderOptOut{}, &skippy.Digest{}, &skippy.Birthday{}, &skippy.WeatherPreference{}, &skippy.WeatherWatch{}, &skippy.GeneratedImage{}, &skippy.ModerationSetting{}, &skippy.RateLimitSetting{}, &skippy.BudgetSetting{}, &skippy.ToolSetting{})
 	if err != nil {
 		log.Println(err)
 		return
@@ -180,20 +188,51 @@ func setup() (
 			time.Hour,
 		},
 		// DefaultModel:  "llama-3.1-70b-versatile",
-		BaseInstructions: instructions,
-		DefaultModel:     openai.GPT4o,
-		UserConfigMap:    userConfigMap,
-		StockAPIKey:      os.Getenv("ALPHA_VANTAGE_API_KEY"),
-		WeatherAPIKey:    os.Getenv("WEATHER_API_KEY"),
+		BaseInstructions:       instructions,
+		DefaultModel:           openai.GPT4o,
+		UserConfigMap:          userConfigMap,
+		StockAPIKey:            os.Getenv("ALPHA_VANTAGE_API_KEY"),
+		WeatherAPIKey:          os.Getenv("WEATHER_API_KEY"),
+		StockAPIURL:            skippy.DEFAULT_STOCK_API_URL,
+		WeatherAPIURL:          skippy.DEFAULT_WEATHER_API_URL,
+		WeatherUnits:           skippy.WEATHER_UNITS_IMPERIAL,
+		WeatherWatchInterval:   skippy.DEFAULT_WEATHER_WATCH_INTERVAL,
+		MaxImageSize:           skippy.MAX_IMAGE_SIZE,
+		MaxImagesPerMessage:    skippy.MAX_IMAGES_PER_MESSAGE,
+		MaxFileSize:            skippy.MAX_FILE_SIZE,
+		MaxFileChars:           skippy.MAX_FILE_CHARS,
+		MaxReplyDepth:          skippy.MAX_REPLY_DEPTH,
+		ThreadScope:            skippy.SCOPE_CHANNEL,
+		AutoThreadMessageCount: skippy.AUTO_THREAD_MESSAGE_COUNT,
+		ModelPrices:            skippy.DEFAULT_MODEL_PRICES,
+		ImagePrices:            skippy.DEFAULT_IMAGE_PRICES,
+		ImagePrice:             skippy.DEFAULT_IMAGE_PRICE,
+		ImageModel:             skippy.DEFAULT_IMAGE_MODEL,
+		ImageModels:            skippy.DEFAULT_IMAGE_MODELS,
+		RequestTimeout:         skippy.DEFAULT_REQUEST_TIMEOUT,
+		MaxRetries:             skippy.DEFAULT_MAX_RETRIES,
+		RetryBaseDelay:         skippy.DEFAULT_RETRY_BASE_DELAY,
+		RetryMaxDelay:          skippy.DEFAULT_RETRY_MAX_DELAY,
+		VisionModels:           []string{openai.GPT4o},
+		AllowedModels:          skippy.DEFAULT_ALLOWED_MODELS,
+		NoToolModels:           skippy.DEFAULT_NO_TOOL_MODELS,
+		ToolTimeout:            skippy.DEFAULT_TOOL_TIMEOUT,
+		ModerationStrictness:   skippy.MODERATION_OFF,
+		Timezone:               "America/Chicago",
+		NewsFeedURL:            os.Getenv("NEWS_FEED_URL"),
+		// the fixture servers are on localhost
+		AllowPrivateURLs: true,
 	}
 	s = &skippy.Skippy{
-		DiscordSession: dg,
-		AIClient:       client,
-		Config:         config,
-		State:          state,
-		DB:             db,
-		Scheduler:      scheduler,
+		DiscordSession:   dg,
+		AIClient:         client,
+		ComponentHandler: components.NewComponentHandler(dg),
+		Config:           config,
+		State:            state,
+		DB:               db,
+		Scheduler:        scheduler,
 	}
+	skippy.RegisterReminderButtons(s)
 	return
 }
 
@@ -227,10 +266,15 @@ func generateTestData(
 	}
 }
 
+// responses sent in place of a response from the model when a request fails
+var ERROR_RESPONSES = []string{skippy.ERROR_RESPONSE, skippy.OUTAGE_RESPONSE, skippy.RATE_LIMITED_RESPONSE}
+
 func checkForErrorResponse(messages []string) bool {
 	for _, message := range messages {
-		if strings.Contains(message, skippy.ERROR_RESPONSE) {
-			return true
+		for _, errorResponse := range ERROR_RESPONSES {
+			if strings.Contains(message, errorResponse) {
+				return true
+			}
 		}
 	}
 	return false
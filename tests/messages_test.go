@@ -2,6 +2,7 @@ package tests
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 	"time"
 
@@ -67,6 +68,84 @@ func TestMessageCreateWithMention(t *testing.T) {
 	}
 }
 
+func TestMessageCreateWithImage(t *testing.T) {
+	t.Parallel()
+	content := "what is in this image?"
+	channelID := GenerateRandomID(10)
+	msg := &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        "1",
+			ChannelID: channelID,
+			Content:   content,
+			Author: &discordgo.User{
+				ID: "USER",
+			},
+			Mentions: []*discordgo.User{
+				{
+					ID: BOT_ID,
+				},
+			},
+			Attachments: []*discordgo.MessageAttachment{
+				{
+					Filename:    "dice.png",
+					ContentType: "image/png",
+					URL:         "https://upload.wikimedia.org/wikipedia/commons/4/47/PNG_transparency_demonstration_1.png",
+					Size:        1024,
+				},
+			},
+		},
+	}
+
+	skippy.OnMessageCreate(msg, s)
+	if len(dg.channelMessages[channelID]) != 1 {
+		t.Error("Expected ChannelMessageSend to be called")
+	}
+	if checkForErrorResponse(dg.channelMessages[channelID]) {
+		t.Error("Expected message to not have error response")
+	}
+}
+
+func TestMessageCreateReplyToBot(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	botMessageID := GenerateRandomID(10)
+	dg.messages[botMessageID] = &discordgo.Message{
+		ID:        botMessageID,
+		ChannelID: channelID,
+		Content:   "The secret word is banana",
+		Author: &discordgo.User{
+			ID: BOT_ID,
+		},
+	}
+
+	// no mention, the reply should count as one
+	msg := &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ID:        "1",
+			ChannelID: channelID,
+			Content:   "what was the secret word?",
+			Author: &discordgo.User{
+				ID: "USER",
+			},
+			MessageReference: &discordgo.MessageReference{
+				MessageID: botMessageID,
+				ChannelID: channelID,
+			},
+		},
+	}
+
+	skippy.OnMessageCreate(msg, s)
+	if len(dg.channelMessages[channelID]) != 1 {
+		t.Fatal("Expected ChannelMessageSend to be called")
+	}
+	if !strings.Contains(strings.ToLower(dg.channelMessages[channelID][0]), "banana") {
+		t.Error("Expected response to use the replied to message")
+	}
+	if checkForErrorResponse(dg.channelMessages[channelID]) {
+		t.Error("Expected message to not have error response")
+	}
+}
+
 func TestSendMultipleMessages(t *testing.T) {
 	t.Parallel()
 	content := "test"
@@ -184,7 +263,7 @@ loop:
 	// if !state.GetAwaitsResponse(channelID) {
 	// 	t.Error("Expected thread to be awaiting response")
 	// }
-	// check that bot responds after a reminder with no mention
+	// check that only replying to the reminder acknowledges it
 	msg = &discordgo.MessageCreate{
 		Message: &discordgo.Message{
 			ID:        "1",
@@ -197,8 +276,19 @@ loop:
 	}
 
 	skippy.OnMessageCreate(msg, s)
-	if len(dg.channelMessages[channelID]) != 5 {
-		t.Error("Expected ChannelMessageSend to be called again")
+	if len(dg.channelMessages[channelID]) != 4 {
+		t.Error("Expected unrelated messages to not get a response")
+	}
+	reminders, _ := s.DB.GetPendingReminders(channelID, "USER")
+	if len(reminders) != 1 {
+		t.Fatal("Expected unrelated messages to not acknowledge the reminder")
+	}
+
+	msg.ID = "2"
+	msg.MessageReference = &discordgo.MessageReference{ChannelID: channelID, MessageID: reminders[0].MessageID}
+	skippy.OnMessageCreate(msg, s)
+	if reminders, _ := s.DB.GetPendingReminders(channelID, "USER"); len(reminders) != 0 {
+		t.Error("Expected the reminder to be acknowledged")
 	}
 
 	// TODO: check needed?
@@ -261,7 +351,7 @@ loop:
 		t.Error("Expected ChannelTyping to be called")
 	}
 
-	if !s.Scheduler.HasMorningMsgJob(channelID) {
+	if !hasDigestJob(t, channelID) {
 		t.Error("Expected job to be scheduled")
 	}
 
@@ -289,7 +379,7 @@ loop:
 		t.Error("Expected morning message to be canceled")
 	}
 
-	if s.Scheduler.HasMorningMsgJob(channelID) {
+	if hasDigestJob(t, channelID) {
 		t.Error("Expected job to be canceled")
 	}
 
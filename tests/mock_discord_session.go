@@ -1,9 +1,12 @@
 package tests
 
 import (
+	"fmt"
 	"log"
+	"sync"
 
 	"github.com/bwmarrin/discordgo"
+	"github.com/google/uuid"
 )
 
 // FOR TESTING
@@ -12,7 +15,27 @@ type MockDiscordSession struct {
 	channelTypingCalled map[string]bool
 	channelID           string
 	content             string
-	State               *discordgo.State
+	// messages returned by ChannelMessage keyed by message id
+	messages map[string]*discordgo.Message
+	// components from the last complex message sent to a channel
+	channelComponents map[string][]discordgo.MessageComponent
+	// last edit of each message keyed by message id
+	messageEdits map[string]*discordgo.MessageEdit
+	// embeds sent to each channel
+	channelEmbeds map[string][]*discordgo.MessageEmbed
+	// files uploaded to each channel
+	channelFiles map[string][]*discordgo.File
+	// responses to interactions in each channel
+	interactionResponses map[string][]*discordgo.InteractionResponse
+	// discord threads started in each channel
+	startedThreads map[string][]*discordgo.Channel
+	// channels returned by GuildChannels keyed by guild id
+	guildChannels map[string][]*discordgo.Channel
+	// permissions returned by UserChannelPermissions keyed by user id and channel id
+	channelPermissions map[string]int64
+	handlers           []interface{}
+	mu                 sync.Mutex
+	State              *discordgo.State
 }
 
 func (m *MockDiscordSession) Open() error {
@@ -30,20 +53,41 @@ func (m *MockDiscordSession) ChannelMessageSend(
 	m.channelMessages[channelID] = append(m.channelMessages[channelID], content)
 	m.channelID = channelID
 	m.content = content
-	return nil, nil
+	return &discordgo.Message{ID: uuid.New().String(), ChannelID: channelID, Content: content}, nil
 }
 
 func (m *MockDiscordSession) ChannelMessageSendComplex(
 	channelID string, data *discordgo.MessageSend,
 	options ...discordgo.RequestOption,
 ) (*discordgo.Message, error) {
+	m.mu.Lock()
+	if len(data.Components) > 0 {
+		m.channelComponents[channelID] = data.Components
+	}
+	m.channelEmbeds[channelID] = append(m.channelEmbeds[channelID], data.Embeds...)
+	m.channelFiles[channelID] = append(m.channelFiles[channelID], data.Files...)
+	m.mu.Unlock()
 	return m.ChannelMessageSend(channelID, data.Content, options...)
 }
 
+func (m *MockDiscordSession) ChannelMessage(
+	channelID string, messageID string,
+	options ...discordgo.RequestOption,
+) (*discordgo.Message, error) {
+	message, ok := m.messages[messageID]
+	if !ok {
+		return nil, fmt.Errorf("message %s not found", messageID)
+	}
+	return message, nil
+}
+
 func (m *MockDiscordSession) ChannelMessageSendEmbed(
 	channelID string, embed *discordgo.MessageEmbed,
 	options ...discordgo.RequestOption,
 ) (*discordgo.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.channelEmbeds[channelID] = append(m.channelEmbeds[channelID], embed)
 	return nil, nil
 }
 
@@ -59,7 +103,10 @@ func (m *MockDiscordSession) ChannelMessageEditEmbed(channelID string, messageID
 	return nil, nil
 }
 
-func (m *MockDiscordSession) ChannelMessageEditComplex(_ *discordgo.MessageEdit, options ...discordgo.RequestOption) (st *discordgo.Message, err error) {
+func (m *MockDiscordSession) ChannelMessageEditComplex(edit *discordgo.MessageEdit, options ...discordgo.RequestOption) (st *discordgo.Message, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messageEdits[edit.ID] = edit
 	return nil, nil
 }
 
@@ -77,9 +124,26 @@ func (m *MockDiscordSession) InteractionRespond(
 	options ...discordgo.RequestOption,
 ) error {
 	log.Println("InteractionRespond")
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.interactionResponses[interaction.ChannelID] = append(m.interactionResponses[interaction.ChannelID], resp)
 	return nil
 }
 
+// returns a thread channel with the messageID as the channel.ID
+func (m *MockDiscordSession) MessageThreadStartComplex(channelID, messageID string, data *discordgo.ThreadStart, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	channel := &discordgo.Channel{
+		ID:       messageID,
+		ParentID: channelID,
+		Name:     data.Name,
+		Type:     discordgo.ChannelTypeGuildPublicThread,
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.startedThreads[channelID] = append(m.startedThreads[channelID], channel)
+	return channel, nil
+}
+
 // returns a channel with USER_ID as the channel.ID
 func (m *MockDiscordSession) UserChannelCreate(userID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
 	return &discordgo.Channel{
@@ -95,12 +159,20 @@ func (m *MockDiscordSession) GuildScheduledEventCreate(guildID string, event *di
 	return nil, nil
 }
 
-func (m *MockDiscordSession) GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+func (m *MockDiscordSession) GuildScheduledEvents(guildID string, userCount bool, options ...discordgo.RequestOption) ([]*discordgo.GuildScheduledEvent, error) {
 	return nil, nil
 }
 
+func (m *MockDiscordSession) GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.guildChannels[guildID], nil
+}
+
 func (m *MockDiscordSession) UserChannelPermissions(userID string, channelID string, fetchOptions ...discordgo.RequestOption) (int64, error) {
-	return 0, nil
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.channelPermissions[userID+channelID], nil
 }
 
 func (m *MockDiscordSession) ApplicationCommandCreate(appID string, guildID string, cmd *discordgo.ApplicationCommand, options ...discordgo.RequestOption) (*discordgo.ApplicationCommand, error) {
@@ -108,9 +180,45 @@ func (m *MockDiscordSession) ApplicationCommandCreate(appID string, guildID stri
 }
 
 func (m *MockDiscordSession) AddHandler(handler interface{}) func() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers = append(m.handlers, handler)
 	return func() {}
 }
 
+// Clicks the button with the label in the last message with components sent to the channel
+func (m *MockDiscordSession) ClickButton(channelID string, label string, interaction *discordgo.InteractionCreate) error {
+	m.mu.Lock()
+	var customID string
+	for _, component := range m.channelComponents[channelID] {
+		row, ok := component.(discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+		for _, rowComponent := range row.Components {
+			if button, ok := rowComponent.(discordgo.Button); ok && button.Label == label {
+				customID = button.CustomID
+			}
+		}
+	}
+	handlers := m.handlers
+	m.mu.Unlock()
+
+	if customID == "" {
+		return fmt.Errorf("no button %s in channel %s", label, channelID)
+	}
+
+	interaction.Type = discordgo.InteractionMessageComponent
+	interaction.ChannelID = channelID
+	interaction.Data = discordgo.MessageComponentInteractionData{CustomID: customID}
+	for _, handler := range handlers {
+		if h, ok := handler.(func(*discordgo.Session, *discordgo.InteractionCreate)); ok {
+			h(nil, interaction)
+		}
+	}
+	return nil
+}
+
 func (m *MockDiscordSession) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
 	return nil, nil
 }
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestModeration(t *testing.T) {
+	t.Parallel()
+	requests := 0
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		requests = n
+		var message string
+		for _, m := range req.Messages {
+			if m.Role == openai.ChatMessageRoleUser {
+				message = m.Content
+			}
+		}
+		switch {
+		case strings.Contains(message, "news"):
+			writeCompletion(w, req.Model, "the news is quiet")
+		case strings.Contains(message, "draw"):
+			json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+				Model: req.Model,
+				Choices: []openai.ChatCompletionChoice{{
+					Message: openai.ChatCompletionMessage{
+						Role: openai.ChatMessageRoleAssistant,
+						ToolCalls: []openai.ToolCall{{
+							ID:       "1",
+							Type:     openai.ToolTypeFunction,
+							Function: openai.FunctionCall{Name: skippy.GenerateImage, Arguments: `{"prompt": "a forbidden cake"}`},
+						}},
+					},
+					FinishReason: openai.FinishReasonToolCalls,
+				}},
+			})
+		default:
+			writeCompletion(w, req.Model, "here is the secret recipe")
+		}
+	})
+	fake.Moderator = skippy.NewBlocklistModerator([]string{"forbidden", "Secret Recipe"})
+	guildID := GenerateRandomID(10)
+	logChannelID := GenerateRandomID(10)
+
+	skippy.OnInteraction(&discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			GuildID:   guildID,
+			ChannelID: GenerateRandomID(10),
+			Member: &discordgo.Member{
+				User:        &discordgo.User{ID: USER_ID},
+				Permissions: discordgo.PermissionManageServer,
+			},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.MODERATION,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					stringOption(skippy.STRICTNESS, skippy.MODERATION_HIGH),
+					{Type: discordgo.ApplicationCommandOptionChannel, Name: skippy.LOG_CHANNEL, Value: logChannelID},
+				},
+			},
+		},
+	}, fake)
+
+	setting, err := s.DB.GetModerationSetting(guildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if setting.Strictness != skippy.MODERATION_HIGH || setting.LogChannelID != logChannelID {
+		t.Fatal("Expected the moderation setting to be saved, got ", setting)
+	}
+
+	getResponse := func(guildID string, message string) (string, error) {
+		return skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+			GuildID:      guildID,
+			ChannelID:    GenerateRandomID(10),
+			UserID:       USER_ID,
+			Message:      message,
+			UserMessage:  message,
+			DisableTools: true,
+		})
+	}
+
+	if _, err := getResponse(guildID, "tell me something FORBIDDEN!"); !errors.Is(err, skippy.ErrContentFlagged) {
+		t.Error("Expected the message to be flagged, got ", err)
+	}
+	if requests != 0 {
+		t.Error("Expected a flagged message to not be sent to the model, got ", requests)
+	}
+	if _, err := getResponse(guildID, "what's for dinner?"); !errors.Is(err, skippy.ErrContentFlagged) {
+		t.Error("Expected the response to be flagged, got ", err)
+	}
+	// only the user's own text is checked, not the reply chain, files or prompts around it
+	response, err := skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		GuildID:      guildID,
+		ChannelID:    GenerateRandomID(10),
+		UserID:       USER_ID,
+		Message:      "> a forbidden reply\nany news?",
+		UserMessage:  "any news?",
+		DisableTools: true,
+	})
+	if err != nil || response != "the news is quiet" {
+		t.Error("Expected only the user's message to be moderated, got ", response, err)
+	}
+
+	// tool arguments are checked before any tool is run
+	before := requests
+	_, err = skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		GuildID:     guildID,
+		ChannelID:   GenerateRandomID(10),
+		UserID:      USER_ID,
+		Message:     "draw me a cake",
+		UserMessage: "draw me a cake",
+	})
+	if !errors.Is(err, skippy.ErrContentFlagged) || requests != before+1 {
+		t.Error("Expected the tool call to be flagged before it is run, got ", err, requests-before)
+	}
+
+	// guilds that have not set a strictness use the default which is off
+	if response, err := getResponse(GenerateRandomID(10), "forbidden"); err != nil || response != "here is the secret recipe" {
+		t.Error("Expected content to not be moderated when moderation is off, got ", response, err)
+	}
+
+	server, imgRequests, _ := newImageAPIServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	fixture.Moderator = fake.Moderator
+	outputs := skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.GenerateImage,
+			Arguments: `{"prompt": "a forbidden cake"}`,
+		}}},
+		skippy.ToolContext{GuildID: guildID, ChannelID: GenerateRandomID(10), UserID: USER_ID},
+		fixture,
+	)
+	if !strings.Contains(outputs[0].Content, skippy.TOOL_ERROR_FLAGGED) || len(*imgRequests) != 0 {
+		t.Error("Expected the image prompt to be flagged, got ", outputs[0].Content)
+	}
+
+	dg.mu.Lock()
+	logs := dg.channelEmbeds[logChannelID]
+	dg.mu.Unlock()
+	if len(logs) != 4 {
+		t.Fatal("Expected the flagged message, response, tool call and image prompt to be logged, got ", len(logs))
+	}
+	for i, source := range []string{
+		skippy.MODERATION_SOURCE_MESSAGE,
+		skippy.MODERATION_SOURCE_RESPONSE,
+		skippy.MODERATION_SOURCE_TOOL_CALL,
+		skippy.MODERATION_SOURCE_IMAGE_PROMPT,
+	} {
+		if logs[i].Title != "Flagged "+source {
+			t.Errorf("Expected log %d to be for the %s, got %s", i, source, logs[i].Title)
+		}
+	}
+	if logs[0].Description != "tell me something FORBIDDEN!" {
+		t.Error("Expected the flagged content in the log, got ", logs[0].Description)
+	}
+}
@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+	rateLimiter := skippy.NewRateLimiter()
+	limit := skippy.RateLimit{PerMinute: 60, Burst: 2}
+	key := GenerateRandomID(10)
+
+	for i := 0; i < limit.Burst; i++ {
+		if ok, _ := rateLimiter.Allow(key, limit); !ok {
+			t.Fatal("Expected request within burst to be allowed")
+		}
+	}
+
+	ok, wait := rateLimiter.Allow(key, limit)
+	if ok {
+		t.Fatal("Expected request over burst to be limited")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Error("Expected wait to be under a second, got ", wait)
+	}
+
+	if !rateLimiter.ShouldNotify(key, wait) {
+		t.Error("Expected first limited request to notify")
+	}
+	if rateLimiter.ShouldNotify(key, wait) {
+		t.Error("Expected second limited request to not notify")
+	}
+
+	// other keys have their own bucket
+	if ok, _ := rateLimiter.Allow(GenerateRandomID(10), limit); !ok {
+		t.Error("Expected different key to be allowed")
+	}
+
+	time.Sleep(wait)
+	if ok, _ := rateLimiter.Allow(key, limit); !ok {
+		t.Error("Expected request to be allowed after waiting")
+	}
+}
+
+func TestRateLimiterAllowAll(t *testing.T) {
+	t.Parallel()
+	rateLimiter := skippy.NewRateLimiter()
+	user := skippy.RateLimitKey{Key: GenerateRandomID(10), Limit: skippy.RateLimit{PerMinute: 1, Burst: 2}}
+	channel := skippy.RateLimitKey{Key: GenerateRandomID(10), Limit: skippy.RateLimit{PerMinute: 1, Burst: 1}}
+
+	if _, _, ok := rateLimiter.AllowAll(user, channel); !ok {
+		t.Fatal("Expected the first request to be allowed")
+	}
+	key, _, ok := rateLimiter.AllowAll(user, channel)
+	if ok || key != channel.Key {
+		t.Fatal("Expected the channel to be limited, got ", key)
+	}
+	// the limited request must not have used the user's last token
+	if ok, _ := rateLimiter.Allow(user.Key, user.Limit); !ok {
+		t.Error("Expected the user to still have a token")
+	}
+}
+
+func TestRateLimitsCommand(t *testing.T) {
+	t.Parallel()
+	guildID := GenerateRandomID(10)
+	channelID := GenerateRandomID(10)
+	runCommand := func(permissions int64, options ...*discordgo.ApplicationCommandInteractionDataOption) {
+		skippy.OnInteraction(&discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Type:      discordgo.InteractionApplicationCommand,
+				GuildID:   guildID,
+				ChannelID: channelID,
+				Member: &discordgo.Member{
+					User:        &discordgo.User{ID: USER_ID},
+					Permissions: permissions,
+				},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name:    skippy.RATE_LIMITS,
+					Options: options,
+				},
+			},
+		}, s)
+	}
+
+	runCommand(0, intOption(skippy.USER_BURST, 1))
+	if setting, err := s.DB.GetRateLimitSetting(guildID); err != nil || setting != nil {
+		t.Fatal("Expected members without Manage Server to not change the limits, got ", setting, err)
+	}
+
+	runCommand(discordgo.PermissionManageServer,
+		&discordgo.ApplicationCommandInteractionDataOption{Type: discordgo.ApplicationCommandOptionNumber, Name: skippy.USER_PER_MINUTE, Value: 2.0},
+		intOption(skippy.USER_BURST, 1),
+	)
+	setting, err := s.DB.GetRateLimitSetting(guildID)
+	if err != nil || setting == nil {
+		t.Fatal("Expected the rate limits to be saved, got ", err)
+	}
+	if setting.Limits.User != (skippy.RateLimit{PerMinute: 2, Burst: 1}) || setting.Limits.Channel != s.Config.RateLimits.Channel {
+		t.Error("Expected only the user limit to change, got ", setting.Limits)
+	}
+
+	runCommand(discordgo.PermissionManageServer, &discordgo.ApplicationCommandInteractionDataOption{
+		Type: discordgo.ApplicationCommandOptionBoolean, Name: skippy.RESET, Value: true,
+	})
+	if setting, err := s.DB.GetRateLimitSetting(guildID); err != nil || setting != nil {
+		t.Error("Expected reset to remove the guild limits, got ", setting, err)
+	}
+}
+
+func TestSlashCommandRateLimit(t *testing.T) {
+	t.Parallel()
+	requests := make(chan string, 10)
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		requests <- req.Model
+		writeCompletion(w, req.Model, "hello channel")
+	})
+	fake.RateLimiter = skippy.NewRateLimiter()
+	bypassRole := GenerateRandomID(10)
+	fake.Config.RateLimitBypassRoles = []string{bypassRole}
+	guildID := GenerateRandomID(10)
+	if err := s.DB.SetRateLimitSetting(&skippy.RateLimitSetting{GuildID: guildID, Limits: skippy.RateLimits{
+		User:    skippy.RateLimit{PerMinute: 1, Burst: 1},
+		Channel: skippy.RateLimit{PerMinute: 1, Burst: 1},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+	channelID := GenerateRandomID(10)
+	sendMessage := func(roles ...string) *discordgo.InteractionResponse {
+		t.Helper()
+		skippy.OnInteraction(&discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Type:      discordgo.InteractionApplicationCommand,
+				GuildID:   guildID,
+				ChannelID: channelID,
+				Member:    &discordgo.Member{User: &discordgo.User{ID: USER_ID}, Roles: roles},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: skippy.SEND_MESSAGE,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{Type: discordgo.ApplicationCommandOptionChannel, Name: skippy.CHANNEL, Value: channelID},
+						stringOption(skippy.MESSAGE, "say hello"),
+					},
+				},
+			},
+		}, fake)
+		dg.mu.Lock()
+		defer dg.mu.Unlock()
+		responses := dg.interactionResponses[channelID]
+		if len(responses) == 0 {
+			t.Fatal("Expected an interaction response")
+		}
+		return responses[len(responses)-1]
+	}
+	waitForRequest := func() {
+		t.Helper()
+		select {
+		case <-requests:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected a completion request")
+		}
+	}
+
+	if response := sendMessage(); response.Data.Flags&discordgo.MessageFlagsEphemeral == 0 || response.Data.Content != "On it!" {
+		t.Error("Expected the first command to be answered, got ", response.Data.Content)
+	}
+	waitForRequest()
+
+	// slash commands share the limits of mentions
+	response := sendMessage()
+	if !strings.Contains(response.Data.Content, "<@"+USER_ID+">") || response.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Error("Expected the cooldown to be sent to the user, got ", response.Data.Content)
+	}
+	select {
+	case model := <-requests:
+		t.Error("Expected no request while rate limited, got one for ", model)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if response := sendMessage(bypassRole); response.Data.Content != "On it!" {
+		t.Error("Expected the bypass role to skip the limits, got ", response.Data.Content)
+	}
+	waitForRequest()
+}
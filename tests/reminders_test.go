@@ -0,0 +1,465 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestReminderManagement(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	userA := GenerateRandomID(10)
+	userB := GenerateRandomID(10)
+
+	setReminder := func(userID string, message string) {
+		skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{
+				ID: "1",
+				Function: openai.FunctionCall{
+					Name:      skippy.SetReminder,
+					Arguments: fmt.Sprintf(`{"message": "%s", "timer_length": 3600}`, message),
+				},
+			}},
+			skippy.ToolContext{ChannelID: channelID, UserID: userID},
+			s,
+		)
+	}
+	setReminder(userA, "take out the trash")
+	setReminder(userA, "call mom")
+	setReminder(userB, "take out the trash")
+
+	if len(getUserReminders(t, userA)) != 2 || len(getUserReminders(t, userB)) != 1 {
+		t.Fatal("Expected reminders to be created for each user")
+	}
+
+	skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{
+			ID:       "1",
+			Function: openai.FunctionCall{Name: skippy.CancelReminder, Arguments: `{"search": "trash"}`},
+		}},
+		skippy.ToolContext{ChannelID: channelID, UserID: userA},
+		s,
+	)
+
+	remindersA := getUserReminders(t, userA)
+	if len(remindersA) != 1 || remindersA[0].Message != "call mom" {
+		t.Error("Expected the trash reminder to be canceled")
+	}
+	remindersB := getUserReminders(t, userB)
+	if len(remindersB) != 1 {
+		t.Fatal("Expected other users reminders to not be canceled")
+	}
+	if !s.Scheduler.HasReminderJob(remindersB[0].ID) {
+		t.Error("Expected other users reminder to still be scheduled")
+	}
+
+	interaction := &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: channelID,
+			Member: &discordgo.Member{
+				User: &discordgo.User{
+					ID: userA,
+				},
+			},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.REMINDERS,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Type: discordgo.ApplicationCommandOptionSubCommand,
+						Name: skippy.CANCEL,
+						Options: []*discordgo.ApplicationCommandInteractionDataOption{
+							{
+								Type:  discordgo.ApplicationCommandOptionInteger,
+								Name:  skippy.ID,
+								Value: float64(remindersB[0].ID),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	if _, err := s.DB.GetReminder(remindersB[0].ID); err != nil {
+		t.Error("Expected users to not be able to cancel other users reminders")
+	}
+
+	interaction.Member.User.ID = userB
+	interaction.Data = discordgo.ApplicationCommandInteractionData{
+		Name: skippy.REMINDERS,
+		Options: []*discordgo.ApplicationCommandInteractionDataOption{
+			{
+				Type: discordgo.ApplicationCommandOptionSubCommand,
+				Name: skippy.SNOOZE,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Type:  discordgo.ApplicationCommandOptionInteger,
+						Name:  skippy.ID,
+						Value: float64(remindersB[0].ID),
+					},
+					{
+						Type:  discordgo.ApplicationCommandOptionInteger,
+						Name:  skippy.MINUTES,
+						Value: float64(5),
+					},
+				},
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	reminder, err := s.DB.GetReminder(remindersB[0].ID)
+	if err != nil {
+		t.Fatal("Expected reminder to exist")
+	}
+	if time.Until(reminder.RemindAt) > 6*time.Minute {
+		t.Error("Expected reminder to be snoozed")
+	}
+}
+
+func TestAbsoluteAndRecurringReminders(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{ChannelID: channelID, UserID: userID}
+
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remindAt := time.Now().In(location).Add(48 * time.Hour).Truncate(time.Minute)
+
+	toolCalls := []openai.ToolCall{
+		{ID: "1", Function: openai.FunctionCall{
+			Name: skippy.SetReminder,
+			Arguments: fmt.Sprintf(
+				`{"message": "dentist", "time": "%s", "timezone": "America/New_York"}`,
+				remindAt.Format("2006-01-02T15:04"),
+			),
+		}},
+		{ID: "2", Function: openai.FunctionCall{
+			Name:      skippy.SetReminder,
+			Arguments: `{"message": "standup", "recurrence": "0 9 * * 1-5"}`,
+		}},
+		{ID: "3", Function: openai.FunctionCall{
+			Name:      skippy.SetReminder,
+			Arguments: `{"message": "too late", "time": "2001-01-01T09:00"}`,
+		}},
+	}
+	skippy.GetToolOutputs(context.Background(), toolCalls, toolCtx, s)
+
+	reminders := getUserReminders(t, userID)
+	if len(reminders) != 2 {
+		t.Fatal("Expected a reminder at a time and a recurring reminder, got ", len(reminders))
+	}
+
+	for _, reminder := range reminders {
+		if !s.Scheduler.HasReminderJob(reminder.ID) {
+			t.Errorf("Expected reminder %d to be scheduled", reminder.ID)
+		}
+
+		switch reminder.Message {
+		case "dentist":
+			if !reminder.RemindAt.Equal(remindAt) {
+				t.Errorf("Expected reminder at %s, got %s", remindAt, reminder.RemindAt)
+			}
+		case "standup":
+			if !reminder.IsRecurring() || reminder.Timezone != s.Config.Timezone {
+				t.Error("Expected reminder to repeat in the default timezone")
+			}
+			chicago, _ := time.LoadLocation(s.Config.Timezone)
+			nextRun := reminder.RemindAt.In(chicago)
+			if nextRun.Hour() != 9 || nextRun.Weekday() == time.Saturday || nextRun.Weekday() == time.Sunday {
+				t.Error("Expected next run to be on a weekday at 9:00, got ", reminder.RemindAt)
+			}
+		default:
+			t.Error("Expected reminders in the past to not be set")
+		}
+	}
+
+	output := skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{Name: skippy.ListReminders, Arguments: `{}`}}},
+		toolCtx,
+		s,
+	)
+	if !strings.Contains(output[0].Content, "repeats `0 9 * * 1-5`") {
+		t.Error("Expected recurring reminders to be listed, got ", output[0].Content)
+	}
+}
+
+func TestReminderButtons(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+
+	skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.SetReminder,
+			Arguments: `{"message": "stretch", "timer_length": 1, "follow_up_minutes": []}`,
+		}}},
+		skippy.ToolContext{ChannelID: channelID, UserID: userID},
+		s,
+	)
+	reminders := getUserReminders(t, userID)
+	if len(reminders) != 1 {
+		t.Fatal("Expected reminder to be created")
+	}
+	reminderID := reminders[0].ID
+	if reminders[0].FollowUps == nil || len(reminders[0].FollowUps) != 0 {
+		t.Error("Expected reminder to have no follow ups")
+	}
+
+	waitForReminder := func() {
+		t.Helper()
+		waitForReminderSent(t, reminderID)
+	}
+	waitForReminder()
+
+	click := func(label string, clickUserID string) {
+		t.Helper()
+		err := dg.ClickButton(channelID, label, &discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Member:  &discordgo.Member{User: &discordgo.User{ID: clickUserID}},
+				Message: &discordgo.Message{ID: GenerateRandomID(10), Content: "stretch"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the ids do not depend on callbacks in memory so the buttons work after a restart
+	dg.mu.Lock()
+	row := dg.channelComponents[channelID][0].(discordgo.ActionsRow)
+	dg.mu.Unlock()
+	if customID := row.Components[0].(discordgo.Button).CustomID; customID != fmt.Sprintf("reminder:%d:done", reminderID) {
+		t.Error("Expected a stable id for the done button, got ", customID)
+	}
+
+	click("Snooze 10m", GenerateRandomID(10))
+	if reminder, _ := s.DB.GetReminder(reminderID); !reminder.Sent {
+		t.Error("Expected other users to not be able to snooze the reminder")
+	}
+	dg.mu.Lock()
+	responses := dg.interactionResponses[channelID]
+	dg.mu.Unlock()
+	if len(responses) != 1 || responses[0].Data == nil || responses[0].Data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Error("Expected other users to be told they can not respond to the reminder")
+	}
+
+	click("Snooze 10m", userID)
+	reminder, err := s.DB.GetReminder(reminderID)
+	if err != nil {
+		t.Fatal("Expected snoozed reminder to exist")
+	}
+	if reminder.Sent || time.Until(reminder.RemindAt) < 9*time.Minute {
+		t.Error("Expected reminder to be snoozed for 10 minutes")
+	}
+
+	// send the reminder again to get new buttons
+	skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.EditReminder,
+			Arguments: fmt.Sprintf(`{"id": %d, "timer_length": 1}`, reminderID),
+		}}},
+		skippy.ToolContext{ChannelID: channelID, UserID: userID},
+		s,
+	)
+	waitForReminder()
+
+	click(skippy.REMINDER_DONE, userID)
+	if _, err := s.DB.GetReminder(reminderID); err == nil {
+		t.Error("Expected reminder to be done")
+	}
+}
+
+func TestRemindOtherUsers(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+	targetUserID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{GuildID: GUILD_ID, ChannelID: channelID, UserID: userID}
+
+	setReminder := func(toolCtx skippy.ToolContext) string {
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name: skippy.SetReminder,
+				Arguments: fmt.Sprintf(
+					`{"message": "bring the cables", "timer_length": 3600, "target_user_id": "<@%s>", "delivery": "dm"}`,
+					targetUserID,
+				),
+			}}},
+			toolCtx,
+			s,
+		)
+		return outputs[0].Content
+	}
+	setReminder(toolCtx)
+
+	reminders := getUserReminders(t, targetUserID)
+	if len(reminders) != 1 {
+		t.Fatal("Expected reminder to be listed for the target user")
+	}
+	reminder := reminders[0]
+	if reminder.UserID != userID || reminder.TargetUserID != targetUserID {
+		t.Error("Expected reminder to be from the requester for the target user")
+	}
+	// the mock dm channel has the same id as the user
+	if reminder.Delivery != skippy.REMINDER_DELIVERY_DM || reminder.ChannelID != targetUserID {
+		t.Error("Expected reminder to be sent by DM")
+	}
+
+	if output := setReminder(skippy.ToolContext{ChannelID: channelID, UserID: userID}); !strings.Contains(output, "server") {
+		t.Error("Expected reminders for other users to require a server, got ", output)
+	}
+
+	// the target can only finish or snooze a reminder. the creator cancels and edits it
+	targetCtx := skippy.ToolContext{GuildID: GUILD_ID, ChannelID: channelID, UserID: targetUserID}
+	for _, name := range []string{skippy.CancelReminder, skippy.EditReminder} {
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name:      name,
+				Arguments: fmt.Sprintf(`{"id": %d, "message": "forget the cables"}`, reminder.ID),
+			}}},
+			targetCtx,
+			s,
+		)
+		if !strings.Contains(outputs[0].Content, "no reminders") {
+			t.Errorf("Expected the target to not be able to %s the reminder, got %s", name, outputs[0].Content)
+		}
+	}
+	if saved, err := s.DB.GetReminder(reminder.ID); err != nil || saved.Message != "bring the cables" {
+		t.Error("Expected the reminder to be unchanged by the target, got ", saved, err)
+	}
+	outputs := skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.EditReminder,
+			Arguments: fmt.Sprintf(`{"id": %d, "message": "bring all the cables"}`, reminder.ID),
+		}}},
+		toolCtx,
+		s,
+	)
+	if !strings.Contains(outputs[0].Content, "bring all the cables") {
+		t.Error("Expected the creator to edit the reminder, got ", outputs[0].Content)
+	}
+
+	interaction := &discordgo.InteractionCreate{
+		Interaction: &discordgo.Interaction{
+			Type:      discordgo.InteractionApplicationCommand,
+			ChannelID: channelID,
+			Member:    &discordgo.Member{User: &discordgo.User{ID: targetUserID}},
+			Data: discordgo.ApplicationCommandInteractionData{
+				Name: skippy.REMINDERS,
+				Options: []*discordgo.ApplicationCommandInteractionDataOption{
+					{
+						Type: discordgo.ApplicationCommandOptionSubCommand,
+						Name: skippy.FROM_OTHERS,
+						Options: []*discordgo.ApplicationCommandInteractionDataOption{
+							{Type: discordgo.ApplicationCommandOptionBoolean, Name: skippy.ALLOW, Value: false},
+						},
+					},
+				},
+			},
+		},
+	}
+	skippy.OnInteraction(interaction, s)
+
+	if len(getUserReminders(t, targetUserID)) != 0 {
+		t.Error("Expected reminders from other users to be canceled after opting out")
+	}
+	if output := setReminder(toolCtx); !strings.Contains(output, "opted out") {
+		t.Error("Expected reminders to be refused after opting out, got ", output)
+	}
+}
+
+func getUserReminders(t *testing.T, userID string) []skippy.Reminder {
+	t.Helper()
+	reminders, err := s.DB.GetRemindersByUser(userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reminders
+}
+
+// Waits for a reminder to be sent and returns it
+func waitForReminderSent(t *testing.T, id uint) *skippy.Reminder {
+	t.Helper()
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			t.Fatal("Expected reminder to be sent")
+		default:
+			if reminder, err := s.DB.GetReminder(id); err == nil && reminder.Sent {
+				return reminder
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+func TestReminderReplyAck(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	userID := GenerateRandomID(10)
+
+	skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.SetReminder,
+			Arguments: `{"message": "water the plants", "timer_length": 1, "follow_up_minutes": []}`,
+		}}},
+		skippy.ToolContext{ChannelID: channelID, UserID: userID},
+		s,
+	)
+	reminders := getUserReminders(t, userID)
+	if len(reminders) != 1 {
+		t.Fatal("Expected reminder to be created")
+	}
+	reminder := waitForReminderSent(t, reminders[0].ID)
+	if reminder.MessageID == "" {
+		t.Fatal("Expected the reminder message to be saved")
+	}
+
+	sendMessage := func(reference *discordgo.MessageReference) {
+		skippy.OnMessageCreate(&discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ID:               GenerateRandomID(10),
+				ChannelID:        channelID,
+				Content:          "ok",
+				Author:           &discordgo.User{ID: userID},
+				MessageReference: reference,
+			},
+		}, s)
+	}
+
+	sendMessage(nil)
+	sendMessage(&discordgo.MessageReference{ChannelID: channelID, MessageID: GenerateRandomID(10)})
+	if pending, _ := s.DB.GetPendingReminders(channelID, userID); len(pending) != 1 {
+		t.Fatal("Expected messages that do not reply to the reminder to not acknowledge it")
+	}
+
+	sendMessage(&discordgo.MessageReference{ChannelID: channelID, MessageID: reminder.MessageID})
+	if pending, _ := s.DB.GetPendingReminders(channelID, userID); len(pending) != 0 {
+		t.Error("Expected replying to the reminder to acknowledge it")
+	}
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"skippybot/skippy"
+
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// creates a skippy that uses a fake completion server
+// handler is called with the number of the request starting at 1
+func newFakeCompletionSkippy(t *testing.T, handler func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter)) *skippy.Skippy {
+	var mu sync.Mutex
+	n := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req openai.ChatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		n++
+		count := n
+		mu.Unlock()
+		handler(count, req, w)
+	}))
+	t.Cleanup(server.Close)
+
+	config := *s.Config
+	config.RetryBaseDelay = time.Millisecond
+	config.RetryMaxDelay = 2 * time.Second
+	config.MaxRetries = 2
+
+	return &skippy.Skippy{
+		DiscordSession: dg,
+		AIClient:       skippy.NewAIClient("test", server.URL),
+		Config:         &config,
+		State:          skippy.NewState(),
+		DB:             s.DB,
+		Scheduler:      s.Scheduler,
+	}
+}
+
+func writeCompletion(w http.ResponseWriter, model string, content string) {
+	json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+		Model: model,
+		Choices: []openai.ChatCompletionChoice{
+			{
+				Message: openai.ChatCompletionMessage{
+					Role:    openai.ChatMessageRoleAssistant,
+					Content: content,
+				},
+				FinishReason: openai.FinishReasonStop,
+			},
+		},
+	})
+}
+
+func TestRetryAfterRateLimit(t *testing.T) {
+	t.Parallel()
+	requests := 0
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		requests = n
+		if n == 1 {
+			w.Header().Set("Retry-After", "0.1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"error": {"message": "rate limited", "type": "requests"}}`))
+			return
+		}
+		writeCompletion(w, req.Model, "hello")
+	})
+
+	response, err := skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		ChannelID:    GenerateRandomID(10),
+		Message:      "test",
+		DisableTools: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "hello" {
+		t.Error("Expected response from retried request")
+	}
+	if requests != 2 {
+		t.Error("Expected request to be retried once, got ", requests)
+	}
+}
+
+func TestFallbackModel(t *testing.T) {
+	t.Parallel()
+	fallback := "fallback-model"
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		if req.Model != fallback {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"message": "overloaded", "type": "server_error"}}`))
+			return
+		}
+		writeCompletion(w, req.Model, "from fallback")
+	})
+	fake.Config.FallbackModels = []string{fallback}
+
+	response, err := skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		ChannelID:    GenerateRandomID(10),
+		Message:      "test",
+		DisableTools: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "from fallback" {
+		t.Error("Expected response from fallback model")
+	}
+}
+
+func TestFallbackModelSupport(t *testing.T) {
+	t.Parallel()
+	noTools := "no-tools-model"
+	noVision := "no-vision-model"
+	capable := "capable-model"
+	var models []string
+	var mu sync.Mutex
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+		if req.Model != capable {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error": {"message": "overloaded", "type": "server_error"}}`))
+			return
+		}
+		writeCompletion(w, req.Model, "from capable fallback")
+	})
+	fake.Config.MaxRetries = 0
+	fake.Config.FallbackModels = []string{noTools, noVision, capable}
+	fake.Config.NoToolModels = []string{noTools}
+	fake.Config.VisionModels = []string{fake.Config.DefaultModel, noTools, capable}
+
+	// fallbacks that can not use the tools or see the image are skipped
+	response, err := skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		ChannelID: GenerateRandomID(10),
+		Message:   "what is in this picture",
+		Images:    []skippy.ImageInput{{Name: "photo.png", URL: "https://example.com/photo.png"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response != "from capable fallback" {
+		t.Error("Expected the response from the capable fallback, got ", response)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(models) != 2 || models[0] != fake.Config.DefaultModel || models[1] != capable {
+		t.Error("Expected only the default and capable models to be tried, got ", models)
+	}
+}
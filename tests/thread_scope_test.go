@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestGetThreadID(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	discordThreadID := GenerateRandomID(10)
+	err := dg.State.ChannelAdd(&discordgo.Channel{
+		ID:      discordThreadID,
+		GuildID: GUILD_ID,
+		Type:    discordgo.ChannelTypeGuildPublicThread,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userThreadID := func(channelID string) string {
+		return fmt.Sprintf(skippy.USER_THREAD_FORMAT, channelID, USER_ID)
+	}
+
+	for _, test := range []struct {
+		scope         skippy.ThreadScope
+		channel       string
+		discordThread string
+	}{
+		{skippy.SCOPE_CHANNEL, channelID, discordThreadID},
+		{skippy.SCOPE_USER, userThreadID(channelID), userThreadID(discordThreadID)},
+		{skippy.SCOPE_DISCORD_THREAD, userThreadID(channelID), discordThreadID},
+	} {
+		fake := newFakeCompletionSkippy(t, nil)
+		fake.Config.ThreadScope = test.scope
+
+		if threadID := skippy.GetThreadID(channelID, USER_ID, fake); threadID != test.channel {
+			t.Errorf("Expected %s scope to use %s in a channel, got %s", test.scope, test.channel, threadID)
+		}
+		if threadID := skippy.GetThreadID(discordThreadID, USER_ID, fake); threadID != test.discordThread {
+			t.Errorf("Expected %s scope to use %s in a discord thread, got %s", test.scope, test.discordThread, threadID)
+		}
+	}
+}
+
+func TestAutoCreateThread(t *testing.T) {
+	t.Parallel()
+	for _, scope := range []skippy.ThreadScope{skippy.SCOPE_CHANNEL, skippy.SCOPE_USER, skippy.SCOPE_DISCORD_THREAD} {
+		t.Run(string(scope), func(t *testing.T) {
+			t.Parallel()
+			fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+				writeCompletion(w, req.Model, "continuing in the thread")
+			})
+			fake.Config.ThreadScope = scope
+			fake.Config.AutoCreateThreads = true
+			fake.Config.AutoThreadMessageCount = 2
+			channelID := GenerateRandomID(10)
+			messageID := GenerateRandomID(10)
+
+			threadID := skippy.GetThreadID(channelID, USER_ID, fake)
+			fake.State.NewThread(threadID)
+			fake.State.SetThreadMessages(threadID, []openai.ChatCompletionMessage{
+				{Role: openai.ChatMessageRoleUser, Content: "hello"},
+				{Role: openai.ChatMessageRoleAssistant, Content: "hello monkey"},
+			})
+			fake.State.SetAlwaysRespond(channelID, true)
+
+			skippy.OnMessageCreate(&discordgo.MessageCreate{
+				Message: &discordgo.Message{
+					ID:        messageID,
+					GuildID:   GUILD_ID,
+					ChannelID: channelID,
+					Content:   "<@" + BOT_ID + "> " + strings.Repeat("é", skippy.AUTO_THREAD_NAME_MAX_LEN+10),
+					Author:    &discordgo.User{ID: USER_ID},
+					Mentions:  []*discordgo.User{{ID: BOT_ID}},
+				},
+			}, fake)
+
+			dg.mu.Lock()
+			threads := dg.startedThreads[channelID]
+			sent := dg.channelMessages[messageID]
+			dg.mu.Unlock()
+			if len(threads) != 1 {
+				t.Fatal("Expected a discord thread to be started, got ", len(threads))
+			}
+			name := threads[0].Name
+			if !utf8.ValidString(name) || utf8.RuneCountInString(name) != skippy.AUTO_THREAD_NAME_MAX_LEN {
+				t.Error("Expected the thread name to be cut to whole characters, got ", name)
+			}
+			if len(sent) != 1 {
+				t.Error("Expected the response in the discord thread, got ", sent)
+			}
+
+			// the thread is not in the state cache so the id is based on the scope alone
+			discordThreadID := messageID
+			if scope == skippy.SCOPE_USER {
+				discordThreadID = fmt.Sprintf(skippy.USER_THREAD_FORMAT, messageID, USER_ID)
+			}
+			if length := fake.State.GetThreadLength(discordThreadID); length < 2 {
+				t.Error("Expected the conversation to be copied to the discord thread, got ", length)
+			}
+			if !fake.State.GetAlwaysRespond(messageID) {
+				t.Error("Expected always respond to be kept in the discord thread")
+			}
+
+			if length := fake.State.GetThreadLength(threadID); length != 0 {
+				t.Error("Expected the channel conversation to be reset, got ", length)
+			}
+
+			// the next mention in the channel is answered there instead of starting another thread
+			skippy.OnMessageCreate(&discordgo.MessageCreate{
+				Message: &discordgo.Message{
+					ID:        GenerateRandomID(10),
+					GuildID:   GUILD_ID,
+					ChannelID: channelID,
+					Content:   "<@" + BOT_ID + "> hello again",
+					Author:    &discordgo.User{ID: USER_ID},
+					Mentions:  []*discordgo.User{{ID: BOT_ID}},
+				},
+			}, fake)
+			dg.mu.Lock()
+			threads = dg.startedThreads[channelID]
+			dg.mu.Unlock()
+			if len(threads) != 1 {
+				t.Error("Expected only one discord thread to be started, got ", len(threads))
+			}
+		})
+	}
+}
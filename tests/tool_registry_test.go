@@ -0,0 +1,343 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestToolRegistry(t *testing.T) {
+	t.Parallel()
+
+	fsys := fstest.MapFS{
+		"echo.json": {Data: []byte(`{
+			"name": "echo",
+			"description": "echo the message",
+			"parameters": {
+				"type": "object",
+				"properties": {"message": {"type": "string"}},
+				"required": ["message"]
+			}
+		}`)},
+		"unused.json": {Data: []byte(`{"name": "unused", "description": "has no handler"}`)},
+	}
+	registry, err := skippy.NewToolRegistry(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type echoArgs struct {
+		Message string `json:"message"`
+	}
+	err = skippy.RegisterTool(registry, "echo", func(ctx context.Context, toolCtx skippy.ToolContext, args echoArgs, s *skippy.Skippy) (string, error) {
+		return toolCtx.ChannelID + ":" + args.Message, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Register("missing", nil); err == nil {
+		t.Error("Expected registering a tool without a definition to fail")
+	}
+
+	tools := registry.Tools()
+	if len(tools) != 1 || tools[0].Function.Name != "echo" {
+		t.Fatal("Expected only tools with handlers to be returned")
+	}
+
+	tool, ok := registry.Get("echo")
+	if !ok {
+		t.Fatal("Expected echo tool to be registered")
+	}
+	output, err := tool.Handler(context.Background(), skippy.ToolContext{ChannelID: "channel"}, `{"message": "hi"}`, s)
+	if err != nil || output != "channel:hi" {
+		t.Error("Expected arguments to be decoded, got ", output, err)
+	}
+
+	guildID := GenerateRandomID(10)
+	if err := s.DB.SetToolSetting(&skippy.ToolSetting{GuildID: guildID, DisabledTools: []string{"echo"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.GuildTools(guildID, s)) != 0 {
+		t.Error("Expected echo to be disabled in guild")
+	}
+	if len(registry.GuildTools(GenerateRandomID(10), s)) != 1 {
+		t.Error("Expected echo to be enabled in other guilds")
+	}
+}
+
+func TestToolsCommand(t *testing.T) {
+	t.Parallel()
+	guildID := GenerateRandomID(10)
+	runCommand := func(permissions int64, option string) {
+		skippy.OnInteraction(&discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Type:      discordgo.InteractionApplicationCommand,
+				GuildID:   guildID,
+				ChannelID: GenerateRandomID(10),
+				Member: &discordgo.Member{
+					User:        &discordgo.User{ID: USER_ID},
+					Permissions: permissions,
+				},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: skippy.TOOLS,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						stringOption(option, skippy.GenerateImage),
+					},
+				},
+			},
+		}, s)
+	}
+	isEnabled := func() bool {
+		for _, tool := range skippy.TOOL_REGISTRY.GuildTools(guildID, s) {
+			if tool.Function.Name == skippy.GenerateImage {
+				return true
+			}
+		}
+		return false
+	}
+
+	runCommand(0, skippy.DISABLE)
+	if !isEnabled() {
+		t.Fatal("Expected members without Manage Server to not turn off tools")
+	}
+
+	runCommand(discordgo.PermissionManageServer, skippy.DISABLE)
+	if isEnabled() {
+		t.Fatal("Expected the tool to be turned off in the guild")
+	}
+	outputs := skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+			Name:      skippy.GenerateImage,
+			Arguments: `{"prompt": "a fun party banana"}`,
+		}}},
+		skippy.ToolContext{GuildID: guildID, ChannelID: GenerateRandomID(10), UserID: USER_ID},
+		s,
+	)
+	if !strings.Contains(outputs[0].Content, skippy.TOOL_ERROR_UNKNOWN) {
+		t.Error("Expected the turned off tool to not run, got ", outputs[0].Content)
+	}
+
+	runCommand(discordgo.PermissionManageServer, skippy.ENABLE)
+	if !isEnabled() {
+		t.Error("Expected the tool to be turned back on")
+	}
+}
+
+func TestDefaultToolDefinitions(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{
+		skippy.GetStockPriceKey,
+		skippy.GetWeatherKey,
+		skippy.SendChannelMessage,
+		skippy.SetReminder,
+		skippy.ListReminders,
+		skippy.CancelReminder,
+		skippy.EditReminder,
+		skippy.SnoozeReminder,
+		skippy.GenerateImage,
+		skippy.EditImage,
+		skippy.CreateImageVariation,
+		skippy.EnableMorningMessage,
+		skippy.DisableMorningMessage,
+		skippy.UpdateMorningMessage,
+	} {
+		tool, ok := skippy.TOOL_REGISTRY.Get(name)
+		if !ok || tool.Handler == nil {
+			t.Errorf("Expected %s to be registered with a handler", name)
+		}
+	}
+
+	if _, err := skippy.TOOL_REGISTRY.Tool(skippy.GenerateEvent); err != nil {
+		t.Error("Expected generate_event definition to be loaded")
+	}
+
+	outputs := skippy.GetToolOutputs(
+		context.Background(),
+		[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{Name: "not_a_tool"}}},
+		skippy.ToolContext{ChannelID: GenerateRandomID(10)},
+		s,
+	)
+	if len(outputs) != 1 || outputs[0].ToolCallID != "1" {
+		t.Error("Expected an output for unknown tools")
+	}
+}
+
+func TestSendChannelMessagePermission(t *testing.T) {
+	t.Parallel()
+	guildID := GenerateRandomID(10)
+	channelID := GenerateRandomID(10)
+	announcementsID := GenerateRandomID(10)
+	generalID := GenerateRandomID(10)
+	dg.mu.Lock()
+	dg.guildChannels[guildID] = []*discordgo.Channel{{ID: channelID}, {ID: announcementsID}, {ID: generalID}}
+	dg.channelPermissions[USER_ID+announcementsID] = discordgo.PermissionViewChannel
+	dg.channelPermissions[USER_ID+generalID] = discordgo.PermissionViewChannel | discordgo.PermissionSendMessages
+	dg.mu.Unlock()
+
+	sendMessage := func(targetID string) string {
+		outputs := skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name:      skippy.SendChannelMessage,
+				Arguments: `{"channel_id": "<#` + targetID + `>", "message": "game night is cancelled"}`,
+			}}},
+			skippy.ToolContext{GuildID: guildID, ChannelID: channelID, UserID: USER_ID},
+			s,
+		)
+		return outputs[0].Content
+	}
+
+	if output := sendMessage(announcementsID); !strings.Contains(output, "can not send messages") {
+		t.Error("Expected the message to not be sent where the user can't send messages, got ", output)
+	}
+	if output := sendMessage(generalID); output != "message sent" {
+		t.Error("Expected the message to be sent where the user can send messages, got ", output)
+	}
+
+	dg.mu.Lock()
+	announcements := dg.channelMessages[announcementsID]
+	general := dg.channelMessages[generalID]
+	dg.mu.Unlock()
+	if len(announcements) != 0 || len(general) != 1 {
+		t.Error("Expected only the channel the user can send messages in to get the message, got ", announcements, general)
+	}
+}
+
+func TestRequiredTools(t *testing.T) {
+	t.Parallel()
+	requests := 0
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		requests = n
+		writeCompletion(w, req.Model, "no tools here")
+	})
+	generateEventTool, err := skippy.TOOL_REGISTRY.Tool(skippy.GenerateEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	getToolOutput := func() (string, error) {
+		return skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+			ChannelID:              GenerateRandomID(10),
+			AdditionalInstructions: "make an event for game night",
+			Tools:                  []openai.Tool{generateEventTool},
+			RequireTools:           true,
+			ReturnToolOutput:       true,
+		})
+	}
+
+	// the model answers without calling the tool
+	if output, err := getToolOutput(); err == nil || requests != 1 {
+		t.Error("Expected an error when no tool is called, got ", output, err)
+	}
+
+	fake.Config.NoToolModels = []string{fake.Config.DefaultModel}
+	if output, err := getToolOutput(); !errors.Is(err, skippy.ErrToolsNotSupported) || requests != 1 {
+		t.Error("Expected models without tools to not be asked for a tool, got ", output, err)
+	}
+}
+
+func TestToolOutputsTimeout(t *testing.T) {
+	t.Parallel()
+
+	config := *s.Config
+	config.ToolTimeout = time.Nanosecond
+	timeoutSkippy := *s
+	timeoutSkippy.Config = &config
+
+	toolCalls := []openai.ToolCall{
+		{ID: "1", Function: openai.FunctionCall{Name: skippy.GetWeatherKey, Arguments: `{"location": "Thompson Corners"}`}},
+		{ID: "2", Function: openai.FunctionCall{Name: "not_a_tool"}},
+		{ID: "3", Function: openai.FunctionCall{Name: skippy.GetStockPriceKey, Arguments: `{"symbol": "GME"}`}},
+	}
+	outputs := skippy.GetToolOutputs(
+		context.Background(),
+		toolCalls,
+		skippy.ToolContext{ChannelID: GenerateRandomID(10)},
+		&timeoutSkippy,
+	)
+
+	if len(outputs) != len(toolCalls) {
+		t.Fatal("Expected an output for every tool call")
+	}
+	for i, output := range outputs {
+		if output.ToolCallID != toolCalls[i].ID {
+			t.Error("Expected outputs to be in call order")
+		}
+
+		var toolError skippy.ToolError
+		if err := json.Unmarshal([]byte(output.Content), &toolError); err != nil {
+			t.Fatal("Expected structured error output, got ", output.Content)
+		}
+
+		expected := skippy.TOOL_ERROR_TIMEOUT
+		if toolCalls[i].ID == "2" {
+			expected = skippy.TOOL_ERROR_UNKNOWN
+		}
+		if toolError.Error != expected {
+			t.Errorf("Expected %s error, got %s", expected, toolError.Error)
+		}
+	}
+}
+
+func TestMorningMessageWithOtherTools(t *testing.T) {
+	t.Parallel()
+	channelID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{ChannelID: channelID}
+
+	toolCalls := []openai.ToolCall{
+		{ID: "1", Function: openai.FunctionCall{Name: skippy.EnableMorningMessage, Arguments: `{"time": "09:00", "stocks": ["GME"]}`}},
+		{ID: "2", Function: openai.FunctionCall{Name: skippy.SetReminder, Arguments: `{"message": "test", "timer_length": 3600}`}},
+	}
+	outputs := skippy.GetToolOutputs(context.Background(), toolCalls, toolCtx, s)
+
+	if len(outputs) != 2 {
+		t.Fatal("Expected an output for every tool call")
+	}
+	for _, output := range outputs {
+		if output.Content == "no-op" {
+			t.Error("Expected every tool to be run")
+		}
+	}
+	if !hasDigestJob(t, channelID) {
+		t.Error("Expected morning message to be scheduled")
+	}
+
+	toolCalls = []openai.ToolCall{
+		{ID: "1", Function: openai.FunctionCall{Name: skippy.UpdateMorningMessage, Arguments: `{"time": "10:30", "weather_locations": ["Portland, OR"]}`}},
+		{ID: "2", Function: openai.FunctionCall{Name: skippy.SetReminder, Arguments: `{"message": "test", "timer_length": 3600}`}},
+	}
+	skippy.GetToolOutputs(context.Background(), toolCalls, toolCtx, s)
+
+	digests := getChannelDigests(t, channelID)
+	if len(digests) != 1 {
+		t.Fatal("Expected morning message settings to be saved")
+	}
+	morningMsg := digests[0]
+	if morningMsg.Time != "10:30" {
+		t.Error("Expected time to be updated")
+	}
+	if len(morningMsg.Stocks) != 1 || len(morningMsg.WeatherLocations) != 1 {
+		t.Error("Expected stocks to be kept and weather locations to be added")
+	}
+
+	toolCalls = []openai.ToolCall{
+		{ID: "1", Function: openai.FunctionCall{Name: skippy.DisableMorningMessage, Arguments: `{}`}},
+	}
+	skippy.GetToolOutputs(context.Background(), toolCalls, toolCtx, s)
+
+	if hasDigestJob(t, channelID) {
+		t.Error("Expected morning message to be disabled")
+	}
+}
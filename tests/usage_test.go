@@ -0,0 +1,260 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+func TestImageUsageCost(t *testing.T) {
+	t.Parallel()
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		if n > 1 {
+			writeCompletion(w, req.Model, "here is your image")
+			return
+		}
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: openai.GPT4o,
+			Choices: []openai.ChatCompletionChoice{{
+				Message: openai.ChatCompletionMessage{
+					Role: openai.ChatMessageRoleAssistant,
+					ToolCalls: []openai.ToolCall{{
+						ID:   "1",
+						Type: openai.ToolTypeFunction,
+						Function: openai.FunctionCall{
+							Name:      skippy.GenerateImage,
+							Arguments: `{"prompt": "a fun party banana", "size": "1792x1024", "quality": "hd"}`,
+						},
+					}},
+				},
+				FinishReason: openai.FinishReasonToolCalls,
+			}},
+			Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 10},
+		})
+	})
+	// the image is refused so only the tokens are charged
+	fake.Config.DisabledTools = []string{skippy.GenerateImage}
+	guildID := GenerateRandomID(10)
+
+	_, err := skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		GuildID:   guildID,
+		ChannelID: GenerateRandomID(10),
+		UserID:    USER_ID,
+		Message:   "make me a wide hd image",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	imagePrice := skippy.DEFAULT_IMAGE_PRICES[skippy.ImagePriceKey{
+		Model:   openai.CreateImageModelDallE3,
+		Size:    openai.CreateImageSize1792x1024,
+		Quality: openai.CreateImageQualityHD,
+	}]
+	if cost := getGuildCost(t, guildID); cost <= 0 || cost >= imagePrice {
+		t.Errorf("Expected only the tokens to be charged for a refused image, got %.4f", cost)
+	}
+
+	server, _, _ := newImageAPIServer(t)
+	fixture := newFixtureSkippy("", "")
+	fixture.AIClient = skippy.NewAIClient("fixture", server.URL)
+	fixture.Config.UserDailyImageLimit = 1
+	imageGuildID := GenerateRandomID(10)
+	toolCtx := skippy.ToolContext{GuildID: imageGuildID, ChannelID: GenerateRandomID(10), UserID: GenerateRandomID(10)}
+	for range 2 {
+		skippy.GetToolOutputs(
+			context.Background(),
+			[]openai.ToolCall{{ID: "1", Function: openai.FunctionCall{
+				Name:      skippy.GenerateImage,
+				Arguments: `{"prompt": "a fun party banana", "size": "1792x1024", "quality": "hd"}`,
+			}}},
+			toolCtx,
+			fixture,
+		)
+	}
+	// the second image is over the daily limit
+	if cost := getGuildCost(t, imageGuildID); cost != imagePrice {
+		t.Errorf("Expected only the image that was made to cost %.2f, got %.4f", imagePrice, cost)
+	}
+}
+
+func getGuildCost(t *testing.T, guildID string) float64 {
+	t.Helper()
+	summaries, err := s.DB.GetUsageSummary(guildID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cost := 0.0
+	for _, summary := range summaries {
+		cost += summary.Cost
+	}
+	return cost
+}
+
+func TestBudget(t *testing.T) {
+	t.Parallel()
+	requests := 0
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		requests = n
+		writeCompletion(w, req.Model, "hello")
+	})
+	guildID := GenerateRandomID(10)
+	channelID := GenerateRandomID(10)
+	runCommand := func(permissions int64, options ...*discordgo.ApplicationCommandInteractionDataOption) {
+		skippy.OnInteraction(&discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Type:      discordgo.InteractionApplicationCommand,
+				GuildID:   guildID,
+				ChannelID: channelID,
+				Member: &discordgo.Member{
+					User:        &discordgo.User{ID: USER_ID},
+					Permissions: permissions,
+				},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name:    skippy.BUDGET,
+					Options: options,
+				},
+			},
+		}, fake)
+	}
+	budgetOption := &discordgo.ApplicationCommandInteractionDataOption{
+		Type:  discordgo.ApplicationCommandOptionNumber,
+		Name:  skippy.DAILY_BUDGET,
+		Value: 0.5,
+	}
+
+	runCommand(0, budgetOption)
+	if setting, err := s.DB.GetBudgetSetting(guildID); err != nil || setting != nil {
+		t.Fatal("Expected members without Manage Server to not set the budget, got ", setting, err)
+	}
+
+	runCommand(discordgo.PermissionManageServer, budgetOption)
+	setting, err := s.DB.GetBudgetSetting(guildID)
+	if err != nil || setting == nil || setting.DailyBudget != 0.5 {
+		t.Fatal("Expected the budget to be saved, got ", setting, err)
+	}
+
+	if err := s.DB.CreateUsage(&skippy.Usage{GuildID: guildID, UserID: USER_ID, Cost: 1}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = skippy.GetResponse(context.Background(), fake, skippy.ResponseReq{
+		GuildID:   guildID,
+		ChannelID: channelID,
+		UserID:    USER_ID,
+		Message:   "hello",
+	})
+	if !errors.Is(err, skippy.ErrBudgetExceeded) || requests != 0 {
+		t.Error("Expected the guild budget to be exceeded, got ", err)
+	}
+
+	runCommand(discordgo.PermissionManageServer, &discordgo.ApplicationCommandInteractionDataOption{
+		Type: discordgo.ApplicationCommandOptionBoolean, Name: skippy.RESET, Value: true,
+	})
+	if setting, err := s.DB.GetBudgetSetting(guildID); err != nil || setting != nil {
+		t.Error("Expected reset to remove the guild budget, got ", setting, err)
+	}
+}
+
+func TestUsagePermission(t *testing.T) {
+	t.Parallel()
+	guildID := GenerateRandomID(10)
+	channelID := GenerateRandomID(10)
+	otherUserID := GenerateRandomID(10)
+	for _, usage := range []skippy.Usage{
+		{GuildID: guildID, UserID: USER_ID, Model: openai.GPT4o, Cost: 1},
+		{GuildID: guildID, UserID: otherUserID, Model: openai.GPT4o, Cost: 2},
+	} {
+		if err := s.DB.CreateUsage(&usage); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, permissions := range []int64{0, discordgo.PermissionManageServer} {
+		skippy.OnInteraction(&discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Type:      discordgo.InteractionApplicationCommand,
+				GuildID:   guildID,
+				ChannelID: channelID,
+				Member: &discordgo.Member{
+					User:        &discordgo.User{ID: USER_ID},
+					Permissions: permissions,
+				},
+				Data: discordgo.ApplicationCommandInteractionData{Name: skippy.USAGE},
+			},
+		}, s)
+	}
+
+	dg.mu.Lock()
+	responses := dg.interactionResponses[channelID]
+	dg.mu.Unlock()
+	if len(responses) != 2 {
+		t.Fatal("Expected a response to each command, got ", len(responses))
+	}
+	if own := responses[0].Data.Content; strings.Contains(own, otherUserID) || !strings.Contains(own, "$1.00") {
+		t.Error("Expected members without Manage Server to only see their own usage, got ", own)
+	}
+	if all := responses[1].Data.Content; !strings.Contains(all, otherUserID) || !strings.Contains(all, "$3.00") {
+		t.Error("Expected Manage Server to see the whole server's usage, got ", all)
+	}
+}
+
+func TestDigestUsage(t *testing.T) {
+	t.Parallel()
+	var models []string
+	fake := newFakeCompletionSkippy(t, func(n int, req openai.ChatCompletionRequest, w http.ResponseWriter) {
+		models = append(models, req.Model)
+		json.NewEncoder(w).Encode(openai.ChatCompletionResponse{
+			Model: req.Model,
+			Choices: []openai.ChatCompletionChoice{{
+				Message:      openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: "good morning"},
+				FinishReason: openai.FinishReasonStop,
+			}},
+			Usage: openai.Usage{PromptTokens: 100, CompletionTokens: 10},
+		})
+	})
+	sendDigest := func(guildID string, channelID string) {
+		digest := skippy.Digest{GuildID: guildID, ChannelID: channelID, Time: "8:00", Sections: []string{skippy.SECTION_REMINDERS}}
+		if err := s.DB.CreateDigest(&digest); err != nil {
+			t.Fatal(err)
+		}
+		skippy.SendDigest(digest.ID, fake)
+	}
+
+	// digests use the channel model and count toward the server's usage
+	guildID := GenerateRandomID(10)
+	channelID := GenerateRandomID(10)
+	if err := s.DB.SetModel(guildID, channelID, openai.GPT4oMini); err != nil {
+		t.Fatal(err)
+	}
+	sendDigest(guildID, channelID)
+	if len(models) != 1 || models[0] != openai.GPT4oMini {
+		t.Error("Expected the digest to use the channel model, got ", models)
+	}
+	summaries, err := s.DB.GetUsageSummary(guildID, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 1 || summaries[0].PromptTokens != 100 {
+		t.Error("Expected the digest usage to be recorded for the server, got ", summaries)
+	}
+
+	overBudgetID := GenerateRandomID(10)
+	if err := s.DB.SetBudgetSetting(&skippy.BudgetSetting{GuildID: overBudgetID, DailyBudget: 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DB.CreateUsage(&skippy.Usage{GuildID: overBudgetID, UserID: USER_ID, Cost: 1}); err != nil {
+		t.Fatal(err)
+	}
+	sendDigest(overBudgetID, GenerateRandomID(10))
+	if len(models) != 1 {
+		t.Error("Expected no digest when the server is over its budget, got ", models)
+	}
+}
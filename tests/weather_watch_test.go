@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"skippybot/skippy"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestWeatherWatch(t *testing.T) {
+	t.Parallel()
+	server := newWeatherAPIServer(t)
+	fixture := newFixtureSkippy("", server.URL)
+	channelID := GenerateRandomID(10)
+
+	permissions := int64(0)
+	weatherWatchCommand := func(subcommand string, options ...*discordgo.ApplicationCommandInteractionDataOption) {
+		skippy.OnInteraction(&discordgo.InteractionCreate{
+			Interaction: &discordgo.Interaction{
+				Type:      discordgo.InteractionApplicationCommand,
+				ChannelID: channelID,
+				Member:    &discordgo.Member{User: &discordgo.User{ID: USER_ID}, Permissions: permissions},
+				Data: discordgo.ApplicationCommandInteractionData{
+					Name: skippy.WEATHER_WATCH,
+					Options: []*discordgo.ApplicationCommandInteractionDataOption{
+						{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subcommand, Options: options},
+					},
+				},
+			},
+		}, fixture)
+	}
+	getEmbeds := func() []*discordgo.MessageEmbed {
+		dg.mu.Lock()
+		defer dg.mu.Unlock()
+		return dg.channelEmbeds[channelID]
+	}
+
+	weatherWatchCommand(skippy.ADD, stringOption(skippy.LOCATION, "Fairbanks"))
+	if watches, _ := s.DB.GetWeatherWatchesByChannel(channelID); len(watches) != 0 {
+		t.Fatal("Expected members without Manage Channels to not add a weather watch, got ", watches)
+	}
+
+	permissions = discordgo.PermissionManageChannels
+	weatherWatchCommand(skippy.ADD, stringOption(skippy.LOCATION, "nowhere"))
+	dg.mu.Lock()
+	responses := dg.interactionResponses[channelID]
+	dg.mu.Unlock()
+	if len(responses) != 2 || !strings.Contains(responses[1].Data.Content, "Unable to watch the weather in nowhere") {
+		t.Error("Expected the location that was not found in the response, got ", responses)
+	}
+	weatherWatchCommand(
+		skippy.ADD,
+		stringOption(skippy.LOCATION, "Fairbanks"),
+		stringOption(skippy.UNITS, skippy.WEATHER_UNITS_METRIC),
+	)
+
+	watches, err := s.DB.GetWeatherWatchesByChannel(channelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(watches) != 1 || watches[0].Name != "Fairbanks, Alaska, United States of America" {
+		t.Fatal("Expected only the location that was found to be watched, got ", watches)
+	}
+	watch := watches[0]
+	if !s.Scheduler.HasWeatherWatchJob(watch.ID) {
+		t.Error("Expected the weather watch to be scheduled")
+	}
+
+	embeds := getEmbeds()
+	if len(embeds) != 1 {
+		t.Fatal("Expected the severe weather to be posted when the watch was added, got ", len(embeds))
+	}
+	var fields []string
+	for _, field := range embeds[0].Fields {
+		fields = append(fields, field.Name+" "+field.Value)
+	}
+	text := strings.Join(fields, "\n")
+	if len(fields) != 3 ||
+		!strings.Contains(text, "Winter Storm Warning (Severe)") ||
+		!strings.Contains(text, "Down to -6°C at 10:00 on 2024-01-10") ||
+		!strings.Contains(text, "27 mm of rain expected on 2024-01-11") {
+		t.Error("Expected the severe alert and crossed thresholds, got ", text)
+	}
+	if strings.Contains(text, "Cold Weather Advisory") {
+		t.Error("Expected alerts that are not severe to be skipped, got ", text)
+	}
+
+	if err := skippy.CheckWeatherWatch(context.Background(), watch.ID, fixture); err != nil {
+		t.Fatal(err)
+	}
+	if len(getEmbeds()) != 1 {
+		t.Error("Expected announced weather to not be posted again, got ", len(getEmbeds()))
+	}
+
+	weatherWatchCommand(skippy.REMOVE, intOption(skippy.ID, int(watch.ID)))
+	if watches, _ := s.DB.GetWeatherWatchesByChannel(channelID); len(watches) != 0 {
+		t.Error("Expected the weather watch to be removed, got ", watches)
+	}
+	if s.Scheduler.HasWeatherWatchJob(watch.ID) {
+		t.Error("Expected the weather watch job to be canceled")
+	}
+}